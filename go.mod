@@ -5,8 +5,13 @@ go 1.12
 require (
 	cloud.google.com/go v0.45.1
 	firebase.google.com/go v3.9.0+incompatible
+	github.com/boltdb/bolt v1.3.1
+	github.com/golang-jwt/jwt v3.2.2+incompatible
 	github.com/gorilla/mux v1.7.3
+	github.com/gorilla/websocket v1.4.1
 	github.com/sirupsen/logrus v1.4.2
+	golang.org/x/crypto v0.0.0-20190605123033-f99c8df09eb5
 	golang.org/x/net v0.0.0-20190909003024-a7b16738d86b
 	google.golang.org/api v0.10.0
+	google.golang.org/grpc v1.21.1
 )
@@ -0,0 +1,307 @@
+// O pacote access implementa o motor de decisão de acesso: para cada placa
+// reconhecida em uma portaria decide se o acesso é permitido, negado ou se
+// depende da confirmação do operador, registrando o motivo da decisão
+package access
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/passes"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/registry"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "ACCESS"
+
+	colecaoConfiguracao = "configuracao"
+	documentoRegras     = "regras-acesso"
+	formatoHorario      = "15:04"
+)
+
+// Identificadores das regras, gravados na decisão para auditoria
+const (
+	RegraBloqueio     = "bloqueio"
+	RegraDesconhecido = "placa-desconhecida"
+	RegraValidade     = "validade"
+	RegraPortaria     = "portaria"
+	RegraHorario      = "horario"
+	RegraCadastro     = "cadastro"
+	RegraSaida        = "saida"
+	RegraConfianca    = "confianca"
+	RegraConfirmacao  = "confirmacao"
+	RegraPasse        = "passe"
+)
+
+var (
+	errHorarioInvalido   = errors.New("Horário inválido, utilize o formato HH:MM")
+	errResultadoInvalido = errors.New("Resultado inválido, utilize permitido, negado ou operador")
+	errConfiancaInvalida = errors.New("Confiança mínima inválida, utilize um valor entre 0 e 1")
+)
+
+// JanelaHorario define o horário em que uma categoria pode entrar no campus
+type JanelaHorario struct {
+	Categoria registry.Categoria `json:"categoria"`
+	Dias      []time.Weekday     `json:"dias"`   // dias da semana (0 = domingo). Vazio vale para todos os dias
+	Inicio    string             `json:"inicio"` // HH:MM
+	Fim       string             `json:"fim"`    // HH:MM
+}
+
+// Bloqueio representa uma placa com acesso bloqueado
+type Bloqueio struct {
+	Placa  string `json:"placa"`
+	Motivo string `json:"motivo"`
+}
+
+// Rules define as regras utilizadas na decisão de acesso
+type Rules struct {
+	// Desconhecido é a decisão para placas não cadastradas
+	Desconhecido defaults.ResultadoAcesso `json:"desconhecido"`
+	// Janelas restringe o horário de entrada por categoria. Categorias sem
+	// janela podem entrar em qualquer horário
+	Janelas []JanelaHorario `json:"janelas"`
+	// Portarias restringe as portarias de entrada por categoria. Categorias
+	// sem portarias podem entrar por qualquer portaria
+	Portarias map[registry.Categoria][]string `json:"portarias"`
+	// Bloqueios lista as placas com acesso negado
+	Bloqueios []Bloqueio `json:"bloqueios"`
+	// ConfiancaMinima é a confiança do reconhecimento abaixo da qual a
+	// entrada depende do operador (0 desabilita)
+	ConfiancaMinima float64 `json:"confiancaMinima"`
+	// Confirmar lista as categorias cuja entrada depende do operador
+	Confirmar []registry.Categoria `json:"confirmar"`
+}
+
+// DefaultRules retorna as regras utilizadas enquanto nenhuma regra for
+// cadastrada: placas desconhecidas, reconhecimentos com confiança abaixo de
+// 0.8 e visitantes dependem do operador e estudantes entram nos dias úteis
+// das 06:00 às 23:00
+func DefaultRules() Rules {
+	return Rules{
+		Desconhecido: defaults.AguardaOperador,
+		Janelas: []JanelaHorario{{
+			Categoria: registry.Estudante,
+			Dias:      []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+			Inicio:    "06:00",
+			Fim:       "23:00",
+		}},
+		Portarias:       map[registry.Categoria][]string{},
+		Bloqueios:       []Bloqueio{},
+		ConfiancaMinima: 0.8,
+		Confirmar:       []registry.Categoria{registry.Visitante},
+	}
+}
+
+// Validate verifica se as regras são válidas
+func (r *Rules) Validate() error {
+	switch r.Desconhecido {
+	case defaults.Permitido, defaults.Negado, defaults.AguardaOperador:
+	default:
+		return errResultadoInvalido
+	}
+	if r.ConfiancaMinima < 0 || r.ConfiancaMinima > 1 {
+		return errConfiancaInvalida
+	}
+	for _, j := range r.Janelas {
+		if _, err := time.Parse(formatoHorario, j.Inicio); err != nil {
+			return errHorarioInvalido
+		}
+		if _, err := time.Parse(formatoHorario, j.Fim); err != nil {
+			return errHorarioInvalido
+		}
+	}
+	for i := range r.Bloqueios {
+		r.Bloqueios[i].Placa = plate.Normalize(r.Bloqueios[i].Placa)
+	}
+	return nil
+}
+
+// contem verifica se o tempo t está dentro da janela
+func (j JanelaHorario) contem(t time.Time) bool {
+	if len(j.Dias) > 0 {
+		diaValido := false
+		for _, d := range j.Dias {
+			if d == t.Weekday() {
+				diaValido = true
+				break
+			}
+		}
+		if !diaValido {
+			return false
+		}
+	}
+
+	horario := t.Format(formatoHorario)
+	if j.Inicio > j.Fim {
+		// janela que atravessa a meia-noite
+		return horario >= j.Inicio || horario <= j.Fim
+	}
+	return horario >= j.Inicio && horario <= j.Fim
+}
+
+// Engine decide o acesso das placas de acordo com o cadastro e as regras
+type Engine struct {
+	mutex    sync.RWMutex
+	registry *registry.Registry
+	passes   *passes.Manager
+	docs     storage.Documents
+	regras   Rules
+}
+
+// New cria o motor de decisão, que consulta o cadastro e os passes de
+// visitantes, carregando as regras persistidas em docs
+func New(reg *registry.Registry, pas *passes.Manager, docs storage.Documents) (*Engine, error) {
+	e := &Engine{registry: reg, passes: pas, docs: docs, regras: DefaultRules()}
+
+	var regras Rules
+	switch err := docs.Get(colecaoConfiguracao, documentoRegras, &regras); err {
+	case nil:
+		e.regras = regras
+	case storage.ErrNotFound:
+		log.Log(logService, "Regras de acesso não cadastradas, utilizando regras padrão")
+	default:
+		return nil, err
+	}
+	return e, nil
+}
+
+// Rules retorna as regras de acesso vigentes
+func (e *Engine) Rules() Rules {
+	e.mutex.RLock()
+	defer e.mutex.RUnlock()
+	return e.regras
+}
+
+// SetRules valida, persiste e passa a utilizar as novas regras
+func (e *Engine) SetRules(regras Rules) error {
+	if err := regras.Validate(); err != nil {
+		return err
+	}
+	if err := e.docs.Put(colecaoConfiguracao, documentoRegras, regras); err != nil {
+		return err
+	}
+
+	e.mutex.Lock()
+	e.regras = regras
+	e.mutex.Unlock()
+
+	log.Log(logService, "Regras de acesso atualizadas")
+	return nil
+}
+
+// Decide retorna a decisão de acesso para o evento
+func (e *Engine) Decide(ev defaults.EventoVeiculo) defaults.DecisaoAcesso {
+	d := e.decide(ev)
+	d.Tempo = time.Now()
+	log.Log(logService, "Placa ", ev.Placa, " (", ev.Tipo, " ", ev.Portaria, "): ", d.Resultado, " - ", d.Motivo)
+	return d
+}
+
+// decide aplica as regras na ordem: bloqueio, saída, confiança, passe,
+// cadastro, validade, portaria, horário e confirmação. A primeira regra que se aplica
+// define a decisão
+func (e *Engine) decide(ev defaults.EventoVeiculo) defaults.DecisaoAcesso {
+	regras := e.Rules()
+	placa := plate.Normalize(ev.Placa)
+
+	for _, b := range regras.Bloqueios {
+		if plate.Equal(b.Placa, placa) {
+			if ev.Tipo == defaults.Saida {
+				// A saída de um veículo bloqueado fica a critério do operador
+				return decisao(defaults.AguardaOperador, RegraBloqueio, "saída de placa bloqueada: %s", b.Motivo)
+			}
+			return decisao(defaults.Negado, RegraBloqueio, "placa bloqueada: %s", b.Motivo)
+		}
+	}
+
+	if ev.Tipo == defaults.Saida {
+		return decisao(defaults.Permitido, RegraSaida, "saída liberada")
+	}
+
+	// Uma leitura incorreta pode corresponder a outra placa cadastrada
+	if ev.Confianca > 0 && ev.Confianca < regras.ConfiancaMinima {
+		return decisao(defaults.AguardaOperador, RegraConfianca, "confiança do reconhecimento baixa: %.2f", ev.Confianca)
+	}
+
+	// O passe já é a autorização do anfitrião, por isso dispensa a confirmação
+	passe, errPasse := e.passes.Find(placa, ev.Portaria, ev.Tempo)
+	if errPasse == nil {
+		return decisao(defaults.Permitido, RegraPasse, "passe %s de %s (%s), anfitrião %s", passe.ID, passe.Nome,
+			passe.Categoria, passe.Anfitriao)
+	}
+
+	veiculo, err := e.registry.Vehicle(placa)
+	if err == storage.ErrNotFound {
+		if errPasse == passes.ErrForaDoPeriodo || errPasse == passes.ErrPortaria {
+			return decisao(defaults.Negado, RegraPasse, "%v", errPasse)
+		}
+		return decisao(regras.Desconhecido, RegraDesconhecido, "placa não cadastrada")
+	} else if err != nil {
+		return decisao(defaults.AguardaOperador, RegraCadastro, "erro ao consultar cadastro: %v", err)
+	}
+
+	pessoa, err := e.registry.Person(veiculo.PessoaID)
+	if err != nil {
+		return decisao(defaults.AguardaOperador, RegraCadastro, "erro ao consultar pessoa %s: %v", veiculo.PessoaID, err)
+	}
+
+	if veiculo.Expirado(ev.Tempo) {
+		return decisao(defaults.Negado, RegraValidade, "cadastro do veículo expirado em %s", veiculo.Validade.Format("02/01/2006"))
+	}
+	if pessoa.Expirado(ev.Tempo) {
+		return decisao(defaults.Negado, RegraValidade, "cadastro de %s expirado em %s", pessoa.Nome, pessoa.Validade.Format("02/01/2006"))
+	}
+
+	if portarias := regras.Portarias[pessoa.Categoria]; len(portarias) > 0 && !contemPortaria(portarias, ev.Portaria) {
+		return decisao(defaults.Negado, RegraPortaria, "categoria %s não pode entrar pela portaria %s", pessoa.Categoria, ev.Portaria)
+	}
+
+	janelas, dentro := 0, false
+	for _, j := range regras.Janelas {
+		if j.Categoria != pessoa.Categoria {
+			continue
+		}
+		janelas++
+		if j.contem(ev.Tempo) {
+			dentro = true
+			break
+		}
+	}
+	if janelas > 0 && !dentro {
+		return decisao(defaults.Negado, RegraHorario, "categoria %s fora do horário permitido", pessoa.Categoria)
+	}
+
+	for _, c := range regras.Confirmar {
+		if c == pessoa.Categoria {
+			return decisao(defaults.AguardaOperador, RegraConfirmacao, "entrada de %s (%s) depende de confirmação", pessoa.Nome, pessoa.Categoria)
+		}
+	}
+
+	return decisao(defaults.Permitido, RegraCadastro, "veículo cadastrado de %s (%s)", pessoa.Nome, pessoa.Categoria)
+}
+
+// contemPortaria verifica se a portaria está na lista, sem diferenciar maiúsculas
+func contemPortaria(portarias []string, portaria string) bool {
+	for _, p := range portarias {
+		if strings.EqualFold(p, portaria) {
+			return true
+		}
+	}
+	return false
+}
+
+// decisao monta a decisão de acesso com o motivo formatado
+func decisao(resultado defaults.ResultadoAcesso, regra string, motivo string, args ...interface{}) defaults.DecisaoAcesso {
+	return defaults.DecisaoAcesso{
+		Resultado: resultado,
+		Regra:     regra,
+		Motivo:    fmt.Sprintf(motivo, args...),
+	}
+}
@@ -0,0 +1,372 @@
+// O pacote audit implementa o registro de auditoria: um arquivo somente de
+// inclusão com as ações dos operadores, as cargas de configuração, os arquivos
+// exportados e as gravações de eventos. Cada entrada contém o hash da entrada
+// anterior, de forma que a alteração ou remoção de um registro é detectável
+package audit
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	logService log.Service = "AUDIT"
+
+	arquivoAuditoria = "audit.log"
+	hashInicial      = "0000000000000000000000000000000000000000000000000000000000000000"
+	tamanhoMaxLinha  = 16 * 1024 * 1024
+
+	// AtorSistema identifica as ações executadas pelo próprio sistema
+	AtorSistema = "sistema"
+	segredo     = "***"
+)
+
+// Ações registradas pelos serviços
+const (
+	AcaoHTTP         = "http"
+	AcaoConfiguracao = "configuracao"
+	AcaoArquivo      = "arquivo"
+	AcaoEntrada      = "evento-entrada"
+	AcaoSaida        = "evento-saida"
+)
+
+var (
+	// ErrNaoConfigurado indica que Setup ainda não foi executado
+	ErrNaoConfigurado = errors.New("Registro de auditoria não configurado")
+
+	defaultLog *Log
+)
+
+// Entry representa uma entrada do registro de auditoria
+type Entry struct {
+	Seq          uint64          `json:"seq"`
+	Tempo        time.Time       `json:"tempo"`
+	Ator         string          `json:"ator"`
+	Acao         string          `json:"acao"`
+	Recurso      string          `json:"recurso"`
+	Origem       string          `json:"origem,omitempty"`
+	Resultado    string          `json:"resultado,omitempty"`
+	Antes        json.RawMessage `json:"antes,omitempty"`
+	Depois       json.RawMessage `json:"depois,omitempty"`
+	HashAnterior string          `json:"hashAnterior"`
+	Hash         string          `json:"hash"`
+}
+
+// calculaHash retorna o hash da entrada encadeado com o hash anterior
+func (e Entry) calculaHash() string {
+	e.Hash = ""
+	data, _ := json.Marshal(e)
+	h := sha256.New()
+	h.Write([]byte(e.HashAnterior))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Verificacao é o resultado da verificação da cadeia de hashes
+type Verificacao struct {
+	Valido   bool   `json:"valido"`
+	Entradas uint64 `json:"entradas"`
+	Invalida uint64 `json:"invalida,omitempty"` // primeira entrada alterada, removida ou fora de ordem
+	Motivo   string `json:"motivo,omitempty"`
+}
+
+// Log é o registro de auditoria gravado em arquivo
+type Log struct {
+	mutex      sync.Mutex
+	arquivo    string
+	file       *os.File
+	seq        uint64
+	ultimoHash string
+	docs       Documentos // banco dos checkpoints, nil sem checkpoints
+	checkpoint Checkpoint // último checkpoint gravado
+}
+
+// Open abre (ou cria) o registro de auditoria no diretório informado e
+// verifica a cadeia de hashes existente
+func Open(dir string) (*Log, error) {
+	l := &Log{arquivo: path.Join(dir, arquivoAuditoria), ultimoHash: hashInicial}
+
+	v, err := l.Verify()
+	if err != nil {
+		return nil, err
+	}
+	if !v.Valido {
+		// O registro continua sendo gravado, a verificação continuará apontando a violação
+		log.Log(logService, "ATENÇÃO: registro de auditoria violado na entrada ", v.Invalida, ": ", v.Motivo)
+	}
+
+	if l.file, err = os.OpenFile(l.arquivo, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Close fecha o arquivo do registro
+func (l *Log) Close() error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.file.Close()
+}
+
+// Record acrescenta a entrada ao registro, preenchendo a sequência, o tempo e
+// os hashes
+func (l *Log) Record(e Entry) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.seq++
+	e.Seq = l.seq
+	if e.Tempo.IsZero() {
+		e.Tempo = time.Now()
+	}
+	e.HashAnterior = l.ultimoHash
+	e.Hash = e.calculaHash()
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		l.seq--
+		return err
+	}
+	info, err := l.file.Stat()
+	if err != nil {
+		l.seq--
+		return err
+	}
+	if _, err := l.file.Write(append(data, '\n')); err != nil {
+		// Remove a entrada gravada pela metade, que invalidaria a cadeia
+		if errTrunc := l.file.Truncate(info.Size()); errTrunc != nil {
+			log.Log(logService, "Erro ao remover entrada de auditoria incompleta: ", errTrunc)
+		}
+		l.seq--
+		return err
+	}
+	// A entrada já está no arquivo, mesmo que a sincronização com o disco
+	// falhe, e a próxima deve ser encadeada a ela
+	l.ultimoHash = e.Hash
+	return l.file.Sync()
+}
+
+// percorre lê as entradas do arquivo em ordem, parando quando f retornar false
+func (l *Log) percorre(f func(linha int, e Entry, err error) bool) error {
+	file, err := os.Open(l.arquivo)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), tamanhoMaxLinha)
+	for linha := 1; scanner.Scan(); linha++ {
+		var e Entry
+		err := json.Unmarshal(scanner.Bytes(), &e)
+		if !f(linha, e, err) {
+			return nil
+		}
+	}
+	return scanner.Err()
+}
+
+// Verify percorre o registro verificando a sequência e a cadeia de hashes e,
+// com checkpoints, se o arquivo ainda contém a entrada do último checkpoint.
+// Ao abrir o registro, posiciona a sequência e o último hash no fim do arquivo
+func (l *Log) Verify() (Verificacao, error) {
+	v := Verificacao{Valido: true}
+	anterior := hashInicial
+	var seq uint64
+
+	cp, comCheckpoint := l.leCheckpoint()
+	hashCheckpoint := ""
+
+	// Record grava com o mutex travado. Sem ele uma entrada sendo gravada
+	// seria lida pela metade e apontada como violação
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	err := l.percorre(func(linha int, e Entry, err error) bool {
+		v.Entradas++
+		seq++
+		switch {
+		case err != nil:
+			v.Motivo = fmt.Sprintf("linha %d ilegível: %v", linha, err)
+		case e.Seq != seq:
+			v.Motivo = fmt.Sprintf("sequência %d esperada, encontrada %d", seq, e.Seq)
+		case e.HashAnterior != anterior:
+			v.Motivo = fmt.Sprintf("hash anterior da entrada %d não confere", e.Seq)
+		case e.calculaHash() != e.Hash:
+			v.Motivo = fmt.Sprintf("conteúdo da entrada %d alterado", e.Seq)
+		}
+		if v.Motivo != "" && v.Valido {
+			v.Valido = false
+			v.Invalida = seq
+		}
+		if err == nil {
+			// Continua a cadeia a partir do arquivo para que novas entradas sejam gravadas em sequência
+			seq = e.Seq
+			anterior = e.Hash
+			if comCheckpoint && e.Seq == cp.Seq {
+				hashCheckpoint = e.Hash
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return v, err
+	}
+
+	// A remoção das últimas entradas mantém a cadeia válida, mas o arquivo
+	// deixa de conter a entrada do checkpoint
+	if comCheckpoint && v.Valido {
+		switch {
+		case seq < cp.Seq:
+			v.Valido = false
+			v.Invalida = seq + 1
+			v.Motivo = fmt.Sprintf("registro truncado: checkpoint na entrada %d, arquivo termina na entrada %d", cp.Seq, seq)
+		case hashCheckpoint != cp.Hash:
+			v.Valido = false
+			v.Invalida = cp.Seq
+			v.Motivo = fmt.Sprintf("entrada %d não confere com o checkpoint", cp.Seq)
+		}
+	}
+
+	if l.file == nil {
+		l.seq = seq
+		l.ultimoHash = anterior
+	}
+	return v, nil
+}
+
+// Filtro define os critérios de consulta ao registro. Campos vazios não
+// restringem a consulta
+type Filtro struct {
+	Ator    string
+	Acao    string
+	Recurso string // início do recurso
+	Inicio  time.Time
+	Fim     time.Time
+	Apos    uint64 // retorna as entradas com sequência maior
+	Limite  int
+}
+
+// Query retorna as entradas que atendem ao filtro, em ordem de sequência
+func (l *Log) Query(f Filtro) ([]Entry, error) {
+	entradas := []Entry{}
+	err := l.percorre(func(linha int, e Entry, err error) bool {
+		if err != nil || e.Seq <= f.Apos {
+			return true
+		}
+		if (f.Ator != "" && e.Ator != f.Ator) || (f.Acao != "" && e.Acao != f.Acao) ||
+			(f.Recurso != "" && !strings.HasPrefix(e.Recurso, f.Recurso)) ||
+			(!f.Inicio.IsZero() && e.Tempo.Before(f.Inicio)) || (!f.Fim.IsZero() && e.Tempo.After(f.Fim)) {
+			return true
+		}
+		entradas = append(entradas, e)
+		return f.Limite <= 0 || len(entradas) < f.Limite
+	})
+	return entradas, err
+}
+
+// ultimaEntrada retorna a entrada mais recente da ação
+func (l *Log) ultimaEntrada(acao string) (Entry, bool, error) {
+	var ultima Entry
+	encontrada := false
+	err := l.percorre(func(linha int, e Entry, err error) bool {
+		if err == nil && e.Acao == acao {
+			ultima, encontrada = e, true
+		}
+		return true
+	})
+	return ultima, encontrada, err
+}
+
+// Valor converte v para JSON para os campos Antes e Depois. Valores nil
+// retornam nil
+func Valor(v interface{}) json.RawMessage {
+	if v == nil {
+		return nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return json.RawMessage(fmt.Sprintf("%q", fmt.Sprint(v)))
+	}
+	return data
+}
+
+// Setup abre o registro de auditoria configurado em config.Config, registra a
+// configuração carregada e passa a registrar as próximas cargas
+func Setup() (*Log, error) {
+	l, err := Open(config.Config.Path.Audit)
+	if err != nil {
+		return nil, err
+	}
+	defaultLog = l
+
+	// A configuração anterior é a última registrada, para que alterações no
+	// arquivo com o sistema parado também fiquem registradas
+	var antes json.RawMessage
+	ultima, ok, err := l.ultimaEntrada(AcaoConfiguracao)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		antes = ultima.Depois
+	}
+	depois := Valor(ocultaSegredos(config.Config))
+	if string(antes) != string(depois) {
+		registraConfig(antes, depois)
+	}
+
+	config.OnLoad(func(antes, depois config.SysConfig) {
+		registraConfig(Valor(ocultaSegredos(antes)), Valor(ocultaSegredos(depois)))
+	})
+	return l, nil
+}
+
+// registraConfig registra a carga de uma configuração diferente da anterior
+func registraConfig(antes, depois json.RawMessage) {
+	Record(Entry{Ator: AtorSistema, Acao: AcaoConfiguracao, Recurso: "util/config.json", Antes: antes, Depois: depois})
+}
+
+// ocultaSegredos remove as senhas e chaves da configuração antes do registro
+func ocultaSegredos(cfg config.SysConfig) config.SysConfig {
+	if cfg.Web.ChaveSessao != "" {
+		cfg.Web.ChaveSessao = segredo
+	}
+	if cfg.Web.SenhaAdmin != "" {
+		cfg.Web.SenhaAdmin = segredo
+	}
+	return cfg
+}
+
+// Record acrescenta a entrada ao registro padrão. Erros são apenas registrados
+// no log para que a auditoria não interrompa a operação das portarias
+func Record(e Entry) {
+	if defaultLog == nil {
+		log.Log(logService, "Entrada de auditoria descartada, registro não configurado: ", e.Acao, " ", e.Recurso)
+		return
+	}
+	if err := defaultLog.Record(e); err != nil {
+		log.Log(logService, "Erro ao gravar entrada de auditoria ", e.Acao, " ", e.Recurso, ": ", err)
+	}
+}
+
+// Default retorna o registro padrão criado por Setup
+func Default() (*Log, error) {
+	if defaultLog == nil {
+		return nil, ErrNaoConfigurado
+	}
+	return defaultLog, nil
+}
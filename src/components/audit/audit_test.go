@@ -0,0 +1,247 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const entradasTeste = 5
+
+// documentos guarda os checkpoints em memória
+type documentos struct {
+	mutex sync.Mutex
+	dados map[string][]byte
+}
+
+func (d *documentos) Put(colecao, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.dados[colecao+"/"+id] = data
+	return nil
+}
+
+func (d *documentos) Get(colecao, id string, v interface{}) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	data, ok := d.dados[colecao+"/"+id]
+	if !ok {
+		return errors.New("documento não encontrado")
+	}
+	return json.Unmarshal(data, v)
+}
+
+// novoLog cria um registro com entradasTeste entradas em um diretório
+// temporário. A função retornada fecha o registro e remove o diretório
+func novoLog(t *testing.T) (*Log, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l, err := Open(dir)
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	for i := 0; i < entradasTeste; i++ {
+		if err := l.Record(Entry{Ator: "admin", Acao: AcaoHTTP, Recurso: "/api/vehicles"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return l, func() {
+		l.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// linhas retorna as linhas do arquivo do registro
+func linhas(t *testing.T, l *Log) []string {
+	t.Helper()
+	data, err := ioutil.ReadFile(l.arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.SplitAfter(strings.TrimSuffix(string(data), "\n"), "\n")
+}
+
+// regrava substitui o conteúdo do arquivo do registro
+func regrava(t *testing.T, l *Log, conteudo string) {
+	t.Helper()
+	if err := ioutil.WriteFile(l.arquivo, []byte(conteudo), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerify(t *testing.T) {
+	casos := []struct {
+		nome       string
+		checkpoint bool
+		violacao   func(t *testing.T, l *Log)
+		invalida   uint64
+		motivo     string
+	}{
+		{
+			nome:     "registro íntegro",
+			violacao: func(t *testing.T, l *Log) {},
+		},
+		{
+			nome:       "registro íntegro com checkpoint",
+			checkpoint: true,
+			violacao:   func(t *testing.T, l *Log) {},
+		},
+		{
+			nome: "conteúdo de uma entrada alterado",
+			violacao: func(t *testing.T, l *Log) {
+				ls := linhas(t, l)
+				ls[2] = strings.Replace(ls[2], "/api/vehicles", "/api/passes", 1)
+				regrava(t, l, strings.Join(ls, ""))
+			},
+			invalida: 3,
+			motivo:   "conteúdo da entrada 3 alterado",
+		},
+		{
+			nome: "entrada removida do meio",
+			violacao: func(t *testing.T, l *Log) {
+				ls := linhas(t, l)
+				regrava(t, l, strings.Join(append(ls[:2], ls[3:]...), ""))
+			},
+			invalida: 3,
+			motivo:   "sequência 3 esperada, encontrada 4",
+		},
+		{
+			nome: "últimas entradas removidas sem checkpoint não são detectadas",
+			violacao: func(t *testing.T, l *Log) {
+				regrava(t, l, strings.Join(linhas(t, l)[:3], ""))
+			},
+		},
+		{
+			nome:       "últimas entradas removidas com checkpoint",
+			checkpoint: true,
+			violacao: func(t *testing.T, l *Log) {
+				regrava(t, l, strings.Join(linhas(t, l)[:3], ""))
+			},
+			invalida: 4,
+			motivo:   "registro truncado",
+		},
+		{
+			nome: "arquivo truncado no meio da última entrada",
+			violacao: func(t *testing.T, l *Log) {
+				data, err := ioutil.ReadFile(l.arquivo)
+				if err != nil {
+					t.Fatal(err)
+				}
+				regrava(t, l, string(data[:len(data)-20]))
+			},
+			invalida: entradasTeste,
+			motivo:   "ilegível",
+		},
+		{
+			nome:       "entrada do checkpoint substituída",
+			checkpoint: true,
+			violacao: func(t *testing.T, l *Log) {
+				// A cadeia é refeita a partir da entrada 4, mantendo-se válida
+				ls := linhas(t, l)
+				var e Entry
+				if err := json.Unmarshal([]byte(ls[3]), &e); err != nil {
+					t.Fatal(err)
+				}
+				regrava(t, l, strings.Join(ls[:3], ""))
+				l.seq, l.ultimoHash = 3, e.HashAnterior
+				for i := 0; i < 2; i++ {
+					if err := l.Record(Entry{Ator: "admin", Acao: AcaoHTTP, Recurso: "/api/users"}); err != nil {
+						t.Fatal(err)
+					}
+				}
+			},
+			invalida: entradasTeste,
+			motivo:   "não confere com o checkpoint",
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			l, remove := novoLog(t)
+			defer remove()
+			if c.checkpoint {
+				l.docs = &documentos{dados: map[string][]byte{}}
+				l.gravaCheckpoint()
+			}
+
+			c.violacao(t, l)
+			v, err := l.Verify()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if v.Valido != (c.invalida == 0) || v.Invalida != c.invalida || !strings.Contains(v.Motivo, c.motivo) {
+				t.Errorf("verificação = %+v, esperada violação na entrada %d (%q)", v, c.invalida, c.motivo)
+			}
+		})
+	}
+}
+
+func TestVerifyDuranteGravacao(t *testing.T) {
+	l, remove := novoLog(t)
+	defer remove()
+
+	fim := make(chan struct{})
+	go func() {
+		defer close(fim)
+		for i := 0; i < 200; i++ {
+			recurso := "/api/vehicles/" + strings.Repeat("x", i*100)
+			if err := l.Record(Entry{Ator: "admin", Acao: AcaoHTTP, Recurso: recurso, Tempo: time.Now()}); err != nil {
+				t.Error(err)
+				return
+			}
+		}
+	}()
+
+	for {
+		v, err := l.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.Valido {
+			t.Fatalf("registro apontado como violado durante a gravação: %+v", v)
+		}
+		select {
+		case <-fim:
+			return
+		default:
+		}
+	}
+}
+
+func TestOpenContinuaCadeia(t *testing.T) {
+	l, remove := novoLog(t)
+	defer remove()
+	l.Close()
+
+	// Reabre o registro e continua a cadeia a partir da última entrada
+	l2, err := Open(filepath.Dir(l.arquivo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l2.Close()
+	if err := l2.Record(Entry{Ator: "admin", Acao: AcaoHTTP, Recurso: "/api/users"}); err != nil {
+		t.Fatal(err)
+	}
+
+	v, err := l2.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valido || v.Entradas != entradasTeste+1 {
+		t.Errorf("verificação após reabrir = %+v", v)
+	}
+}
@@ -0,0 +1,95 @@
+package audit
+
+import (
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	colecaoAuditoria    = "auditoria"  // coleção do banco com o checkpoint do registro
+	idCheckpoint        = "checkpoint" // documento do último checkpoint
+	intervaloCheckpoint = time.Minute  // intervalo de gravação do checkpoint
+)
+
+// Documentos define a persistência dos checkpoints, implementada pelo
+// storage.Store
+type Documentos interface {
+	Put(colecao, id string, v interface{}) error
+	Get(colecao, id string, v interface{}) error
+}
+
+// Checkpoint é a última entrada do registro, gravada periodicamente no banco.
+// A cadeia de hashes não detecta a remoção das últimas entradas do arquivo,
+// o checkpoint sim
+type Checkpoint struct {
+	Seq   uint64    `json:"seq"`
+	Hash  string    `json:"hash"`
+	Tempo time.Time `json:"tempo"`
+}
+
+// Checkpoints verifica o registro contra o checkpoint gravado em docs e passa
+// a gravar o checkpoint a cada intervaloCheckpoint. Um registro violado não
+// tem o checkpoint atualizado, preservando a evidência da violação
+func (l *Log) Checkpoints(docs Documentos) error {
+	l.mutex.Lock()
+	l.docs = docs
+	l.mutex.Unlock()
+
+	v, err := l.Verify()
+	if err != nil {
+		return err
+	}
+	if !v.Valido {
+		log.Log(logService, "ATENÇÃO: registro de auditoria violado na entrada ", v.Invalida, ": ", v.Motivo,
+			" - checkpoint não será atualizado")
+		return nil
+	}
+
+	go func() {
+		for {
+			l.gravaCheckpoint()
+			time.Sleep(intervaloCheckpoint)
+		}
+	}()
+	return nil
+}
+
+// leCheckpoint lê o último checkpoint gravado no banco. Retorna false sem
+// checkpoints configurados ou sem checkpoint gravado
+func (l *Log) leCheckpoint() (Checkpoint, bool) {
+	l.mutex.Lock()
+	docs := l.docs
+	l.mutex.Unlock()
+	if docs == nil {
+		return Checkpoint{}, false
+	}
+
+	var cp Checkpoint
+	if err := docs.Get(colecaoAuditoria, idCheckpoint, &cp); err != nil {
+		log.Log(logService, "Checkpoint do registro de auditoria não encontrado: ", err)
+		return Checkpoint{}, false
+	}
+	return cp, true
+}
+
+// gravaCheckpoint grava a última entrada do registro no banco, caso tenha
+// mudado desde o último checkpoint
+func (l *Log) gravaCheckpoint() {
+	l.mutex.Lock()
+	cp := Checkpoint{Seq: l.seq, Hash: l.ultimoHash, Tempo: time.Now()}
+	alterado := cp.Seq != l.checkpoint.Seq || cp.Hash != l.checkpoint.Hash
+	docs := l.docs
+	l.mutex.Unlock()
+	if !alterado || cp.Seq == 0 {
+		return
+	}
+
+	if err := docs.Put(colecaoAuditoria, idCheckpoint, cp); err != nil {
+		log.Log(logService, "Erro ao gravar checkpoint do registro de auditoria: ", err)
+		return
+	}
+	l.mutex.Lock()
+	l.checkpoint = cp
+	l.mutex.Unlock()
+}
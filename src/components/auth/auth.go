@@ -0,0 +1,441 @@
+// O pacote auth implementa a autenticação dos usuários do serviço web: o
+// cadastro local de usuários com senhas protegidas por hash e as sessões
+// assinadas (JWT) entregues no login
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt"
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "AUTH"
+
+	colecaoUsuarios  = "usuarios"
+	colecaoRevogadas = "sessoes-revogadas"
+	colecaoConfig    = "configuracao"
+	documentoChave   = "chave-sessao"
+
+	// UsuarioAdmin é o usuário criado no primeiro início do sistema
+	UsuarioAdmin = "admin"
+
+	duracaoPadrao    = 8 * time.Hour
+	tamanhoChave     = 32
+	tamanhoSenhaMin  = 8
+	emissorSessao    = "controle-acesso"
+	tamanhoSenhaAuto = 12
+)
+
+var (
+	// ErrCredenciais indica usuário inexistente, inativo ou senha incorreta
+	ErrCredenciais = errors.New("Usuário ou senha inválidos")
+	// ErrBloqueado indica muitas falhas de login do usuário ou do endereço de origem
+	ErrBloqueado = errors.New("Muitas tentativas de login, tente novamente mais tarde")
+	// ErrSessaoInvalida indica sessão ausente, expirada, revogada ou com assinatura inválida
+	ErrSessaoInvalida = errors.New("Sessão inválida ou expirada")
+	// ErrSenhaCurta indica que a senha não possui o tamanho mínimo
+	ErrSenhaCurta = errors.New("A senha deve possuir ao menos 8 caracteres")
+
+	// ErrUltimoAdmin indica a remoção ou desativação do último administrador ativo
+	ErrUltimoAdmin = errors.New("O sistema deve possuir ao menos um administrador ativo")
+
+	errUsuarioVazio = errors.New("O nome de usuário é obrigatório")
+)
+
+// User representa um usuário do serviço web
+type User struct {
+	Usuario string `json:"usuario"`
+	Nome    string `json:"nome"`
+	Papel   Papel  `json:"papel"`
+	Ativo   bool   `json:"ativo"`
+	Hash    string `json:"hash,omitempty"`
+	Versao  uint64 `json:"versao,omitempty"` // incrementada ao alterar a senha ou desativar o usuário
+}
+
+// Publico retorna o usuário sem o hash da senha, para envio ao cliente
+func (u User) Publico() User {
+	u.Hash = ""
+	u.Versao = 0
+	return u
+}
+
+// claims são os dados assinados no token da sessão. Versao é a versão do
+// usuário no login: sessões de versões anteriores são inválidas
+type claims struct {
+	jwt.StandardClaims
+	Versao uint64 `json:"ver"`
+}
+
+// Session representa uma sessão autenticada
+type Session struct {
+	ID      string    `json:"-"`
+	Token   string    `json:"token"`
+	Expira  time.Time `json:"expira"`
+	Usuario User      `json:"usuario"`
+}
+
+// chaveSessao é o documento com a chave de assinatura gerada automaticamente
+type chaveSessao struct {
+	Chave string `json:"chave"`
+}
+
+// revogada é o documento de uma sessão encerrada antes da expiração
+type revogada struct {
+	ID     string    `json:"id"`
+	Expira time.Time `json:"expira"`
+}
+
+// Service autentica os usuários e valida as sessões
+type Service struct {
+	docs     storage.Documents
+	rbac     *rbac
+	chave    []byte
+	duracao  time.Duration
+	mutex    sync.Mutex
+	revogada map[string]time.Time // sessões revogadas e sua expiração
+	limite   *limitador
+	ficticio string // hash verificado no login de usuários inexistentes
+}
+
+// New cria o serviço de autenticação. Caso não exista nenhum usuário
+// cadastrado, cria o usuário admin com a senha da configuração
+func New(docs storage.Documents, cfg config.WebConfig) (*Service, error) {
+	s := &Service{
+		docs:     docs,
+		duracao:  time.Duration(cfg.DuracaoSessao) * time.Minute,
+		revogada: map[string]time.Time{},
+		limite:   newLimitador(),
+	}
+	if s.duracao <= 0 {
+		s.duracao = duracaoPadrao
+	}
+
+	var err error
+	if s.ficticio, err = HashSenha(storage.NewID()); err != nil {
+		return nil, err
+	}
+	if s.rbac, err = carregaPapeis(docs); err != nil {
+		return nil, err
+	}
+	if s.chave, err = carregaChave(docs, cfg.ChaveSessao); err != nil {
+		return nil, err
+	}
+	if err := s.carregaRevogadas(); err != nil {
+		return nil, err
+	}
+	if err := s.criaAdmin(cfg.SenhaAdmin); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// carregaChave retorna a chave configurada ou a chave persistida no banco,
+// gerando uma nova no primeiro início
+func carregaChave(docs storage.Documents, configurada string) ([]byte, error) {
+	if configurada != "" {
+		return []byte(configurada), nil
+	}
+
+	var c chaveSessao
+	switch err := docs.Get(colecaoConfig, documentoChave, &c); err {
+	case nil:
+		return hex.DecodeString(c.Chave)
+	case storage.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	chave := make([]byte, tamanhoChave)
+	if _, err := rand.Read(chave); err != nil {
+		return nil, err
+	}
+	if err := docs.Put(colecaoConfig, documentoChave, chaveSessao{Chave: hex.EncodeToString(chave)}); err != nil {
+		return nil, err
+	}
+	log.Log(logService, "Gerada nova chave de assinatura das sessões")
+	return chave, nil
+}
+
+// carregaRevogadas carrega as sessões revogadas que ainda não expiraram
+func (s *Service) carregaRevogadas() error {
+	revogadas := []revogada{}
+	if err := s.docs.List(colecaoRevogadas, &revogadas); err != nil {
+		return err
+	}
+	for _, r := range revogadas {
+		s.revogada[r.ID] = r.Expira
+	}
+	s.limpaRevogadas()
+	return nil
+}
+
+// criaAdmin cria o usuário admin caso não exista nenhum usuário cadastrado.
+// O admin criado antes dos papéis recebe o papel de administrador
+func (s *Service) criaAdmin(senha string) error {
+	usuarios, err := s.Users()
+	if err != nil {
+		return err
+	}
+	for _, u := range usuarios {
+		if u.Usuario == UsuarioAdmin && u.Papel == "" {
+			u.Papel = Administrador
+			log.Log(logService, "Usuário ", UsuarioAdmin, " recebeu o papel ", Administrador)
+			return s.SaveUser(u, "")
+		}
+	}
+	if len(usuarios) > 0 {
+		return nil
+	}
+
+	if senha == "" {
+		b := make([]byte, tamanhoSenhaAuto/2)
+		if _, err := rand.Read(b); err != nil {
+			return err
+		}
+		senha = hex.EncodeToString(b)
+		// A senha é exibida somente no terminal, nunca no arquivo de log
+		fmt.Fprintln(os.Stderr, "Usuário", UsuarioAdmin, "criado com a senha", senha, "- altere a senha no primeiro acesso")
+		log.Log(logService, "Usuário ", UsuarioAdmin, " criado com senha gerada, exibida no terminal - altere a senha no primeiro acesso")
+	} else {
+		log.Log(logService, "Usuário ", UsuarioAdmin, " criado com a senha da configuração")
+	}
+	return s.SaveUser(User{Usuario: UsuarioAdmin, Nome: "Administrador", Papel: Administrador, Ativo: true}, senha)
+}
+
+// Users retorna os usuários cadastrados, sem o hash das senhas
+func (s *Service) Users() ([]User, error) {
+	usuarios := []User{}
+	if err := s.docs.List(colecaoUsuarios, &usuarios); err != nil {
+		return nil, err
+	}
+	for i := range usuarios {
+		usuarios[i] = usuarios[i].Publico()
+	}
+	return usuarios, nil
+}
+
+// User retorna o usuário cadastrado. Retorna storage.ErrNotFound caso não exista
+func (s *Service) User(usuario string) (User, error) {
+	var u User
+	err := s.docs.Get(colecaoUsuarios, normalizaUsuario(usuario), &u)
+	return u, err
+}
+
+// SaveUser cria ou atualiza o usuário. Senha vazia mantém a senha atual e é
+// obrigatória para novos usuários. Alterar a senha ou desativar o usuário
+// invalida as sessões já emitidas
+func (s *Service) SaveUser(u User, senha string) error {
+	u.Usuario = normalizaUsuario(u.Usuario)
+	if u.Usuario == "" {
+		return errUsuarioVazio
+	}
+	if err := ValidaPapel(u.Papel); err != nil {
+		return err
+	}
+
+	atual, err := s.User(u.Usuario)
+	existe := err == nil
+	if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	u.Versao = atual.Versao
+
+	if senha != "" {
+		if len(senha) < tamanhoSenhaMin {
+			return ErrSenhaCurta
+		}
+		hash, err := HashSenha(senha)
+		if err != nil {
+			return err
+		}
+		u.Hash = hash
+		if existe {
+			u.Versao++
+		}
+	} else {
+		if !existe {
+			return ErrSenhaCurta
+		}
+		u.Hash = atual.Hash
+		if atual.Ativo && !u.Ativo {
+			u.Versao++
+		}
+	}
+	if u.Papel != Administrador || !u.Ativo {
+		if err := s.verificaAdmins(u.Usuario); err != nil {
+			return err
+		}
+	}
+	return s.docs.Put(colecaoUsuarios, u.Usuario, u)
+}
+
+// DeleteUser remove o usuário
+func (s *Service) DeleteUser(usuario string) error {
+	usuario = normalizaUsuario(usuario)
+	if err := s.verificaAdmins(usuario); err != nil {
+		return err
+	}
+	return s.docs.Delete(colecaoUsuarios, usuario)
+}
+
+// verificaAdmins retorna ErrUltimoAdmin caso o usuário seja o único
+// administrador ativo
+func (s *Service) verificaAdmins(usuario string) error {
+	usuarios, err := s.Users()
+	if err != nil {
+		return err
+	}
+	for _, u := range usuarios {
+		if u.Usuario != usuario && u.Ativo && u.Papel == Administrador {
+			return nil
+		}
+	}
+	for _, u := range usuarios {
+		if u.Usuario == usuario && u.Ativo && u.Papel == Administrador {
+			return ErrUltimoAdmin
+		}
+	}
+	return nil
+}
+
+// Login verifica as credenciais e cria uma nova sessão para o usuário.
+// origem é o endereço do cliente, utilizado para limitar as tentativas.
+// Retorna ErrBloqueado após muitas falhas do usuário ou da origem
+func (s *Service) Login(usuario, senha, origem string) (Session, error) {
+	agora := time.Now()
+	chave := normalizaUsuario(usuario)
+	if s.limite.bloqueado(chave, origem, agora) {
+		log.Log(logService, "Login do usuário ", usuario, " bloqueado por excesso de falhas (", origem, ")")
+		return Session{}, ErrBloqueado
+	}
+
+	u, err := s.User(usuario)
+	if err != nil && err != storage.ErrNotFound {
+		return Session{}, err
+	}
+	// A senha é verificada mesmo para usuários inexistentes, para que o tempo
+	// de resposta não revele os usuários cadastrados
+	hash := u.Hash
+	if err == storage.ErrNotFound {
+		hash = s.ficticio
+	}
+	if !VerificaSenha(senha, hash) || err == storage.ErrNotFound || !u.Ativo {
+		s.limite.falha(chave, origem, agora)
+		log.Log(logService, "Falha de login do usuário ", usuario, " (", origem, ")")
+		return Session{}, ErrCredenciais
+	}
+	s.limite.sucesso(chave)
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return Session{}, err
+	}
+	sessao := Session{ID: hex.EncodeToString(id), Expira: agora.Add(s.duracao), Usuario: u.Publico()}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims{
+		StandardClaims: jwt.StandardClaims{
+			Id:        sessao.ID,
+			Subject:   u.Usuario,
+			Issuer:    emissorSessao,
+			IssuedAt:  agora.Unix(),
+			ExpiresAt: sessao.Expira.Unix(),
+		},
+		Versao: u.Versao,
+	})
+	if sessao.Token, err = token.SignedString(s.chave); err != nil {
+		return Session{}, err
+	}
+
+	log.Log(logService, "Login do usuário ", u.Usuario)
+	return sessao, nil
+}
+
+// Validate verifica a assinatura e a validade do token, retornando a sessão
+// com os dados atuais do usuário. Sessões emitidas antes da alteração da
+// senha ou da desativação do usuário são inválidas
+func (s *Service) Validate(tokenString string) (Session, error) {
+	c := &claims{}
+	token, err := jwt.ParseWithClaims(tokenString, c, func(t *jwt.Token) (interface{}, error) {
+		if t.Method != jwt.SigningMethodHS256 {
+			return nil, ErrSessaoInvalida
+		}
+		return s.chave, nil
+	})
+	if err != nil || !token.Valid || c.Issuer != emissorSessao {
+		return Session{}, ErrSessaoInvalida
+	}
+
+	s.mutex.Lock()
+	_, revogada := s.revogada[c.Id]
+	s.mutex.Unlock()
+	if revogada {
+		return Session{}, ErrSessaoInvalida
+	}
+
+	// O usuário pode ter sido desativado, removido ou ter a senha alterada
+	// após o login
+	u, err := s.User(c.Subject)
+	if err == storage.ErrNotFound || (err == nil && (!u.Ativo || u.Versao != c.Versao)) {
+		return Session{}, ErrSessaoInvalida
+	} else if err != nil {
+		return Session{}, err
+	}
+
+	return Session{
+		ID:      c.Id,
+		Token:   tokenString,
+		Expira:  time.Unix(c.ExpiresAt, 0),
+		Usuario: u.Publico(),
+	}, nil
+}
+
+// Logout revoga a sessão até a sua expiração
+func (s *Service) Logout(sessao Session) error {
+	if err := s.docs.Put(colecaoRevogadas, sessao.ID, revogada{ID: sessao.ID, Expira: sessao.Expira}); err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	s.revogada[sessao.ID] = sessao.Expira
+	s.mutex.Unlock()
+
+	log.Log(logService, "Logout do usuário ", sessao.Usuario.Usuario)
+	s.limpaRevogadas()
+	return nil
+}
+
+// limpaRevogadas remove as sessões revogadas que já expiraram
+func (s *Service) limpaRevogadas() {
+	agora := time.Now()
+	s.mutex.Lock()
+	expiradas := []string{}
+	for id, expira := range s.revogada {
+		if agora.After(expira) {
+			delete(s.revogada, id)
+			expiradas = append(expiradas, id)
+		}
+	}
+	s.mutex.Unlock()
+
+	for _, id := range expiradas {
+		if err := s.docs.Delete(colecaoRevogadas, id); err != nil && err != storage.ErrNotFound {
+			log.Log(logService, "Erro ao remover sessão revogada ", id, ": ", err)
+		}
+	}
+}
+
+// normalizaUsuario padroniza o nome de usuário utilizado como ID do documento
+func normalizaUsuario(usuario string) string {
+	return strings.ToLower(strings.TrimSpace(usuario))
+}
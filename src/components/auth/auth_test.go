@@ -0,0 +1,91 @@
+package auth
+
+import "testing"
+
+func TestValidateAposAlteracao(t *testing.T) {
+	casos := []struct {
+		nome     string
+		altera   func(s *Service, u User) error
+		invalida bool
+	}{
+		{
+			nome:   "nome alterado",
+			altera: func(s *Service, u User) error { u.Nome = "Outro nome"; return s.SaveUser(u, "") },
+		},
+		{
+			nome:     "senha alterada",
+			altera:   func(s *Service, u User) error { return s.SaveUser(u, "nova-senha-de-teste") },
+			invalida: true,
+		},
+		{
+			nome:     "usuário desativado",
+			altera:   func(s *Service, u User) error { u.Ativo = false; return s.SaveUser(u, "") },
+			invalida: true,
+		},
+		{
+			nome: "usuário desativado e reativado",
+			altera: func(s *Service, u User) error {
+				u.Ativo = false
+				if err := s.SaveUser(u, ""); err != nil {
+					return err
+				}
+				u.Ativo = true
+				return s.SaveUser(u, "")
+			},
+			invalida: true,
+		},
+	}
+
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			s, remove := novoServico(t)
+			defer remove()
+			u := User{Usuario: "operador", Nome: "Operador", Papel: Operador, Ativo: true}
+			if err := s.SaveUser(u, "senha-do-operador"); err != nil {
+				t.Fatal(err)
+			}
+			sessao, err := s.Login(u.Usuario, "senha-do-operador", "127.0.0.1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Validate(sessao.Token); err != nil {
+				t.Fatalf("sessão inválida antes da alteração: %v", err)
+			}
+
+			if err := c.altera(s, u); err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.Validate(sessao.Token)
+			if c.invalida && err != ErrSessaoInvalida {
+				t.Errorf("Validate = %v, esperado %v", err, ErrSessaoInvalida)
+			}
+			if !c.invalida && err != nil {
+				t.Errorf("Validate = %v, esperada sessão válida", err)
+			}
+		})
+	}
+}
+
+func TestValidateNovaSessaoAposSenhaAlterada(t *testing.T) {
+	s, remove := novoServico(t)
+	defer remove()
+	u, err := s.User(UsuarioAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveUser(u, "nova-senha-de-teste"); err != nil {
+		t.Fatal(err)
+	}
+
+	sessao, err := s.Login(UsuarioAdmin, "nova-senha-de-teste", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := s.Validate(sessao.Token)
+	if err != nil {
+		t.Fatalf("Validate = %v, esperada sessão válida", err)
+	}
+	if v.Usuario.Hash != "" || v.Usuario.Versao != 0 {
+		t.Errorf("usuário da sessão = %+v, esperado sem hash e versão", v.Usuario)
+	}
+}
@@ -0,0 +1,80 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	janelaTentativas  = 15 * time.Minute // janela de contagem das falhas de login
+	falhasPorUsuario  = 10               // falhas de um usuário na janela antes do bloqueio
+	falhasPorOrigem   = 20               // falhas de um endereço na janela antes do bloqueio
+	limpezaTentativas = 1000             // tentativas registradas antes de descartar as expiradas
+)
+
+// tentativas conta as falhas de login de um usuário ou endereço desde o início da janela
+type tentativas struct {
+	falhas int
+	inicio time.Time
+}
+
+// limitador bloqueia o login dos usuários e endereços que excederam as
+// falhas permitidas, até o fim da janela de contagem
+type limitador struct {
+	mutex    sync.Mutex
+	usuarios map[string]*tentativas
+	origens  map[string]*tentativas
+}
+
+func newLimitador() *limitador {
+	return &limitador{usuarios: map[string]*tentativas{}, origens: map[string]*tentativas{}}
+}
+
+// bloqueado indica se o login do usuário a partir da origem está bloqueado
+func (l *limitador) bloqueado(usuario, origem string, agora time.Time) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return excedeu(l.usuarios[usuario], falhasPorUsuario, agora) || excedeu(l.origens[origem], falhasPorOrigem, agora)
+}
+
+// falha registra uma falha de login do usuário a partir da origem
+func (l *limitador) falha(usuario, origem string, agora time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	conta(l.usuarios, usuario, agora)
+	if origem != "" {
+		conta(l.origens, origem, agora)
+	}
+}
+
+// sucesso zera as falhas do usuário. As falhas da origem são mantidas, para
+// que um login válido não libere tentativas em outros usuários
+func (l *limitador) sucesso(usuario string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	delete(l.usuarios, usuario)
+}
+
+// excedeu indica se as falhas dentro da janela atingiram o limite
+func excedeu(t *tentativas, limite int, agora time.Time) bool {
+	return t != nil && agora.Sub(t.inicio) < janelaTentativas && t.falhas >= limite
+}
+
+// conta registra a falha da chave, iniciando uma nova janela caso a anterior
+// tenha expirado. Deve ser chamada com o mutex travado
+func conta(m map[string]*tentativas, chave string, agora time.Time) {
+	if len(m) >= limpezaTentativas {
+		for k, t := range m {
+			if agora.Sub(t.inicio) >= janelaTentativas {
+				delete(m, k)
+			}
+		}
+	}
+
+	t, ok := m[chave]
+	if !ok || agora.Sub(t.inicio) >= janelaTentativas {
+		t = &tentativas{inicio: agora}
+		m[chave] = t
+	}
+	t.falhas++
+}
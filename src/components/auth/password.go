@@ -0,0 +1,55 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	algoritmoHash = "pbkdf2-sha256"
+	iteracoesHash = 100000
+	tamanhoSal    = 16
+	tamanhoHash   = 32
+)
+
+// HashSenha gera o hash da senha com PBKDF2-HMAC-SHA256 e um sal aleatório,
+// no formato algoritmo$iterações$sal$hash
+func HashSenha(senha string) (string, error) {
+	sal := make([]byte, tamanhoSal)
+	if _, err := rand.Read(sal); err != nil {
+		return "", err
+	}
+	hash := pbkdf2.Key([]byte(senha), sal, iteracoesHash, tamanhoHash, sha256.New)
+	return fmt.Sprintf("%s$%d$%s$%s", algoritmoHash, iteracoesHash,
+		base64.RawStdEncoding.EncodeToString(sal), base64.RawStdEncoding.EncodeToString(hash)), nil
+}
+
+// VerificaSenha compara a senha com o hash gerado por HashSenha
+func VerificaSenha(senha, hash string) bool {
+	partes := strings.Split(hash, "$")
+	if len(partes) != 4 || partes[0] != algoritmoHash {
+		return false
+	}
+	iteracoes, err := strconv.Atoi(partes[1])
+	if err != nil || iteracoes <= 0 {
+		return false
+	}
+	sal, err := base64.RawStdEncoding.DecodeString(partes[2])
+	if err != nil {
+		return false
+	}
+	esperado, err := base64.RawStdEncoding.DecodeString(partes[3])
+	if err != nil {
+		return false
+	}
+
+	calculado := pbkdf2.Key([]byte(senha), sal, iteracoes, len(esperado), sha256.New)
+	return subtle.ConstantTimeCompare(calculado, esperado) == 1
+}
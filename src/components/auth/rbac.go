@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	colecaoPapeis = "papeis"
+)
+
+// Papel representa a função do usuário no sistema
+type Papel string
+
+// Papéis dos usuários
+const (
+	Operador      Papel = "operador"
+	Supervisor    Papel = "supervisor"
+	Administrador Papel = "administrador"
+)
+
+// Permissao representa uma ação que pode ser autorizada a um papel
+type Permissao string
+
+// Permissões verificadas pelo serviço web
+const (
+	VerEventos        Permissao = "ver-eventos"        // ocupação, visitas e histórico de eventos
+	VerCadastro       Permissao = "ver-cadastro"       // pessoas, veículos e regras de acesso
+	EditarCadastro    Permissao = "editar-cadastro"    // alterar pessoas e veículos
+	AbrirCancela      Permissao = "abrir-cancela"      // abertura manual das cancelas
+	Exportar          Permissao = "exportar"           // exportação de dados
+	Configurar        Permissao = "configurar"         // regras de acesso, câmeras e configurações
+	GerenciarUsuarios Permissao = "gerenciar-usuarios" // usuários e papéis
+	VerAuditoria      Permissao = "ver-auditoria"      // consulta e verificação do registro de auditoria
+)
+
+var (
+	// ErrPapelInvalido indica um papel inexistente
+	ErrPapelInvalido = errors.New("Papel inválido, utilize operador, supervisor ou administrador")
+	// ErrPermissaoInvalida indica uma permissão inexistente
+	ErrPermissaoInvalida = errors.New("Permissão inválida")
+	// ErrPermissaoAdmin indica a tentativa de alterar as permissões do administrador
+	ErrPermissaoAdmin = errors.New("O administrador possui todas as permissões e não pode ser alterado")
+
+	// Permissoes lista todas as permissões existentes
+	Permissoes = []Permissao{VerEventos, VerCadastro, EditarCadastro, AbrirCancela, Exportar, Configurar, GerenciarUsuarios,
+		VerAuditoria}
+)
+
+// Role define as permissões de um papel
+type Role struct {
+	Papel      Papel       `json:"papel"`
+	Permissoes []Permissao `json:"permissoes"`
+}
+
+// Roles agrupa as definições de todos os papéis
+type Roles map[Papel]Role
+
+// DefaultRoles retorna os papéis criados no primeiro início do sistema
+func DefaultRoles() Roles {
+	return Roles{
+		Operador: {Papel: Operador, Permissoes: []Permissao{VerEventos, VerCadastro, AbrirCancela}},
+		Supervisor: {Papel: Supervisor, Permissoes: []Permissao{VerEventos, VerCadastro, AbrirCancela,
+			EditarCadastro, Exportar}},
+		Administrador: {Papel: Administrador, Permissoes: Permissoes},
+	}
+}
+
+// Permite verifica se o papel possui a permissão
+func (r Roles) Permite(papel Papel, p Permissao) bool {
+	for _, permissao := range r[papel].Permissoes {
+		if permissao == p {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidaPapel verifica se o papel existe
+func ValidaPapel(papel Papel) error {
+	switch papel {
+	case Operador, Supervisor, Administrador:
+		return nil
+	}
+	return ErrPapelInvalido
+}
+
+// Validate verifica se o papel e as permissões existem. O papel de
+// administrador possui todas as permissões e não pode ser alterado
+func (r Role) Validate() error {
+	if err := ValidaPapel(r.Papel); err != nil {
+		return err
+	}
+
+	if r.Papel == Administrador {
+		return ErrPermissaoAdmin
+	}
+
+	for _, p := range r.Permissoes {
+		valida := false
+		for _, existente := range Permissoes {
+			if p == existente {
+				valida = true
+				break
+			}
+		}
+		if !valida {
+			return ErrPermissaoInvalida
+		}
+	}
+	return nil
+}
+
+// rbac mantém os papéis persistidos no banco
+type rbac struct {
+	mutex  sync.RWMutex
+	docs   storage.Documents
+	papeis Roles
+}
+
+// carregaPapeis carrega os papéis do banco, gravando os papéis padrão que
+// ainda não existirem
+func carregaPapeis(docs storage.Documents) (*rbac, error) {
+	r := &rbac{docs: docs, papeis: Roles{}}
+
+	papeis := []Role{}
+	if err := docs.List(colecaoPapeis, &papeis); err != nil {
+		return nil, err
+	}
+	for _, p := range papeis {
+		r.papeis[p.Papel] = p
+	}
+
+	// O administrador recebe as permissões criadas após o cadastro do papel
+	if admin, ok := r.papeis[Administrador]; ok && len(admin.Permissoes) != len(Permissoes) {
+		admin.Permissoes = Permissoes
+		if err := docs.Put(colecaoPapeis, string(Administrador), admin); err != nil {
+			return nil, err
+		}
+		r.papeis[Administrador] = admin
+		log.Log(logService, "Papel ", Administrador, " atualizado com as novas permissões")
+	}
+
+	for papel, padrao := range DefaultRoles() {
+		if _, ok := r.papeis[papel]; ok {
+			continue
+		}
+		if err := docs.Put(colecaoPapeis, string(papel), padrao); err != nil {
+			return nil, err
+		}
+		r.papeis[papel] = padrao
+		log.Log(logService, "Papel ", papel, " criado com as permissões padrão")
+	}
+	return r, nil
+}
+
+// Roles retorna a definição dos papéis
+func (s *Service) Roles() Roles {
+	s.rbac.mutex.RLock()
+	defer s.rbac.mutex.RUnlock()
+
+	papeis := Roles{}
+	for k, v := range s.rbac.papeis {
+		papeis[k] = v
+	}
+	return papeis
+}
+
+// SetRole valida, persiste e passa a utilizar as permissões do papel
+func (s *Service) SetRole(r Role) error {
+	if err := r.Validate(); err != nil {
+		return err
+	}
+	if err := s.docs.Put(colecaoPapeis, string(r.Papel), r); err != nil {
+		return err
+	}
+
+	s.rbac.mutex.Lock()
+	s.rbac.papeis[r.Papel] = r
+	s.rbac.mutex.Unlock()
+
+	log.Log(logService, "Permissões do papel ", r.Papel, " atualizadas: ", r.Permissoes)
+	return nil
+}
+
+// Allowed verifica se o usuário possui a permissão através do seu papel
+func (s *Service) Allowed(u User, p Permissao) bool {
+	return u.Ativo && s.Roles().Permite(u.Papel, p)
+}
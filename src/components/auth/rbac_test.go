@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+// novoServico cria o serviço de autenticação em um banco local temporário.
+// A função retornada remove o banco
+func novoServico(t *testing.T) (*Service, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := storage.NewLocal(filepath.Join(dir, "auth.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	remove := func() {
+		store.Close()
+		os.RemoveAll(dir)
+	}
+
+	s, err := New(store, config.WebConfig{SenhaAdmin: "senha-de-teste"})
+	if err != nil {
+		remove()
+		t.Fatal(err)
+	}
+	return s, remove
+}
+
+func TestAllowed(t *testing.T) {
+	s, remove := novoServico(t)
+	defer remove()
+
+	// Permissões padrão de cada papel, as ausentes são negadas
+	permitidas := map[Papel][]Permissao{
+		Operador:      {VerEventos, VerCadastro, AbrirCancela},
+		Supervisor:    {VerEventos, VerCadastro, AbrirCancela, EditarCadastro, Exportar},
+		Administrador: Permissoes,
+		"visitante":   nil,
+		"":            nil,
+	}
+
+	for papel, lista := range permitidas {
+		for _, p := range Permissoes {
+			esperado := false
+			for _, permitida := range lista {
+				esperado = esperado || permitida == p
+			}
+
+			for _, ativo := range []bool{true, false} {
+				u := User{Usuario: "teste", Papel: papel, Ativo: ativo}
+				// Usuários inativos não possuem nenhuma permissão
+				if got, want := s.Allowed(u, p), esperado && ativo; got != want {
+					t.Errorf("Allowed(papel %q, ativo %v, %s) = %v, esperado %v", papel, ativo, p, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestAllowedPermissaoDesconhecida(t *testing.T) {
+	s, remove := novoServico(t)
+	defer remove()
+	for _, papel := range []Papel{Operador, Supervisor, Administrador} {
+		if s.Allowed(User{Usuario: "teste", Papel: papel, Ativo: true}, "apagar-tudo") {
+			t.Errorf("papel %s autorizado em permissão inexistente", papel)
+		}
+	}
+}
+
+func TestSetRole(t *testing.T) {
+	s, remove := novoServico(t)
+	defer remove()
+	operador := User{Usuario: "teste", Papel: Operador, Ativo: true}
+
+	if err := s.SetRole(Role{Papel: Operador, Permissoes: []Permissao{VerEventos, Exportar}}); err != nil {
+		t.Fatal(err)
+	}
+	casos := []struct {
+		p    Permissao
+		quer bool
+	}{
+		{VerEventos, true},
+		{Exportar, true},
+		{AbrirCancela, false},
+		{VerCadastro, false},
+	}
+	for _, c := range casos {
+		if got := s.Allowed(operador, c.p); got != c.quer {
+			t.Errorf("Allowed(operador, %s) após SetRole = %v, esperado %v", c.p, got, c.quer)
+		}
+	}
+
+	erros := []struct {
+		nome string
+		role Role
+		err  error
+	}{
+		{"papel inexistente", Role{Papel: "visitante", Permissoes: []Permissao{VerEventos}}, ErrPapelInvalido},
+		{"administrador", Role{Papel: Administrador, Permissoes: []Permissao{VerEventos}}, ErrPermissaoAdmin},
+		{"permissão inexistente", Role{Papel: Supervisor, Permissoes: []Permissao{"apagar-tudo"}}, ErrPermissaoInvalida},
+	}
+	for _, c := range erros {
+		if err := s.SetRole(c.role); err != c.err {
+			t.Errorf("SetRole(%s) = %v, esperado %v", c.nome, err, c.err)
+		}
+	}
+	if !s.Allowed(User{Usuario: "teste", Papel: Administrador, Ativo: true}, GerenciarUsuarios) {
+		t.Error("administrador perdeu permissões após SetRole inválido")
+	}
+}
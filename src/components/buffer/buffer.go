@@ -4,11 +4,15 @@ import (
 	"errors"
 	"sync"
 	"time"
+
+	"github.com/gustavolimam/control-access/src/components/image"
 )
 
 const (
 	bufferTimeoutLoop = time.Minute // tempo para verificação de dados obsoletos
 	bufferTimeout     = 60          // tempo para descartar dados obsoletos - Em minutos
+
+	janelaRepetidasPadrao = 30 * time.Second // intervalo de leituras repetidas quando não configurado
 )
 
 var errFrameNotFound = errors.New("Nao foi possivel obter frame")
@@ -6,6 +6,7 @@ import (
 
 	"github.com/gustavolimam/control-access/src/components/config"
 	"github.com/gustavolimam/control-access/src/components/messages"
+	"github.com/gustavolimam/control-access/src/components/plate"
 )
 
 // InfraBuffer define a estrutura do buffer circular de vídeo
@@ -22,25 +23,53 @@ func NewPlateBuffer() *InfraBuffer {
 	return b
 }
 
-// FindPlateBuffer busca o índice do elemento que tem o timestamp mais próximo ao tempo t
-func (b *InfraBuffer) FindPlateBuffer(plateBuf *messages.BufferPackage) bool {
-	plate := plateBuf.PlateInfo.Placa
+// FindPlateBuffer verifica se a placa é uma leitura repetida de uma placa do
+// buffer, dentro do intervalo de leituras repetidas (Plate.PlateBufferSeconds).
+// A mesma placa (plate.Key) é repetida e renova o intervalo, enquanto o
+// veículo estiver na frente da câmera. Leituras diferentes são pareadas pelo
+// plate.Matcher, que tolera apenas as trocas entre caracteres confundíveis:
+// acima da confiança de revisão a leitura é repetida, abaixo dela a leitura
+// não é descartada e o pareamento retornado indica Revisar. Leituras que não
+// são repetidas são salvas no buffer. O Match retornado é o pareamento com a
+// placa do buffer, com Indice -1 quando nenhuma corresponde
+func (b *InfraBuffer) FindPlateBuffer(plateBuf *messages.BufferPackage) (bool, plate.Match) {
+	if plateBuf.Placa == "" {
+		plateBuf.Placa = plate.Key(plateBuf.PlateInfo.Placa)
+	}
+	cfg := config.Config.Plate
+	janela := time.Duration(cfg.PlateBufferSeconds * float64(time.Second))
+	if janela <= 0 {
+		janela = janelaRepetidasPadrao
+	}
+	t := plateBuf.Frame.Time
 
 	b.bufferMutex.Lock()
 	defer b.bufferMutex.Unlock()
-	// Verifica se a placa já se encontra no buffer, caso não esteja ela será salva
-	if _, ok := b.bufferPlate[plate]; !ok {
-		b.bufferPlate[plate] = *plateBuf
-		return false
-	} else {
-		// Após identificar a placa no buffer, verificamos o tempo e caso esteja acima do tempo configurado,
-		// a função retorna true, permitindo que a placa seja passada adiante para a então geração da multa.
-		timestamp := time.Since(b.bufferPlate[plate].Frame.Time).Seconds()
-		if timestamp > config.Config.Plate.PlateBufferSeconds {
-			return true
+
+	if anterior, ok := b.bufferPlate[plateBuf.Placa]; ok && recente(anterior, t, janela) {
+		b.bufferPlate[plateBuf.Placa] = *plateBuf
+		return true, plate.Match{Indice: 0, Placa: anterior.PlateInfo.Placa, Confianca: 1}
+	}
+
+	candidatos := []plate.Candidate{}
+	for _, p := range b.bufferPlate {
+		if recente(p, t, janela) {
+			candidatos = append(candidatos, plate.Candidate{Placa: p.PlateInfo.Placa, Tempo: p.Frame.Time})
 		}
 	}
-	return false
+	matcher := plate.Matcher{Minima: cfg.ConfiancaMinima, Revisao: cfg.ConfiancaRevisao, Janela: janela}
+	m := matcher.Best(plateBuf.PlateInfo.Placa, t, candidatos)
+	if m.Indice >= 0 && !m.Revisar {
+		return true, m
+	}
+	b.bufferPlate[plateBuf.Placa] = *plateBuf
+	return false, m
+}
+
+// recente verifica se a placa do buffer foi lida a menos de janela de t
+func recente(p messages.BufferPackage, t time.Time, janela time.Duration) bool {
+	dt := t.Sub(p.Frame.Time)
+	return dt > -janela && dt < janela
 }
 
 // DeletaPlateBuffer -  Percorre todo o map e verifica se algum dos itens encontrados estão a mais de 10 minutos,
@@ -0,0 +1,55 @@
+package buffer
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/messages"
+	"github.com/gustavolimam/control-access/src/components/recognition"
+)
+
+// leitura cria o pacote do buffer da placa lida no tempo t
+func leitura(placa string, t time.Time) messages.BufferPackage {
+	return messages.NewBufferPackage(recognition.Reconhecimento{Placa: placa, Confianca: 0.9}, &image.ImageStruct{Time: t})
+}
+
+func TestFindPlateBuffer(t *testing.T) {
+	casos := []struct {
+		nome      string
+		anterior  string
+		lida      string
+		dt        time.Duration
+		repetida  bool
+		revisar   bool
+		confianca float64
+	}{
+		{"mesma placa", "ABC1234", "ABC1234", 5 * time.Second, true, false, 1},
+		{"forma Mercosul da mesma placa", "ABC1234", "ABC1C34", 5 * time.Second, true, false, 1},
+		{"mesma placa após o intervalo", "ABC1234", "ABC1234", 40 * time.Second, false, false, 0},
+		{"último dígito diferente é outro veículo", "ABC1234", "ABC1235", time.Second, false, false, 0},
+		{"caractere não lido é outro veículo", "ABC1234", "ABC123", time.Second, false, false, 0},
+		{"uma confusão é repetida", "EXI7254", "EX17254", time.Second, true, false, 0},
+		{"duas confusões são enviadas para revisão", "EXI7254", "EX17Z54", time.Second, false, true, 0},
+		{"confusão distante no tempo é enviada para revisão", "EXI7254", "EX17254", 20 * time.Second, false, true, 0},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			b := NewPlateBuffer()
+			agora := time.Now()
+			anterior := leitura(c.anterior, agora)
+			if repetida, _ := b.FindPlateBuffer(&anterior); repetida {
+				t.Fatal("primeira leitura apontada como repetida")
+			}
+
+			lida := leitura(c.lida, agora.Add(c.dt))
+			repetida, m := b.FindPlateBuffer(&lida)
+			if repetida != c.repetida || m.Revisar != c.revisar {
+				t.Errorf("FindPlateBuffer = %v, %+v, esperado repetida %v e revisar %v", repetida, m, c.repetida, c.revisar)
+			}
+			if c.confianca == 1 && m.Confianca != 1 {
+				t.Errorf("confiança da mesma placa = %.3f", m.Confianca)
+			}
+		})
+	}
+}
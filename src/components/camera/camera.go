@@ -2,32 +2,38 @@ package camera
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
 	"strings"
-	"sync/atomic"
 	"time"
 
+	"github.com/gustavolimam/control-access/src/components/image"
 	"github.com/gustavolimam/control-access/src/components/log"
 )
 
 const (
 	timeStartAttempts     = 5               // maximo de requisições para tentar obter tempo inicial da câmera
 	sleepRetryConection   = time.Second     // Tempo de espera para a tentativa de nova conexao a API camera
-	timeoutImg            = time.Second     // Timeout recebimento de imagem
 	blockConnection       = 2 * time.Second // Timeout para conexão bloqueada sem recebimento de nova imagem
 	timeoutConnectionSync = 1 * time.Second // Timeout do client de conexão para sincronização do relógio
 )
 
-// Camera representa a estrutura de uma câmera
+// fimJpeg é o marcador de fim da imagem (EOI) que encerra todo frame JPEG
+var fimJpeg = []byte{0xFF, 0xD9}
+
+// Camera representa a estrutura de uma câmera com a API mjpegvideo.cgi e
+// config.cgi. Implementa FrameSource, entregando os frames com o timestamp
+// calculado a partir do relógio da câmera
 type Camera struct {
-	logService         log.Service
+	base
 	Address            string  // Ip da câmera
-	ChFrame            ChFrame // Canal que os frames serão enviados
+	ChFrame            ChFrame // Canal que os frames serão enviados por SendFrames
 	FrameRate          int
 	StartTimestamp     time.Time // Tempo inicial da câmera
 	LastFrameTimestamp time.Time // Tempo do último frame
@@ -49,89 +55,122 @@ type ChFrame chan []byte
 func New(logService log.Service, address string, chFrame ChFrame, frameRate int, imgQuality int) *Camera {
 	log.Log(logService, "Nova camera instanciada: ", address)
 
-	return &Camera{logService, address, chFrame,
-		frameRate, time.Now(), time.Now(), imgQuality}
+	c := &Camera{
+		Address:            address,
+		ChFrame:            chFrame,
+		FrameRate:          frameRate,
+		StartTimestamp:     time.Now(),
+		LastFrameTimestamp: time.Now(),
+		ImgQuality:         imgQuality,
+	}
+	c.prepara(logService, limiteSemFrames(frameRate))
+	return c
 }
 
 // SendFrames envia frames capturados no canal ChFrame
 func (c *Camera) SendFrames() {
+	go c.captura(func(img []byte) {
+		c.ChFrame <- img
+	})
+}
 
-	c.syncTimeLoopInfinito()
+// Start inicia a captura, entregando os frames processados no canal Frames
+func (c *Camera) Start() error {
+	if err := c.inicia(); err != nil {
+		return err
+	}
+	go func() {
+		defer c.encerra()
+		c.captura(func(img []byte) {
+			c.entrega(c.ProcessaFrame(img), false)
+		})
+	}()
+	return nil
+}
 
+// captura sincroniza o relógio, conecta ao vídeo mjpeg da câmera e entrega os
+// frames recebidos até a captura ser encerrada, reconectando em caso de falha
+func (c *Camera) captura(envia func(img []byte)) {
 	URL := fmt.Sprintf("http://%s/api/mjpegvideo.cgi?Quality=%d&FrameRate=%d",
 		c.Address, c.ImgQuality, c.FrameRate)
 
+	// Encerra a conexão em andamento quando a captura é encerrada
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-c.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	client := http.Client{}
-	var response *http.Response
-	var err error
-	for {
-		response, err = client.Get(URL)
+	for tentativa := 0; ; tentativa++ {
+		if tentativa > 0 && !c.aguarda(sleepRetryConection) {
+			return
+		}
+		if !c.sincroniza() {
+			return
+		}
+
+		c.estado(Conectando)
+		req, err := http.NewRequest(http.MethodGet, URL, nil)
+		if err != nil {
+			log.Log(c.logService, "URL de vídeo da câmera inválida: ", err.Error())
+			return
+		}
+		response, err := client.Do(req.WithContext(ctx))
 		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
 			log.Log(c.logService, "Falha na conexão HTTP de vídeo com a câmera: ", err.Error())
-			time.Sleep(sleepRetryConection)
-		} else {
-			log.Log(c.logService, "Conexao feita (", c.Address, ") com sucesso.")
-			break
+			c.reconexao(err)
+			continue
 		}
-	}
+		log.Log(c.logService, "Conexao feita (", c.Address, ") com sucesso.")
+		c.conectada()
 
-	var getJpegOK int32
-	// Monitora a conexão de vídeo com a câmera. Caso a função getJpeg()
-	// fique bloqueado por mais de 2s fecha o response.
-	// Body para forçar o retorno de erro.
-	go func() {
-		for {
-			atomic.StoreInt32(&getJpegOK, 0)
-			time.Sleep(blockConnection)
-			if atomic.LoadInt32(&getJpegOK) == 0 {
-				if response != nil {
-					response.Body.Close()
-				}
-			}
+		err = c.leVideo(response.Body, envia)
+		response.Body.Close()
+		if ctx.Err() != nil {
+			return
 		}
-	}()
+		log.Log(c.logService, "Falha na decodificação do vídeo mjpeg: ", err.Error())
+		if err != io.EOF {
+			c.erro(err)
+		}
+		c.reconexao(err)
+	}
+}
 
-	// nextImg, no qual o sci-nmet.go vai ficar olhando e tratando os dados
-	// processa dados do HTTP
-	go func() {
-		for {
-			if response != nil {
-				img, err := getJpeg(response.Body)
-				atomic.StoreInt32(&getJpegOK, 1)
-				// Caso não esteja mais recebendo imagens, dorme por um segundo e tenta reconectar posteriormente
-				if err == io.EOF {
-					time.Sleep(timeoutImg)
-				}
-				if err != nil {
-					log.Log(c.logService, "Falha na decodificação do vídeo mjpeg: ", err.Error())
-					response.Body.Close()
-					response, err = client.Get(URL)
-					if err != nil {
-						log.Log(c.logService, "Falha na conexão http de vídeo com a câmera: ", err.Error())
-					}
-				}
-				if img != nil {
-					c.ChFrame <- img
-				}
-			} else {
-				var err error
-				c.syncTimeLoopInfinito()
-				if response, err = client.Get(URL); err != nil {
-					atomic.StoreInt32(&getJpegOK, 1)
-					// Caso a URL esteja inacessível, aguarda um segundo antes de tentar novamente
-					if err != nil {
-						log.Log(c.logService, "Falha na tentativa de reconexão HTTP de vídeo com a camera. Erro: ",
-							err.Error())
-						time.Sleep(sleepRetryConection)
-					} else {
-						log.Log(c.logService, "Conexao feita (", c.Address, ") com sucesso.")
-						continue
-					}
-					time.Sleep(sleepRetryConection)
-				}
-			}
+// leVideo lê os frames do vídeo mjpeg até a primeira falha. Caso a leitura
+// fique bloqueada por mais de blockConnection a conexão é fechada, forçando
+// o retorno de erro e a reconexão
+func (c *Camera) leVideo(body io.ReadCloser, envia func(img []byte)) error {
+	vigia := time.AfterFunc(blockConnection, func() {
+		body.Close()
+	})
+	defer vigia.Stop()
+
+	for {
+		img, err := getJpeg(body)
+		if err != nil {
+			return err
 		}
-	}()
+		// Partes truncadas pela câmera são descartadas. A leitura continua a
+		// partir do próximo boundary encontrado
+		if !bytes.HasSuffix(img, fimJpeg) {
+			log.Log(c.logService, "Frame descartado: ", errIncompleto.Error())
+			c.erro(errIncompleto)
+			continue
+		}
+		// O tempo de entrega do frame não é considerado bloqueio da conexão
+		vigia.Stop()
+		envia(img)
+		vigia.Reset(blockConnection)
+	}
 }
 
 // syncTime atualiza c.inicioCamera para cálculo correto de timestamp das imagens
@@ -149,14 +188,24 @@ func (c *Camera) SyncTime() error {
 	return err
 }
 
-// syncTimeLoopInfinito executa sincronização do horário indefinidamente até que
-// a sincronização obtenha sucesso, se isso ocorrer retorna
-func (c *Camera) syncTimeLoopInfinito() {
+// sincroniza executa a sincronização do horário até que obtenha sucesso.
+// Retorna false caso a captura seja encerrada antes
+func (c *Camera) sincroniza() bool {
 	for {
-		if err := c.SyncTime(); err == nil {
-			return
+		err := c.SyncTime()
+		if err == nil {
+			return true
+		}
+		// Sem resposta a câmera está offline, com resposta inválida o relógio
+		// não pode ser sincronizado
+		if _, ok := err.(*url.Error); ok {
+			c.falha(Offline, err)
+		} else {
+			c.falha(SemSincronia, err)
+		}
+		if !c.aguarda(sleepRetryConection) {
+			return false
 		}
-		time.Sleep(sleepRetryConection)
 	}
 }
 
@@ -196,18 +245,7 @@ func (c *Camera) GetStartTime() (t time.Time, err error) {
 
 // extractComment retorna os comentários do arquivo jpg
 func (c *Camera) ExtractComment(img []byte) (data map[string]string) {
-	data = map[string]string{}
-	ini := bytes.Index(img, []byte{0xFF, 0xFE})
-	if ini >= 0 {
-		comString := string(img[ini+4:])
-		for _, campo := range strings.Split(comString, ";") {
-			par := strings.Split(campo, "=")
-			if len(par) == 2 {
-				data[par[0]] = par[1]
-			}
-		}
-	}
-	return
+	return comentario(img)
 }
 
 // processaTimestamp calcula o timestamp a partir do TempoCaptura, valida e sincroniza a base de tempo se necessário
@@ -217,17 +255,17 @@ func (c *Camera) ProcessaTimestamp(tempoCaptura uint64) time.Time {
 	// verifica se o timestamp é válido
 	if frameTimestamp.Before(c.LastFrameTimestamp) {
 		log.Log(c.logService, "Erro na verificação do timestamp - erro : Frame Anterior ", c.LastFrameTimestamp, "Valor do FrameTimestamp: ", frameTimestamp)
-		c.syncTimeLoopInfinito()
+		c.sincroniza()
 		frameTimestamp = c.StartTimestamp.Add(time.Duration(tempoCaptura) * time.Millisecond)
 	}
 	/*if time.Since(frameTimestamp) > time.Second {
 		log.Log(c.logService, "Erro na verificação do timestamp - erro : Frame Anterior ", c.LastFrameTimestamp, "Valor do FrameTimestamp: ", frameTimestamp)
-		c.syncTimeLoopInfinito()
+		c.sincroniza()
 		frameTimestamp = c.StartTimestamp.Add(time.Duration(tempoCaptura) * time.Millisecond)
 	}*/
 	if c.StartTimestamp == timeZero {
 		log.Log(c.logService, "Erro na verificação do timestamp - erro : Frame Anterior ", c.LastFrameTimestamp, "Valor do FrameTimestamp: ", frameTimestamp)
-		c.syncTimeLoopInfinito()
+		c.sincroniza()
 		frameTimestamp = c.StartTimestamp.Add(time.Duration(tempoCaptura) * time.Millisecond)
 	}
 
@@ -240,15 +278,7 @@ func (c *Camera) ProcessaFrame(img []byte) *image.ImageStruct {
 	tempoCaptura, _ := strconv.ParseUint(strings.TrimSpace(infoMap["TempoCaptura"]), 10, 64)
 	timestamp := c.ProcessaTimestamp(tempoCaptura)
 
-	isNight, _ := strconv.ParseUint(strings.TrimSpace(infoMap["SituacaoDayNight"]), 10, 64)
-
-	night := 0
-
-	if isNight == 2 {
-		night = 1
-	}
-
-	return &image.ImageStruct{Image: img, Time: timestamp, IsNightMode: night}
+	return &image.ImageStruct{Image: img, Time: timestamp, IsNightMode: modoNoturno(infoMap)}
 }
 
 // getJpeg returns the next Image found in the MJPEG stream.
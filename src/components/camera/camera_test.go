@@ -0,0 +1,343 @@
+package camera
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/camera/cameratest"
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	frameRateTeste = 20
+	timeoutTeste   = 10 * time.Second
+)
+
+// jpegTeste é um JPEG mínimo, com o início e o fim da imagem
+var jpegTeste = []byte{0xFF, 0xD8, 0x01, 0x02, 0x03, 0x04, 0xFF, 0xD9}
+
+// recepcao acompanha os frames entregues pela câmera durante o teste
+type recepcao struct {
+	frames chan *image.ImageStruct
+}
+
+// consome lê os frames da câmera até o fim da captura
+func consome(c *Camera) *recepcao {
+	r := &recepcao{frames: make(chan *image.ImageStruct, 1000)}
+	go func() {
+		for f := range c.Frames() {
+			r.frames <- f
+		}
+		close(r.frames)
+	}()
+	return r
+}
+
+// proximo aguarda o próximo frame entregue
+func (r *recepcao) proximo(t *testing.T) *image.ImageStruct {
+	t.Helper()
+	select {
+	case f, ok := <-r.frames:
+		if !ok {
+			t.Fatal("captura encerrada antes do frame")
+		}
+		return f
+	case <-time.After(timeoutTeste):
+		t.Fatal("nenhum frame recebido")
+	}
+	return nil
+}
+
+// descarta remove os frames já entregues
+func (r *recepcao) descarta() {
+	for {
+		select {
+		case <-r.frames:
+		default:
+			return
+		}
+	}
+}
+
+// espera aguarda até que cond seja verdadeira
+func espera(t *testing.T, descricao string, cond func() bool) {
+	t.Helper()
+	limite := time.Now().Add(timeoutTeste)
+	for !cond() {
+		if time.Now().After(limite) {
+			t.Fatal("tempo esgotado aguardando: ", descricao)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// iniciaCamera inicia a captura da câmera simulada
+func iniciaCamera(t *testing.T, srv *cameratest.Server, frameRate int) *Camera {
+	t.Helper()
+	c := New(log.Service("TESTE"), srv.Address(), nil, frameRate, 80)
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCameraFalhas(t *testing.T) {
+	casos := []struct {
+		nome     string
+		falha    func(srv *cameratest.Server)
+		verifica func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao)
+	}{
+		{
+			nome:  "transmissão normal",
+			falha: func(srv *cameratest.Server) {},
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				anterior := r.proximo(t)
+				for i := 0; i < 5; i++ {
+					f := r.proximo(t)
+					if !f.Time.After(anterior.Time) {
+						t.Errorf("timestamp não crescente: %v após %v", f.Time, anterior.Time)
+					}
+					anterior = f
+				}
+				if d := time.Since(anterior.Time); d < -time.Second || d > time.Second {
+					t.Errorf("timestamp distante do horário atual: %v", d)
+				}
+				if h := c.Health(); h.Estado != Transmitindo || h.Reconexoes != 0 || h.Erros != 0 {
+					t.Errorf("saúde inesperada: %+v", h)
+				}
+			},
+		},
+		{
+			nome:  "modo noturno",
+			falha: func(srv *cameratest.Server) { srv.SetNight(true) },
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "frame em modo noturno", func() bool { return r.proximo(t).IsNightMode == 1 })
+			},
+		},
+		{
+			nome:  "travamento reconecta pelo vigia da conexão",
+			falha: func(srv *cameratest.Server) { srv.Stall(blockConnection + time.Second) },
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "estado travada", func() bool { return c.Health().Estado == Travada })
+				espera(t, "reconexão", func() bool { return c.Health().Reconexoes >= 1 && srv.Connections() >= 2 })
+				r.descarta()
+				r.proximo(t)
+			},
+		},
+		{
+			nome:  "parte truncada é descartada",
+			falha: func(srv *cameratest.Server) { srv.Truncate(1) },
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "erro de decodificação", func() bool { return c.Health().Erros >= 1 })
+				for i := 0; i < 5; i++ {
+					if f := r.proximo(t); !bytes.HasSuffix(f.Image, fimJpeg) {
+						t.Fatalf("frame corrompido entregue: %q", f.Image)
+					}
+				}
+				if h := c.Health(); h.Reconexoes != 0 || h.UltimoErro != errIncompleto.Error() {
+					t.Errorf("parte truncada deveria ser descartada sem reconectar: %+v", h)
+				}
+			},
+		},
+		{
+			nome:  "relógio voltando sincroniza novamente",
+			falha: func(srv *cameratest.Server) { srv.JumpClock(-time.Hour) },
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "nova sincronização", func() bool { return srv.TimeRequests() >= 2*timeStartAttempts })
+				r.descarta()
+				f := r.proximo(t)
+				if d := time.Since(f.Time); d < -time.Second || d > time.Second {
+					t.Errorf("timestamp distante do horário atual após sincronizar: %v", d)
+				}
+				if h := c.Health(); h.Reconexoes != 0 {
+					t.Errorf("salto no relógio não deveria reconectar: %+v", h)
+				}
+			},
+		},
+		{
+			nome:  "desconexão",
+			falha: func(srv *cameratest.Server) { srv.Disconnect() },
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "reconexão", func() bool { return c.Health().Reconexoes >= 1 && srv.Connections() >= 2 })
+				r.descarta()
+				r.proximo(t)
+			},
+		},
+		{
+			nome: "câmera lenta fica offline até responder",
+			falha: func(srv *cameratest.Server) {
+				srv.SetDelay(timeoutConnectionSync + 500*time.Millisecond)
+				srv.Disconnect()
+			},
+			verifica: func(t *testing.T, srv *cameratest.Server, c *Camera, r *recepcao) {
+				espera(t, "sincronização sem resposta", func() bool {
+					h := c.Health()
+					return h.Estado == Offline && strings.Contains(h.UltimoErro, "config.cgi")
+				})
+				srv.SetDelay(0)
+				r.descarta()
+				r.proximo(t)
+				espera(t, "estado transmitindo", func() bool { return c.Health().Estado == Transmitindo })
+			},
+		},
+	}
+
+	for _, caso := range casos {
+		caso := caso
+		t.Run(caso.nome, func(t *testing.T) {
+			t.Parallel()
+			srv := cameratest.NewFrames(jpegTeste)
+			defer srv.Close()
+
+			c := iniciaCamera(t, srv, frameRateTeste)
+			defer c.Stop()
+			r := consome(c)
+			r.proximo(t)
+
+			caso.falha(srv)
+			caso.verifica(t, srv, c, r)
+		})
+	}
+}
+
+func TestCameraDescartaSemLeitura(t *testing.T) {
+	srv := cameratest.NewFrames(jpegTeste)
+	defer srv.Close()
+
+	// Sem leitura do canal de frames a captura continua, descartando os frames
+	c := iniciaCamera(t, srv, 100)
+	defer c.Stop()
+	espera(t, "frames descartados", func() bool { return c.Health().Descartados > 0 })
+
+	h := c.Health()
+	if h.Frames != uint64(cap(c.frames)) {
+		t.Errorf("frames entregues = %d, esperado a capacidade do canal %d", h.Frames, cap(c.frames))
+	}
+	if h.Reconexoes != 0 {
+		t.Errorf("descarte não deveria reconectar: %+v", h)
+	}
+}
+
+func TestCameraStop(t *testing.T) {
+	srv := cameratest.NewFrames(jpegTeste)
+	defer srv.Close()
+
+	c := iniciaCamera(t, srv, frameRateTeste)
+	r := consome(c)
+	r.proximo(t)
+
+	c.Stop()
+	espera(t, "canal fechado", func() bool {
+		select {
+		case _, ok := <-r.frames:
+			return !ok
+		default:
+			return false
+		}
+	})
+	if h := c.Health(); h.Estado != Parada {
+		t.Errorf("estado após Stop = %s, esperado %s", h.Estado, Parada)
+	}
+	if err := c.Start(); err != errIniciada {
+		t.Errorf("Start após Stop = %v, esperado %v", err, errIniciada)
+	}
+}
+
+func TestSendFrames(t *testing.T) {
+	srv := cameratest.NewFrames(jpegTeste)
+	defer srv.Close()
+
+	ch := make(ChFrame, 10)
+	c := New(log.Service("TESTE"), srv.Address(), ch, frameRateTeste, 80)
+	c.SendFrames()
+	defer c.Stop()
+
+	select {
+	case img := <-ch:
+		info := c.ExtractComment(img)
+		if _, ok := info["TempoCaptura"]; !ok {
+			t.Errorf("frame sem o comentário da câmera: %v", info)
+		}
+		if !bytes.HasPrefix(img, jpegTeste[:2]) || !bytes.HasSuffix(img, fimJpeg) {
+			t.Errorf("frame corrompido: %X", img)
+		}
+	case <-time.After(timeoutTeste):
+		t.Fatal("nenhum frame recebido")
+	}
+}
+
+func TestSyncTime(t *testing.T) {
+	srv := cameratest.NewFrames(jpegTeste)
+	defer srv.Close()
+	time.Sleep(200 * time.Millisecond)
+
+	c := New(log.Service("TESTE"), srv.Address(), nil, frameRateTeste, 80)
+	if err := c.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+	inicio := c.StartTimestamp
+
+	srv.JumpClock(time.Hour)
+	if err := c.SyncTime(); err != nil {
+		t.Fatal(err)
+	}
+	if d := inicio.Sub(c.StartTimestamp) - time.Hour; d < -100*time.Millisecond || d > 100*time.Millisecond {
+		t.Errorf("início da câmera deslocado em %v após o salto de 1h", inicio.Sub(c.StartTimestamp))
+	}
+
+	srv.Close()
+	if err := c.SyncTime(); err == nil {
+		t.Error("sincronização com a câmera desligada deveria falhar")
+	}
+}
+
+func TestReadHeader(t *testing.T) {
+	casos := []struct {
+		nome    string
+		entrada string
+		tamanho int
+		erro    bool
+	}{
+		{"cabeçalho completo", "--myboundary\r\nContent-Type: image/jpeg\r\nContent-Length: 8\r\nMotion-Event: 1\r\n\r\n", 8, false},
+		{"quebras de linha antes do boundary", "\r\n--myboundary\r\nContent-Type: image/jpeg\r\nContent-Length: 3\r\n\r\n", 3, false},
+		{"tamanho inválido", "--myboundary\r\nContent-Type: image/jpeg\r\nContent-Length: x\r\n\r\n", 0, true},
+		{"campo sem valor", "--myboundary\r\nContent-Type\r\n\r\n", 0, true},
+		{"conexão encerrada", "--myboundary\r\nContent-Type: image/jpeg\r\n", 0, true},
+	}
+	for _, caso := range casos {
+		t.Run(caso.nome, func(t *testing.T) {
+			h, err := readHeader(strings.NewReader(caso.entrada))
+			if caso.erro {
+				if err == nil {
+					t.Errorf("esperado erro, cabeçalho %+v", h)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.contentLength != caso.tamanho || h.contentType != "image/jpeg" {
+				t.Errorf("cabeçalho inesperado: %+v", h)
+			}
+		})
+	}
+}
+
+func TestGetJpeg(t *testing.T) {
+	parte := "--myboundary\r\nContent-Type: image/jpeg\r\nContent-Length: 8\r\n\r\n" + string(jpegTeste) + "\r\n"
+	img, err := getJpeg(strings.NewReader(parte + parte))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(img, jpegTeste) {
+		t.Errorf("imagem = %X, esperado %X", img, jpegTeste)
+	}
+
+	// Parte com menos bytes que o Content-Length e a conexão encerrada
+	if _, err := getJpeg(strings.NewReader(parte[:len(parte)-6])); err == nil {
+		t.Error("parte truncada deveria retornar erro")
+	}
+}
@@ -0,0 +1,303 @@
+// O pacote cameratest implementa uma câmera simulada para testes do pacote
+// camera sem as câmeras da portaria: um servidor HTTP local com os endpoints
+// da API da câmera (mjpegvideo.cgi e config.cgi?TempoLigado), que transmite
+// em loop os JPEGs de um diretório com o segmento de comentário preenchido
+// como na câmera real. As falhas de campo podem ser simuladas durante o
+// teste: travamentos, partes truncadas, saltos no relógio, desconexões e
+// respostas lentas
+package cameratest
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	boundary         = "myboundary"
+	frameRatePadrao  = 10
+	situacaoDia      = 1 // SituacaoDayNight da câmera em modo diurno
+	situacaoNoite    = 2 // SituacaoDayNight da câmera em modo noturno
+	intervaloTravado = 10 * time.Millisecond
+)
+
+var (
+	errSemFrames = errors.New("Nenhum JPEG encontrado no diretório")
+)
+
+// Server representa a câmera simulada
+type Server struct {
+	srv    *httptest.Server
+	frames [][]byte
+
+	mutex      sync.Mutex
+	ligada     time.Time     // horário em que a câmera foi ligada, base do TempoLigado
+	salto      time.Duration // deslocamento do relógio da câmera
+	noturno    bool
+	travar     time.Duration // tempo do próximo travamento da transmissão
+	truncar    int           // quantidade de próximas partes truncadas
+	atraso     time.Duration // atraso de todas as respostas
+	geracao    int           // incrementada a cada desconexão forçada
+	conexoes   int
+	enviados   int
+	sincronias int
+}
+
+// New inicia a câmera simulada que transmite os JPEGs do diretório, na ordem
+// dos nomes dos arquivos
+func New(dir string) (*Server, error) {
+	arquivos, err := filepath.Glob(filepath.Join(dir, "*.jp*g"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(arquivos)
+
+	frames := [][]byte{}
+	for _, a := range arquivos {
+		img, err := ioutil.ReadFile(a)
+		if err != nil {
+			return nil, err
+		}
+		frames = append(frames, img)
+	}
+	if len(frames) == 0 {
+		return nil, errSemFrames
+	}
+	return NewFrames(frames...), nil
+}
+
+// NewFrames inicia a câmera simulada que transmite os JPEGs informados
+func NewFrames(frames ...[]byte) *Server {
+	s := &Server{frames: frames, ligada: time.Now()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/mjpegvideo.cgi", s.video)
+	mux.HandleFunc("/api/config.cgi", s.config)
+	s.srv = httptest.NewServer(mux)
+	return s
+}
+
+// Address retorna o endereço host:porta da câmera, utilizado em camera.New
+func (s *Server) Address() string {
+	return strings.TrimPrefix(s.srv.URL, "http://")
+}
+
+// Close encerra a câmera simulada e as conexões abertas
+func (s *Server) Close() {
+	s.Disconnect()
+	s.srv.CloseClientConnections()
+	s.srv.Close()
+}
+
+// SetNight define se os frames indicam o modo noturno
+func (s *Server) SetNight(noturno bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.noturno = noturno
+}
+
+// Stall trava a transmissão durante d a partir do próximo frame, mantendo a
+// conexão aberta sem enviar dados
+func (s *Server) Stall(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.travar = d
+}
+
+// Truncate envia as próximas n partes com menos bytes que o Content-Length
+func (s *Server) Truncate(n int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.truncar = n
+}
+
+// JumpClock desloca o relógio da câmera em d, alterando o TempoCaptura dos
+// frames e o TempoLigado. Valores negativos simulam o relógio voltando, como
+// no reinício da câmera, limitados ao horário em que foi ligada
+func (s *Server) JumpClock(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.salto += d
+	if ligada := time.Since(s.ligada); ligada+s.salto < 0 {
+		s.salto = -ligada
+	}
+}
+
+// Disconnect encerra as transmissões em andamento. Novas conexões são aceitas
+func (s *Server) Disconnect() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.geracao++
+}
+
+// SetDelay atrasa o início de todas as respostas em d, simulando uma câmera lenta
+func (s *Server) SetDelay(d time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.atraso = d
+}
+
+// Connections retorna a quantidade de conexões de vídeo recebidas
+func (s *Server) Connections() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.conexoes
+}
+
+// FramesSent retorna a quantidade de frames transmitidos
+func (s *Server) FramesSent() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.enviados
+}
+
+// TimeRequests retorna a quantidade de consultas ao TempoLigado
+func (s *Server) TimeRequests() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.sincronias
+}
+
+// tempoLigado retorna o tempo desde que a câmera foi ligada, pelo relógio da câmera
+func (s *Server) tempoLigado() time.Duration {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return time.Since(s.ligada) + s.salto
+}
+
+// aguardaAtraso aplica o atraso configurado às respostas
+func (s *Server) aguardaAtraso() {
+	s.mutex.Lock()
+	atraso := s.atraso
+	s.mutex.Unlock()
+	time.Sleep(atraso)
+}
+
+// config responde à consulta config.cgi?TempoLigado com o tempo em milissegundos
+func (s *Server) config(w http.ResponseWriter, r *http.Request) {
+	s.aguardaAtraso()
+	if _, ok := r.URL.Query()["TempoLigado"]; !ok {
+		http.Error(w, "parâmetro não suportado", http.StatusBadRequest)
+		return
+	}
+
+	s.mutex.Lock()
+	s.sincronias++
+	s.mutex.Unlock()
+	fmt.Fprintf(w, "TempoLigado=%d\r\n", s.tempoLigado()/time.Millisecond)
+}
+
+// video transmite os frames em multipart no ritmo do FrameRate solicitado
+func (s *Server) video(w http.ResponseWriter, r *http.Request) {
+	s.aguardaAtraso()
+
+	frameRate, err := strconv.Atoi(r.URL.Query().Get("FrameRate"))
+	if err != nil || frameRate <= 0 {
+		frameRate = frameRatePadrao
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming não suportado", http.StatusInternalServerError)
+		return
+	}
+
+	s.mutex.Lock()
+	s.conexoes++
+	geracao := s.geracao
+	s.mutex.Unlock()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	intervalo := time.Second / time.Duration(frameRate)
+	for i := 0; ; i++ {
+		if !s.aguarda(r, geracao, intervalo) {
+			return
+		}
+
+		s.mutex.Lock()
+		travar, truncar, noturno := s.travar, s.truncar > 0, s.noturno
+		s.travar = 0
+		if truncar {
+			s.truncar--
+		}
+		s.mutex.Unlock()
+
+		if travar > 0 && !s.aguarda(r, geracao, travar) {
+			return
+		}
+
+		situacao := situacaoDia
+		if noturno {
+			situacao = situacaoNoite
+		}
+		img := comComentario(s.frames[i%len(s.frames)],
+			fmt.Sprintf("TempoCaptura=%d;SituacaoDayNight=%d;", s.tempoLigado()/time.Millisecond, situacao))
+
+		fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\nMotion-Event: 0\r\n\r\n",
+			boundary, len(img))
+		if truncar {
+			img = img[:len(img)/2]
+		}
+		if _, err := w.Write(img); err != nil {
+			return
+		}
+		fmt.Fprint(w, "\r\n")
+		flusher.Flush()
+
+		s.mutex.Lock()
+		s.enviados++
+		s.mutex.Unlock()
+	}
+}
+
+// aguarda espera d, retornando false caso o cliente desconecte ou a
+// transmissão seja encerrada por Disconnect
+func (s *Server) aguarda(r *http.Request, geracao int, d time.Duration) bool {
+	fim := time.Now().Add(d)
+	for {
+		s.mutex.Lock()
+		desconectada := s.geracao != geracao
+		s.mutex.Unlock()
+		if desconectada {
+			return false
+		}
+
+		restante := time.Until(fim)
+		if restante <= 0 {
+			return true
+		}
+		if restante > intervaloTravado {
+			restante = intervaloTravado
+		}
+		select {
+		case <-r.Context().Done():
+			return false
+		case <-time.After(restante):
+		}
+	}
+}
+
+// comComentario retorna o JPEG com o segmento de comentário (COM) inserido
+// logo após o início da imagem, como nos frames da câmera
+func comComentario(img []byte, texto string) []byte {
+	if len(img) < 2 {
+		return img
+	}
+	tamanho := len(texto) + 2
+	com := append([]byte{0xFF, 0xFE, byte(tamanho >> 8), byte(tamanho)}, texto...)
+
+	saida := make([]byte, 0, len(img)+len(com))
+	saida = append(saida, img[:2]...)
+	saida = append(saida, com...)
+	return append(saida, img[2:]...)
+}
@@ -0,0 +1,234 @@
+package camera
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+)
+
+const (
+	comandoFFmpeg = "ffmpeg"
+	esperaPts     = time.Second // espera pelo tempo de apresentação informado pelo showinfo
+)
+
+var (
+	// rePts extrai o tempo de apresentação das linhas do filtro showinfo
+	rePts = regexp.MustCompile(`pts_time:\s*(-?[0-9.]+)`)
+
+	errFimVideo = errors.New("Vídeo encerrado pelo ffmpeg")
+)
+
+// ffmpeg executa o ffmpeg com os argumentos e entrega os JPEGs escritos na
+// saída padrão até o fim do processo, o encerramento da captura ou o retorno
+// false de envia. Com pts, os argumentos devem incluir o filtro showinfo e
+// cada JPEG é entregue com o seu tempo de apresentação, em segundos (-1
+// quando não informado). O processo é encerrado caso nenhum JPEG seja
+// recebido durante inatividade. Retorna nil ao fim normal do vídeo
+func (b *base) ffmpeg(args []string, pts bool, inatividade time.Duration, envia func(img []byte, pts float64) bool) error {
+	cmd := exec.Command(comandoFFmpeg, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	// Lê o log do ffmpeg: os tempos de apresentação e a última mensagem de erro
+	tempos := make(chan float64, defaults.BufferChannel)
+	var mutex sync.Mutex
+	var ultima string
+	go func() {
+		defer close(tempos)
+		s := bufio.NewScanner(stderr)
+		for s.Scan() {
+			linha := strings.TrimSpace(s.Text())
+			if m := rePts.FindStringSubmatch(linha); m != nil {
+				if pts {
+					v, _ := strconv.ParseFloat(m[1], 64)
+					tempos <- v
+				}
+				continue
+			}
+			if linha != "" {
+				mutex.Lock()
+				ultima = linha
+				mutex.Unlock()
+			}
+		}
+	}()
+
+	encerra := func() { cmd.Process.Kill() }
+	vigia := time.AfterFunc(inatividade, encerra)
+	fim := make(chan struct{})
+	go func() {
+		select {
+		case <-b.stop:
+			encerra()
+		case <-fim:
+		}
+	}()
+
+	r := bufio.NewReaderSize(stdout, 1<<16)
+	for {
+		img, err := proximoJpeg(r)
+		if err != nil {
+			if err != io.EOF && err != io.ErrUnexpectedEOF {
+				b.erro(err)
+				encerra()
+			}
+			break
+		}
+		vigia.Stop()
+
+		p := -1.0
+		if pts {
+			select {
+			case v, ok := <-tempos:
+				if ok {
+					p = v
+				}
+			case <-time.After(esperaPts):
+			}
+		}
+		if !envia(img, p) {
+			encerra()
+			break
+		}
+		vigia.Reset(inatividade)
+	}
+	vigia.Stop()
+	close(fim)
+
+	// A saída deve ser consumida até o fim antes de aguardar o processo
+	io.Copy(ioutil.Discard, stdout)
+	for range tempos {
+	}
+	err = cmd.Wait()
+
+	select {
+	case <-b.stop:
+		return nil
+	default:
+	}
+	if err != nil {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if ultima != "" {
+			return fmt.Errorf("ffmpeg: %v: %s", err, ultima)
+		}
+		return fmt.Errorf("ffmpeg: %v", err)
+	}
+	return nil
+}
+
+// qualidadeFFmpeg converte a qualidade da imagem, de 1 a 100, na escala do
+// codificador mjpeg do ffmpeg, de 31 (pior) a 2 (melhor)
+func qualidadeFFmpeg(qualidade int) string {
+	if qualidade <= 0 || qualidade > 100 {
+		qualidade = 100
+	}
+	return strconv.Itoa(2 + (100-qualidade)*29/100)
+}
+
+// proximoJpeg lê o próximo JPEG baseline de r, do início (SOI) ao fim (EOI)
+// da imagem, percorrendo os segmentos do cabeçalho pelo tamanho para que
+// bytes 0xFFD9 das tabelas não sejam confundidos com o fim da imagem
+func proximoJpeg(r *bufio.Reader) ([]byte, error) {
+	// Procura o início da imagem
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c != 0xFF {
+			continue
+		}
+		c, err = r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c == 0xD8 {
+			break
+		}
+		r.UnreadByte()
+	}
+	img := []byte{0xFF, 0xD8}
+
+	// Segmentos do cabeçalho até o início dos dados comprimidos (SOS)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if c != 0xFF {
+			return nil, errors.New("JPEG inválido: marcador esperado")
+		}
+		marcador, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		if marcador == 0xFF {
+			// Bytes de preenchimento antes do marcador
+			r.UnreadByte()
+			continue
+		}
+		img = append(img, 0xFF, marcador)
+		if marcador == 0xD9 {
+			return img, nil
+		}
+
+		tamanho := make([]byte, 2)
+		if _, err := io.ReadFull(r, tamanho); err != nil {
+			return nil, err
+		}
+		n := (int(tamanho[0])<<8 | int(tamanho[1])) - 2
+		if n < 0 {
+			return nil, errors.New("JPEG inválido: segmento com tamanho inválido")
+		}
+		segmento := make([]byte, n)
+		if _, err := io.ReadFull(r, segmento); err != nil {
+			return nil, err
+		}
+		img = append(img, tamanho...)
+		img = append(img, segmento...)
+		if marcador == 0xDA {
+			break
+		}
+	}
+
+	// Dados comprimidos até o fim da imagem. Nos dados, 0xFF é seguido de
+	// 0x00 ou de um marcador de reinício
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		img = append(img, c)
+		if c != 0xFF {
+			continue
+		}
+		c, err = r.ReadByte()
+		if err != nil {
+			return nil, err
+		}
+		img = append(img, c)
+		if c == 0xD9 {
+			return img, nil
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package camera
+
+import (
+	"sync"
+
+	"github.com/gustavolimam/control-access/src/components/image"
+)
+
+// Relay distribui os frames de uma câmera aos visualizadores ao vivo a partir
+// da única conexão com a câmera. Cada visualizador recebe apenas o frame mais
+// recente, de forma que um navegador lento perde frames sem atrasar a captura
+// nem os demais visualizadores
+type Relay struct {
+	mutex          sync.Mutex
+	ultimo         *image.ImageStruct
+	visualizadores map[chan *image.ImageStruct]struct{}
+	encerrado      bool
+}
+
+// NewRelay cria a distribuição dos frames de uma câmera
+func NewRelay() *Relay {
+	return &Relay{visualizadores: map[chan *image.ImageStruct]struct{}{}}
+}
+
+// Publish guarda o frame como o mais recente e o envia aos visualizadores,
+// substituindo o frame ainda não lido por cada um
+func (r *Relay) Publish(img *image.ImageStruct) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.ultimo = img
+	for ch := range r.visualizadores {
+		select {
+		case <-ch:
+		default:
+		}
+		ch <- img
+	}
+}
+
+// Last retorna o frame mais recente da câmera, ou nil caso nenhum tenha sido recebido
+func (r *Relay) Last() *image.ImageStruct {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.ultimo
+}
+
+// Subscribe registra um novo visualizador. O canal é fechado quando a
+// captura da câmera é encerrada e cancela remove o visualizador
+func (r *Relay) Subscribe() (frames <-chan *image.ImageStruct, cancela func()) {
+	ch := make(chan *image.ImageStruct, 1)
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.encerrado {
+		close(ch)
+		return ch, func() {}
+	}
+	r.visualizadores[ch] = struct{}{}
+
+	return ch, func() {
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		if _, ok := r.visualizadores[ch]; ok {
+			delete(r.visualizadores, ch)
+			close(ch)
+		}
+	}
+}
+
+// Viewers retorna a quantidade de visualizadores conectados
+func (r *Relay) Viewers() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.visualizadores)
+}
+
+// Close encerra a distribuição ao fim da captura, fechando os canais dos visualizadores
+func (r *Relay) Close() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.encerrado = true
+	for ch := range r.visualizadores {
+		delete(r.visualizadores, ch)
+		close(ch)
+	}
+}
@@ -0,0 +1,196 @@
+package camera
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	// LayoutReplay é o formato do horário no início do nome dos arquivos de
+	// replay, como 20191018-153012.250.jpg. Arquivos sem o horário no nome
+	// utilizam o horário de modificação
+	LayoutReplay = "20060102-150405.000"
+)
+
+// ReplaySource reproduz um diretório de JPEGs ou um arquivo de vídeo gravado,
+// entregando os frames com os timestamps originais e no intervalo original
+// entre eles, para reproduzir ocorrências do campo fora da portaria
+type ReplaySource struct {
+	base
+	caminho    string
+	velocidade float64
+	repetir    bool
+}
+
+// quadro representa um JPEG do diretório de replay
+type quadro struct {
+	arquivo string
+	tempo   time.Time
+}
+
+// NewReplay cria a origem de frames que reproduz o diretório ou o arquivo de
+// vídeo do caminho. velocidade multiplica o ritmo original (0 utiliza o
+// ritmo original) e repetir reinicia a reprodução ao fim
+func NewReplay(logService log.Service, caminho string, velocidade float64, repetir bool) *ReplaySource {
+	log.Log(logService, "Novo replay instanciado: ", caminho)
+
+	if velocidade <= 0 {
+		velocidade = 1
+	}
+	s := &ReplaySource{caminho: caminho, velocidade: velocidade, repetir: repetir}
+	// A gravação não é ao vivo, os intervalos entre os frames são os originais
+	s.prepara(logService, 0)
+	return s
+}
+
+// Start inicia a reprodução
+func (s *ReplaySource) Start() error {
+	info, err := os.Stat(s.caminho)
+	if err != nil {
+		return err
+	}
+	if err := s.inicia(); err != nil {
+		return err
+	}
+	go s.reproduz(info.IsDir())
+	return nil
+}
+
+// reproduz entrega os frames do replay até o fim, ou indefinidamente caso
+// configurado para repetir
+func (s *ReplaySource) reproduz(diretorio bool) {
+	defer s.encerra()
+
+	for {
+		var err error
+		if diretorio {
+			err = s.reproduzDiretorio()
+		} else {
+			err = s.reproduzVideo()
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if err != nil {
+			log.Log(s.logService, "Falha no replay de ", s.caminho, ": ", err)
+			s.erro(err)
+			return
+		}
+		if !s.repetir {
+			log.Log(s.logService, "Replay de ", s.caminho, " concluído")
+			return
+		}
+	}
+}
+
+// reproduzDiretorio entrega os JPEGs do diretório na ordem dos horários
+func (s *ReplaySource) reproduzDiretorio() error {
+	quadros, err := s.quadros()
+	if err != nil {
+		return err
+	}
+
+	ritmo := s.ritmo()
+	for _, q := range quadros {
+		img, err := ioutil.ReadFile(q.arquivo)
+		if err != nil {
+			s.erro(err)
+			continue
+		}
+		if !ritmo(q.tempo) {
+			return nil
+		}
+		f := &image.ImageStruct{Image: img, Time: q.tempo, IsNightMode: modoNoturno(comentario(img))}
+		if !s.entrega(f, true) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// quadros retorna os JPEGs do diretório ordenados pelo horário
+func (s *ReplaySource) quadros() ([]quadro, error) {
+	arquivos, err := ioutil.ReadDir(s.caminho)
+	if err != nil {
+		return nil, err
+	}
+
+	quadros := []quadro{}
+	for _, a := range arquivos {
+		ext := strings.ToLower(filepath.Ext(a.Name()))
+		if a.IsDir() || (ext != ".jpg" && ext != ".jpeg") {
+			continue
+		}
+		quadros = append(quadros, quadro{
+			arquivo: filepath.Join(s.caminho, a.Name()),
+			tempo:   horarioArquivo(a),
+		})
+	}
+	sort.SliceStable(quadros, func(i, j int) bool {
+		return quadros[i].tempo.Before(quadros[j].tempo)
+	})
+	return quadros, nil
+}
+
+// reproduzVideo entrega os frames do arquivo de vídeo decodificados pelo
+// ffmpeg, com o horário de início do arquivo somado ao tempo de apresentação
+func (s *ReplaySource) reproduzVideo() error {
+	info, err := os.Stat(s.caminho)
+	if err != nil {
+		return err
+	}
+	inicio := horarioArquivo(info)
+
+	args := []string{"-nostdin", "-loglevel", "info", "-i", s.caminho,
+		"-an", "-vsync", "0", "-f", "image2pipe", "-c:v", "mjpeg", "-q:v", qualidadeFFmpeg(100), "-vf", "showinfo", "-"}
+
+	ritmo := s.ritmo()
+	var ultimo time.Time
+	return s.ffmpeg(args, true, timeoutQuadroChave, func(img []byte, pts float64) bool {
+		t := ultimo
+		if pts >= 0 {
+			t = inicio.Add(time.Duration(pts * float64(time.Second)))
+		}
+		ultimo = t
+		return ritmo(t) && s.entrega(&image.ImageStruct{Image: img, Time: t}, true)
+	})
+}
+
+// ritmo retorna a função que aguarda o momento de entregar o frame do
+// horário t, mantendo o intervalo original entre os frames dividido pela
+// velocidade. A função retorna false caso a captura seja encerrada
+func (s *ReplaySource) ritmo() func(t time.Time) bool {
+	inicio := time.Now()
+	var primeiro time.Time
+	return func(t time.Time) bool {
+		if primeiro.IsZero() {
+			primeiro = t
+		}
+		espera := time.Duration(float64(t.Sub(primeiro))/s.velocidade) - time.Since(inicio)
+		if espera <= 0 {
+			return true
+		}
+		return s.aguarda(espera)
+	}
+}
+
+// horarioArquivo retorna o horário do início do nome do arquivo, no formato
+// LayoutReplay, ou o horário de modificação do arquivo
+func horarioArquivo(info os.FileInfo) time.Time {
+	if nome := info.Name(); len(nome) >= len(LayoutReplay) {
+		if t, err := time.ParseInLocation(LayoutReplay, nome[:len(LayoutReplay)], time.Local); err == nil {
+			return t
+		}
+	}
+	return info.ModTime()
+}
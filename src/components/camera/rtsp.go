@@ -0,0 +1,98 @@
+package camera
+
+import (
+	"net/url"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	timeoutQuadroChave = 10 * time.Second // tempo máximo sem quadros-chave antes da reconexão
+)
+
+// RTSPSource captura o vídeo H.264 de uma câmera IP genérica por RTSP. Apenas
+// os quadros-chave são decodificados, convertidos em JPEG pelo ffmpeg, e
+// entregues com o horário de recebimento, já que o protocolo não informa o
+// relógio da câmera
+type RTSPSource struct {
+	base
+	url       string
+	frameRate int
+	quality   int
+}
+
+// NewRTSP cria a origem de frames RTSP, como rtsp://usuario:senha@ip/stream.
+// frameRate limita os frames entregues por segundo (0 entrega todos os
+// quadros-chave) e quality define a qualidade do JPEG, de 1 a 100
+func NewRTSP(logService log.Service, endereco string, frameRate, quality int) *RTSPSource {
+	log.Log(logService, "Nova camera RTSP instanciada: ", semSenha(endereco))
+
+	s := &RTSPSource{url: endereco, frameRate: frameRate, quality: quality}
+	// Apenas os quadros-chave são entregues, em intervalos de alguns segundos
+	s.prepara(logService, timeoutQuadroChave/2)
+	return s
+}
+
+// Start inicia a captura do vídeo
+func (s *RTSPSource) Start() error {
+	if err := s.inicia(); err != nil {
+		return err
+	}
+	go s.captura()
+	return nil
+}
+
+// captura executa o ffmpeg até a captura ser encerrada, reconectando quando
+// o vídeo é interrompido
+func (s *RTSPSource) captura() {
+	defer s.encerra()
+
+	args := []string{"-nostdin", "-loglevel", "error",
+		"-rtsp_transport", "tcp", "-skip_frame", "nokey", "-i", s.url,
+		"-an", "-vsync", "0", "-f", "image2pipe", "-c:v", "mjpeg", "-q:v", qualidadeFFmpeg(s.quality), "-"}
+
+	var intervalo time.Duration
+	if s.frameRate > 0 {
+		intervalo = time.Second / time.Duration(s.frameRate)
+	}
+	var ultimo time.Time
+
+	for tentativa := 0; ; tentativa++ {
+		if tentativa > 0 && !s.aguarda(sleepRetryConection) {
+			return
+		}
+
+		s.estado(Conectando)
+		err := s.ffmpeg(args, false, timeoutQuadroChave, func(img []byte, _ float64) bool {
+			agora := time.Now()
+			if agora.Sub(ultimo) < intervalo {
+				return true
+			}
+			ultimo = agora
+			return s.entrega(&image.ImageStruct{Image: img, Time: agora}, false)
+		})
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		if err == nil {
+			err = errFimVideo
+		}
+		log.Log(s.logService, "Falha no vídeo RTSP da câmera ", semSenha(s.url), ": ", err)
+		s.reconexao(err)
+	}
+}
+
+// semSenha retorna o endereço sem a senha, para registro no log
+func semSenha(endereco string) string {
+	u, err := url.Parse(endereco)
+	if err != nil || u.User == nil {
+		return endereco
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
@@ -0,0 +1,297 @@
+package camera
+
+import (
+	"bytes"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+// Tipos de origem de frames configuráveis
+const (
+	TipoVendor = "vendor" // câmera com a API mjpegvideo.cgi/config.cgi
+	TipoRTSP   = "rtsp"   // câmera IP genérica com vídeo H.264 por RTSP
+	TipoReplay = "replay" // diretório de JPEGs ou arquivo de vídeo gravado
+)
+
+// Estado representa a situação da conexão com a câmera
+type Estado string
+
+// Estados possíveis de uma origem de frames
+const (
+	Parada       Estado = "parada"        // não iniciada, encerrada ou replay concluído
+	Conectando   Estado = "conectando"    // conectando ou reconectando à câmera
+	Transmitindo Estado = "transmitindo"  // recebendo frames
+	Travada      Estado = "travada"       // conectada, mas sem receber frames
+	SemSincronia Estado = "sem-sincronia" // relógio da câmera não sincronizado
+	Offline      Estado = "offline"       // falha na conexão, aguardando nova tentativa
+)
+
+const (
+	intervaloFPS     = 5 * time.Second // janela da medição dos frames por segundo
+	travadaMinima    = time.Second     // tempo mínimo sem frames para considerar a câmera travada
+	framesSemEntrega = 3               // frames esperados sem entrega para considerar a câmera travada
+)
+
+var (
+	errIniciada     = errors.New("Origem de frames já iniciada")
+	errTipoInvalido = errors.New("Tipo de câmera inválido, utilize vendor, rtsp ou replay")
+	errIncompleto   = errors.New("Frame JPEG incompleto, sem o marcador de fim da imagem")
+)
+
+// Health representa a saúde de uma origem de frames
+type Health struct {
+	Estado      Estado    `json:"estado"`
+	Inicio      time.Time `json:"inicio"`      // horário de início da captura
+	UltimoFrame time.Time `json:"ultimoFrame"` // horário de recebimento do último frame
+	FPS         float64   `json:"fps"`         // frames entregues por segundo, medidos nos últimos segundos
+	Frames      uint64    `json:"frames"`      // frames entregues desde o início
+	Descartados uint64    `json:"descartados"` // frames descartados por falta de leitura do canal
+	Reconexoes  int       `json:"reconexoes"`
+	Erros       int       `json:"erros"` // falhas na decodificação do vídeo
+	UltimoErro  string    `json:"ultimoErro,omitempty"`
+}
+
+// FrameSource representa uma origem de frames JPEG de uma câmera
+type FrameSource interface {
+	// Start inicia a captura dos frames em segundo plano
+	Start() error
+	// Stop encerra a captura. O canal de frames é fechado em seguida
+	Stop()
+	// Frames retorna o canal em que os frames capturados são entregues
+	Frames() <-chan *image.ImageStruct
+	// Health retorna a saúde da origem
+	Health() Health
+}
+
+// NewSource cria a origem de frames descrita na configuração da câmera
+func NewSource(nome log.Service, cfg config.CamCfg) (FrameSource, error) {
+	switch cfg.Tipo {
+	case TipoVendor, "":
+		return New(nome, cfg.Address, nil, cfg.FrameRate, cfg.ImgQuality), nil
+	case TipoRTSP:
+		return NewRTSP(nome, cfg.Address, cfg.FrameRate, cfg.ImgQuality), nil
+	case TipoReplay:
+		return NewReplay(nome, cfg.Address, cfg.Velocidade, cfg.Repetir), nil
+	}
+	return nil, errTipoInvalido
+}
+
+// base implementa o canal de frames, o início, o encerramento e a saúde
+// comuns às origens de frames
+type base struct {
+	logService log.Service
+	frames     chan *image.ImageStruct
+	stop       chan struct{}
+
+	// Tempo sem frames, após a conexão, em que a origem é considerada travada
+	// (0 não verifica)
+	limiteTravada time.Duration
+
+	mutex        sync.Mutex
+	iniciada     bool
+	parada       bool
+	saude        Health
+	conexao      time.Time // horário da última conexão com a câmera
+	janelaInicio time.Time // início da janela de medição dos frames por segundo
+	janelaFrames uint64    // frames entregues até o início da janela
+}
+
+// prepara inicializa a base de uma origem de frames
+func (b *base) prepara(logService log.Service, limiteTravada time.Duration) {
+	b.logService = logService
+	b.limiteTravada = limiteTravada
+	b.frames = make(chan *image.ImageStruct, defaults.BufferChannel)
+	b.stop = make(chan struct{})
+	b.saude = Health{Estado: Parada}
+}
+
+// Frames retorna o canal em que os frames capturados são entregues
+func (b *base) Frames() <-chan *image.ImageStruct {
+	return b.frames
+}
+
+// Health retorna a saúde da origem. Uma origem transmitindo sem entregar
+// frames por mais de limiteTravada é informada como travada
+func (b *base) Health() Health {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	h := b.saude
+	ultimo := h.UltimoFrame
+	if b.conexao.After(ultimo) {
+		ultimo = b.conexao
+	}
+	if h.Estado == Transmitindo && b.limiteTravada > 0 && time.Since(ultimo) > b.limiteTravada {
+		h.Estado = Travada
+	}
+	if h.Estado != Transmitindo || time.Since(h.UltimoFrame) > intervaloFPS {
+		h.FPS = 0
+	}
+	return h
+}
+
+// Stop encerra a captura
+func (b *base) Stop() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if !b.parada {
+		b.parada = true
+		close(b.stop)
+	}
+}
+
+// inicia marca a origem como iniciada. Uma origem só pode ser iniciada uma vez
+func (b *base) inicia() error {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.iniciada || b.parada {
+		return errIniciada
+	}
+	b.iniciada = true
+	b.saude.Estado = Conectando
+	b.saude.Inicio = time.Now()
+	return nil
+}
+
+// encerra fecha o canal de frames ao fim da captura
+func (b *base) encerra() {
+	b.estado(Parada)
+	close(b.frames)
+}
+
+// estado atualiza o estado da origem
+func (b *base) estado(e Estado) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.saude.Estado = e
+}
+
+// conectada registra a conexão com a câmera. A partir da conexão a origem é
+// considerada transmitindo, até que fique travada sem entregar frames
+func (b *base) conectada() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.saude.Estado = Transmitindo
+	b.conexao = time.Now()
+}
+
+// reconexao registra uma nova tentativa de conexão após a falha err
+func (b *base) reconexao(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.saude.Estado = Offline
+	b.saude.Reconexoes++
+	if err != nil {
+		b.saude.UltimoErro = err.Error()
+	}
+}
+
+// falha registra o estado da origem após a falha err, sem nova conexão
+func (b *base) falha(e Estado, err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.saude.Estado = e
+	b.saude.UltimoErro = err.Error()
+}
+
+// erro registra uma falha na decodificação do vídeo
+func (b *base) erro(err error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.saude.Erros++
+	b.saude.UltimoErro = err.Error()
+}
+
+// entrega envia o frame no canal. Origens ao vivo não aguardam a leitura do
+// canal, descartando o frame caso esteja cheio, para não atrasar a conexão
+// com a câmera. Retorna false caso a captura tenha sido encerrada
+func (b *base) entrega(f *image.ImageStruct, aguarda bool) bool {
+	if aguarda {
+		select {
+		case b.frames <- f:
+		case <-b.stop:
+			return false
+		}
+	} else {
+		select {
+		case b.frames <- f:
+		default:
+			b.mutex.Lock()
+			b.saude.Descartados++
+			b.mutex.Unlock()
+			return true
+		}
+	}
+
+	agora := time.Now()
+	b.mutex.Lock()
+	b.saude.Estado = Transmitindo
+	b.saude.UltimoFrame = agora
+	b.saude.Frames++
+	if janela := agora.Sub(b.janelaInicio); janela >= intervaloFPS {
+		if !b.janelaInicio.IsZero() {
+			b.saude.FPS = float64(b.saude.Frames-b.janelaFrames) / janela.Seconds()
+		}
+		b.janelaInicio = agora
+		b.janelaFrames = b.saude.Frames
+	}
+	b.mutex.Unlock()
+	return true
+}
+
+// limiteSemFrames retorna o tempo sem frames em que uma câmera com o
+// frameRate informado é considerada travada
+func limiteSemFrames(frameRate int) time.Duration {
+	if frameRate <= 0 {
+		return travadaMinima
+	}
+	limite := framesSemEntrega * time.Second / time.Duration(frameRate)
+	if limite < travadaMinima {
+		return travadaMinima
+	}
+	return limite
+}
+
+// aguarda espera a duração d. Retorna false caso a captura seja encerrada antes
+func (b *base) aguarda(d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return true
+	case <-b.stop:
+		return false
+	}
+}
+
+// comentario retorna os campos do segmento de comentário (COM) do JPEG, no
+// formato Campo=valor;Campo=valor
+func comentario(img []byte) map[string]string {
+	data := map[string]string{}
+	ini := bytes.Index(img, []byte{0xFF, 0xFE})
+	if ini >= 0 {
+		for _, campo := range strings.Split(string(img[ini+4:]), ";") {
+			par := strings.Split(campo, "=")
+			if len(par) == 2 {
+				data[par[0]] = par[1]
+			}
+		}
+	}
+	return data
+}
+
+// modoNoturno retorna 1 caso o comentário do JPEG indique o modo noturno
+func modoNoturno(info map[string]string) int {
+	if situacao, _ := strconv.ParseUint(strings.TrimSpace(info["SituacaoDayNight"]), 10, 64); situacao == 2 {
+		return 1
+	}
+	return 0
+}
@@ -12,32 +12,130 @@ import (
 // Config representa a configuração do serviço
 var (
 	Config SysConfig
+	loaded bool
+
+	observadores []func(antes, depois SysConfig)
 )
 
 // SysConfig define a estrutura de configuração do serviço
 type SysConfig struct {
-	Camera  CamCfg
-	Jidosha CfgJidosha
-	Path    PathConfig
+	Portarias     []PortariaConfig
+	Monitoramento MonitoramentoConfig
+	Jidosha       CfgJidosha
+	Path          PathConfig
+	Storage       StorageConfig
+	Visitas       VisitasConfig
+	Eventos       EventosConfig
+	Cancelas      []CancelaConfig
+	Web           WebConfig
+	Alertas       AlertasConfig
+	Plate         PlateConfig
+}
+
+// PlateConfig define a estrutura de configuração do pareamento de leituras da mesma placa
+type PlateConfig struct {
+	PlateBufferSeconds float64 // Intervalo em que leituras da mesma placa são consideradas repetidas, em segundos
+	ConfiancaMinima    float64 // Confiança mínima para parear leituras diferentes da mesma placa (padrão 0.8)
+	ConfiancaRevisao   float64 // Pareamentos abaixo desta confiança são marcados para revisão (padrão 0.95)
+	JanelaPareamento   int     // Distância no tempo em que a confiança do pareamento atinge a redução máxima, em minutos (0 desconsidera o tempo)
+}
+
+// AlertasConfig define a estrutura de configuração dos alertas da lista de observação
+type AlertasConfig struct {
+	Similaridade     float64  // Similaridade mínima entre a leitura e a placa observada, entre 0 e 1 (padrão 0.9)
+	SeveridadeMinima string   // Severidade mínima encaminhada por webhook e e-mail: baixa, media, alta ou critica (padrão alta)
+	Webhook          string   // URL que recebe os alertas por POST em JSON (vazia desabilita)
+	SMTP             string   // Relay SMTP local, host:porta (vazio desabilita o e-mail)
+	Remetente        string   // Endereço de origem dos e-mails de alerta
+	Destinatarios    []string // Endereços que recebem os alertas por e-mail
+}
+
+// WebConfig define a estrutura de configuração do serviço web
+type WebConfig struct {
+	Origens       []string // Origens autorizadas a acessar a API pelo navegador (CORS)
+	DuracaoSessao int      // Duração da sessão do usuário, em minutos
+	ChaveSessao   string   // Chave de assinatura das sessões (vazia gera e persiste uma chave no banco)
+	SenhaAdmin    string   // Senha inicial do usuário admin (vazia gera uma senha aleatória no log)
+	FPSAoVivo     int      // Frames por segundo máximos enviados a cada operador na visualização ao vivo (padrão 5)
+}
+
+// CancelaConfig define a estrutura de configuração do acionador da cancela de uma portaria
+type CancelaConfig struct {
+	Portaria  string // Nome da portaria (EventoVeiculo.Portaria)
+	Tipo      string // Acionador: "http", "modbus" ou "log"
+	Endereco  string // URL base (http) ou host:porta (modbus)
+	Pulso     int    // Duração do pulso do relé, em milissegundos
+	Cooldown  int    // Tempo mínimo entre acionamentos para o mesmo evento, em segundos
+	Confirmar int    // Tempo máximo de espera pela confirmação de abertura, em milissegundos (0 não confirma)
+	URLAbrir  string // Caminho para ligar o relé (http)
+	URLFechar string // Caminho para desligar o relé (http)
+	URLEstado string // Caminho para consultar o estado da cancela (http)
+	Aberta    string // Texto da resposta de URLEstado que indica cancela aberta (http)
+	UnitID    int    // Identificador do escravo modbus
+	Coil      int    // Endereço da bobina do relé (modbus)
+	Sensor    int    // Endereço da entrada discreta do sensor de cancela aberta (modbus, -1 sem sensor)
+}
+
+// EventosConfig define a estrutura de configuração do serviço de eventos
+type EventosConfig struct {
+	Modo            string // Origem dos eventos: "camera" (padrão) ou "demo" para eventos simulados
+	TimeoutOperador int    // Tempo de espera pela decisão do operador, em segundos (padrão 60)
+}
+
+// VisitasConfig define a estrutura de configuração das visitas
+type VisitasConfig struct {
+	PermanenciaMaxima int // Tempo máximo de permanência no campus, em minutos (0 desabilita)
+}
+
+// StorageConfig define a estrutura de configuração do banco de dados
+type StorageConfig struct {
+	Driver          string // Banco utilizado: "firestore" ou "local"
+	CredentialsFile string // Arquivo de credenciais do Firebase (driver firestore)
+	LocalPath       string // Arquivo do banco embarcado (driver local)
 }
 
 // PathConfig define a estrutura de configuração dos diretórios
 type PathConfig struct {
 	FinalPackage string // Caminho para armazenar arquivos .xml e as imagens zoom e pan
 	LogPath      string // Caminho para armazenar .txt de logs
+	Outbox       string // Caminho da fila de eventos pendentes de envio ao banco
+	Audit        string // Caminho do registro de auditoria
 }
 
-// CfgJidosha define a estrutura de configuração do jidosha
+// CfgJidosha define a estrutura de configuração do reconhecimento de placas
 type CfgJidosha struct {
-	Timeout    int
-	NumThreads int
+	Tipo       string   // Motor de reconhecimento: "comando", "http" ou "fake"
+	Comando    string   // Executável que recebe o JPEG na entrada padrão e responde em JSON (comando)
+	Argumentos []string // Argumentos do executável (comando)
+	Endereco   string   // URL do serviço que recebe o JPEG por POST e responde em JSON (http)
+	Timeout    int      // Tempo máximo de cada reconhecimento, em milissegundos (padrão 2000)
+	NumThreads int      // Quantidade de reconhecimentos simultâneos (padrão 1)
+	Fila       int      // Frames aguardando reconhecimento (padrão 32)
+	Descarte   string   // Com a fila cheia: "antigo" descarta o frame mais antigo (padrão), "novo" o recebido e "bloqueia" aguarda
+}
+
+// PortariaConfig define a estrutura de configuração de uma portaria e das suas câmeras
+type PortariaConfig struct {
+	Nome    string   // Nome da portaria (EventoVeiculo.Portaria)
+	Sentido string   // Sentido das passagens: "entrada", "saida" ou "ambos" (pela ocupação do campus)
+	Cameras []CamCfg // Câmeras da portaria
+}
+
+// MonitoramentoConfig define a estrutura de configuração do monitoramento das câmeras
+type MonitoramentoConfig struct {
+	AlarmeSemFrames int // Tempo sem frames de uma câmera offline ou travada antes do alarme, em segundos (padrão 60)
 }
 
 // CamCfg define a estrutura de configuração de uma camera
 type CamCfg struct {
-	Address    string // Ip para conexão
-	FrameRate  int
-	ImgQuality int
+	ID         string  // Identificador da câmera (padrão portaria-funcao-n)
+	Funcao     string  // Função da câmera: "placa" (zoom) ou "panoramica"
+	Tipo       string  // Origem dos frames: "vendor" (padrão), "rtsp" ou "replay"
+	Address    string  // Ip para conexão (vendor), URL rtsp:// (rtsp) ou diretório de JPEGs/arquivo de vídeo (replay)
+	FrameRate  int     // Frames por segundo solicitados à câmera (vendor) ou entregues (rtsp)
+	ImgQuality int     // Qualidade do JPEG, de 1 a 100
+	Velocidade float64 // Multiplicador do ritmo original da gravação (replay, padrão 1)
+	Repetir    bool    // Reinicia a gravação ao fim (replay)
 }
 
 // SetupConfig salva em memória as configurações do serviço
@@ -50,11 +148,32 @@ func SetupConfig() error {
 	if err != nil {
 		return err
 	}
-	if err := json.Unmarshal(file, &Config); err != nil {
+	var novo SysConfig
+	if err := json.Unmarshal(file, &novo); err != nil {
 		return err
 	}
+	antes := Config
+	Config = novo
+	loaded = true
+
+	if err := setupPaths(); err != nil {
+		return err
+	}
+	for _, f := range observadores {
+		f(antes, Config)
+	}
+	return nil
+}
+
+// OnLoad registra uma função chamada a cada carga do arquivo de configuração,
+// recebendo a configuração anterior e a nova
+func OnLoad(f func(antes, depois SysConfig)) {
+	observadores = append(observadores, f)
+}
 
-	return setupPaths()
+// Loaded indica se o arquivo de configuração foi carregado
+func Loaded() bool {
+	return loaded
 }
 
 // setupPaths verifica se todos os diretórios existem, senão cria os mesmos
@@ -68,6 +187,24 @@ func setupPaths() error {
 		return err
 	}
 
+	if err := verifyPath(Config.Path.Outbox); err != nil {
+		return err
+	}
+
+	// Configurações anteriores à auditoria gravam o registro junto aos logs
+	if Config.Path.Audit == "" {
+		Config.Path.Audit = path.Join(Config.Path.LogPath, "audit")
+	}
+	if err := verifyPath(Config.Path.Audit); err != nil {
+		return err
+	}
+
+	if Config.Storage.LocalPath != "" {
+		if err := verifyPath(path.Dir(Config.Storage.LocalPath)); err != nil {
+			return err
+		}
+	}
+
 	return verifyPath(Config.Path.LogPath)
 }
 
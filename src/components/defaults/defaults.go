@@ -5,11 +5,58 @@ import (
 	"time"
 )
 
+const (
+	BufferChannel = 100                     // capacidade dos canais entre serviços
+	BufferSize    = 100                     // quantidade de frames mantidos no buffer da panorâmica
+	IDMax         = 9999                    // maior ID de frame antes de reiniciar a contagem
+	TimeMax       = 500 * time.Millisecond  // diferença máxima entre frame zoom e panorâmico
+	TimeMin       = -500 * time.Millisecond // diferença mínima entre frame zoom e panorâmico
+)
+
+// TipoEvento identifica se o evento é de entrada ou de saída de veículo
+type TipoEvento string
+
+const (
+	// Entrada evento de entrada de veículo
+	Entrada TipoEvento = "entrada"
+	// Saida evento de saída de veículo
+	Saida TipoEvento = "saida"
+)
+
+// ResultadoAcesso representa a decisão tomada para uma placa na portaria
+type ResultadoAcesso string
+
+const (
+	// Permitido o acesso do veículo é liberado
+	Permitido ResultadoAcesso = "permitido"
+	// Negado o acesso do veículo é negado
+	Negado ResultadoAcesso = "negado"
+	// AguardaOperador a decisão depende de confirmação do operador
+	AguardaOperador ResultadoAcesso = "operador"
+)
+
+// DecisaoAcesso registra a decisão de acesso e o motivo, para auditoria
+type DecisaoAcesso struct {
+	Resultado ResultadoAcesso `json:"resultado"`
+	Motivo    string          `json:"motivo"`
+	Regra     string          `json:"regra,omitempty"` // identificador da regra que decidiu o acesso
+	Tempo     time.Time       `json:"tempo"`
+	Operador  string          `json:"operador,omitempty"` // usuário que decidiu o acesso manualmente
+
+	// Automatica é a decisão automática que encaminhou o evento ao operador
+	Automatica *DecisaoAcesso `json:"automatica,omitempty"`
+}
+
 // EventoVeiculo estrutura que defini os dados que são utilizar para criar o evento de entrada de veículo
 type EventoVeiculo struct {
-	Placa    string
-	Tempo    time.Time
-	Portaria string
+	Placa     string
+	Tempo     time.Time
+	Portaria  string
+	Tipo      TipoEvento
+	Confianca float64        // confiança do reconhecimento da placa, entre 0 e 1 (0 desconhecida)
+	Revisar   bool           // leitura semelhante a outra placa recente, deve ser revisada
+	Decisao   *DecisaoAcesso // decisão de acesso tomada para o evento
+	ID        string         // identificador estável do evento, evita registros duplicados no reenvio ao banco
 }
 
 // GetPath função que retorna o diretório do sistema
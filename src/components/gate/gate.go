@@ -0,0 +1,243 @@
+// O pacote gate implementa o acionamento das cancelas das portarias. Cada
+// portaria possui um Actuator (placa de relé HTTP, relé Modbus/TCP ou apenas
+// log) controlado por um Controller, que aplica a duração do pulso, a
+// confirmação de abertura e o intervalo mínimo entre acionamentos
+package gate
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+)
+
+const (
+	logService log.Service = "GATE"
+
+	// Tipos de acionadores configuráveis
+	TipoHTTP   = "http"
+	TipoModbus = "modbus"
+	TipoLog    = "log"
+
+	pulsoPadrao         = 500 * time.Millisecond
+	cooldownPadrao      = 10 * time.Second
+	intervaloConfirmar  = 100 * time.Millisecond // intervalo de consulta do estado na confirmação
+	timeoutAcionamento  = 2 * time.Second        // timeout das requisições ao hardware
+	limpezaAcionamentos = time.Minute            // intervalo para descartar acionamentos antigos
+	tentativasDesligar  = 5                      // tentativas de desligar o relé ao fim do pulso
+	esperaDesligar      = 100 * time.Millisecond // espera após a primeira falha ao desligar, dobrada a cada falha
+)
+
+// Estado representa o estado de uma cancela
+type Estado string
+
+// Estados possíveis de uma cancela
+const (
+	Aberta  Estado = "aberta"
+	Fechada Estado = "fechada"
+)
+
+var (
+	// ErrNaoSuportado indica que o hardware não informa o estado da cancela
+	ErrNaoSuportado = errors.New("Acionador não suporta consulta de estado")
+	// ErrCooldown indica que a cancela já foi acionada para o mesmo evento recentemente
+	ErrCooldown = errors.New("Cancela já acionada para este evento")
+	// ErrNaoConfirmado indica que a cancela não informou abertura após o acionamento
+	ErrNaoConfirmado = errors.New("Abertura da cancela não confirmada")
+	// ErrPortariaDesconhecida indica que não há cancela configurada para a portaria
+	ErrPortariaDesconhecida = errors.New("Nenhuma cancela configurada para a portaria")
+	// ErrReleLigado indica que o relé não desligou ao fim do pulso, mantendo a cancela aberta
+	ErrReleLigado = errors.New("Relé da cancela permaneceu ligado após o pulso")
+
+	errTipoInvalido = errors.New("Tipo de acionador inválido, utilize http, modbus ou log")
+)
+
+// Actuator representa o hardware que aciona a cancela
+type Actuator interface {
+	// Pulse liga o relé da cancela durante o tempo informado
+	Pulse(duracao time.Duration) error
+	// State retorna o estado da cancela, ou ErrNaoSuportado caso o hardware
+	// não possua sensor
+	State() (Estado, error)
+}
+
+// NewActuator cria o acionador descrito na configuração
+func NewActuator(cfg config.CancelaConfig) (Actuator, error) {
+	switch cfg.Tipo {
+	case TipoHTTP:
+		return NewHTTPRelay(cfg.Endereco, cfg.URLAbrir, cfg.URLFechar, cfg.URLEstado, cfg.Aberta), nil
+	case TipoModbus:
+		return NewModbusRelay(cfg.Endereco, byte(cfg.UnitID), uint16(cfg.Coil), cfg.Sensor), nil
+	case TipoLog, "":
+		return NewLogActuator(cfg.Portaria), nil
+	}
+	return nil, errTipoInvalido
+}
+
+// desliga executa f, que desliga o relé do endereço ao fim do pulso,
+// repetindo com espera exponencial em caso de falha. O relé ligado mantém a
+// cancela aberta, por isso a falha após tentativasDesligar é um alerta
+func desliga(endereco string, f func() error) error {
+	espera := esperaDesligar
+	var err error
+	for tentativa := 1; tentativa <= tentativasDesligar; tentativa++ {
+		if err = f(); err == nil {
+			return nil
+		}
+		if tentativa < tentativasDesligar {
+			log.Log(logService, "Falha ao desligar o relé ", endereco, " (tentativa ", tentativa, "): ", err,
+				" - nova tentativa em ", espera)
+			time.Sleep(espera)
+			espera *= 2
+		}
+	}
+	log.Log(logService, "ALERTA: relé ", endereco, " permaneceu ligado após ", tentativasDesligar, " tentativas: ", err)
+	return fmt.Errorf("%v: %v", ErrReleLigado, err)
+}
+
+// Controller controla a cancela de uma portaria
+type Controller struct {
+	portaria  string
+	actuator  Actuator
+	pulso     time.Duration
+	cooldown  time.Duration
+	confirmar time.Duration
+	mutex     sync.Mutex
+	acionadas map[string]time.Time // último acionamento por evento
+	acionando sync.Mutex           // impede pulsos simultâneos no mesmo relé
+}
+
+// NewController cria o controle da cancela com a configuração da portaria
+func NewController(cfg config.CancelaConfig, actuator Actuator) *Controller {
+	c := &Controller{
+		portaria:  cfg.Portaria,
+		actuator:  actuator,
+		pulso:     time.Duration(cfg.Pulso) * time.Millisecond,
+		cooldown:  time.Duration(cfg.Cooldown) * time.Second,
+		confirmar: time.Duration(cfg.Confirmar) * time.Millisecond,
+		acionadas: map[string]time.Time{},
+	}
+	if c.pulso <= 0 {
+		c.pulso = pulsoPadrao
+	}
+	if c.cooldown <= 0 {
+		c.cooldown = cooldownPadrao
+	}
+	return c
+}
+
+// chaveEvento identifica o evento para o controle de acionamentos repetidos
+func chaveEvento(ev defaults.EventoVeiculo) string {
+	return fmt.Sprintf("%s|%s|%s", plate.Key(ev.Placa), ev.Portaria, ev.Tipo)
+}
+
+// Open abre a cancela para o evento. Retorna ErrCooldown caso a cancela já
+// tenha sido acionada para o mesmo evento dentro do cooldown
+func (c *Controller) Open(ev defaults.EventoVeiculo) error {
+	chave := chaveEvento(ev)
+
+	c.mutex.Lock()
+	agora := time.Now()
+	if ultimo, ok := c.acionadas[chave]; ok && agora.Sub(ultimo) < c.cooldown {
+		c.mutex.Unlock()
+		return ErrCooldown
+	}
+	c.acionadas[chave] = agora
+	c.limpa(agora)
+	c.mutex.Unlock()
+
+	log.Log(logService, "Abrindo cancela ", c.portaria, " - placa: ", ev.Placa)
+	return c.aciona()
+}
+
+// OpenManual abre a cancela por comando do operador, sem controle de cooldown
+func (c *Controller) OpenManual(motivo string) error {
+	log.Log(logService, "Abertura manual da cancela ", c.portaria, ": ", motivo)
+	return c.aciona()
+}
+
+// State retorna o estado atual da cancela
+func (c *Controller) State() (Estado, error) {
+	return c.actuator.State()
+}
+
+// aciona envia o pulso ao relé e aguarda a confirmação, quando configurada
+func (c *Controller) aciona() error {
+	c.acionando.Lock()
+	defer c.acionando.Unlock()
+
+	if err := c.actuator.Pulse(c.pulso); err != nil {
+		log.Log(logService, "Erro ao acionar cancela ", c.portaria, ": ", err)
+		return err
+	}
+	if c.confirmar <= 0 {
+		return nil
+	}
+
+	limite := time.Now().Add(c.confirmar)
+	for time.Now().Before(limite) {
+		estado, err := c.actuator.State()
+		if err == ErrNaoSuportado {
+			return nil
+		}
+		if err == nil && estado == Aberta {
+			return nil
+		}
+		time.Sleep(intervaloConfirmar)
+	}
+	log.Log(logService, "Cancela ", c.portaria, " não confirmou abertura")
+	return ErrNaoConfirmado
+}
+
+// limpa descarta os acionamentos mais antigos que o cooldown. Deve ser
+// chamada com o mutex travado
+func (c *Controller) limpa(agora time.Time) {
+	for k, t := range c.acionadas {
+		if agora.Sub(t) > c.cooldown+limpezaAcionamentos {
+			delete(c.acionadas, k)
+		}
+	}
+}
+
+// Manager agrupa os controles das cancelas de todas as portarias
+type Manager struct {
+	cancelas map[string]*Controller
+}
+
+// NewManager cria os controles das cancelas configuradas
+func NewManager(cfgs []config.CancelaConfig) (*Manager, error) {
+	m := &Manager{cancelas: map[string]*Controller{}}
+	for _, cfg := range cfgs {
+		actuator, err := NewActuator(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("cancela %s: %v", cfg.Portaria, err)
+		}
+		m.cancelas[strings.ToLower(cfg.Portaria)] = NewController(cfg, actuator)
+		log.Log(logService, "Cancela configurada: ", cfg.Portaria, " (", cfg.Tipo, ")")
+	}
+	return m, nil
+}
+
+// Gate retorna o controle da cancela da portaria
+func (m *Manager) Gate(portaria string) (*Controller, error) {
+	c, ok := m.cancelas[strings.ToLower(portaria)]
+	if !ok {
+		return nil, ErrPortariaDesconhecida
+	}
+	return c, nil
+}
+
+// Open abre a cancela da portaria do evento
+func (m *Manager) Open(ev defaults.EventoVeiculo) error {
+	c, err := m.Gate(ev.Portaria)
+	if err != nil {
+		return err
+	}
+	return c.Open(ev)
+}
@@ -0,0 +1,85 @@
+package gate
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPRelay aciona a cancela através de uma placa de relé controlada por
+// requisições HTTP GET
+type HTTPRelay struct {
+	endereco  string
+	urlAbrir  string
+	urlFechar string
+	urlEstado string
+	aberta    string
+	client    http.Client
+}
+
+// NewHTTPRelay cria o acionador HTTP. urlFechar e urlEstado são opcionais:
+// sem urlFechar a placa deve desligar o relé sozinha, sem urlEstado a
+// abertura não é confirmada
+func NewHTTPRelay(endereco, urlAbrir, urlFechar, urlEstado, aberta string) *HTTPRelay {
+	return &HTTPRelay{
+		endereco:  strings.TrimRight(endereco, "/"),
+		urlAbrir:  urlAbrir,
+		urlFechar: urlFechar,
+		urlEstado: urlEstado,
+		aberta:    aberta,
+		client:    http.Client{Timeout: timeoutAcionamento},
+	}
+}
+
+// Pulse liga o relé, aguarda a duração do pulso e desliga o relé,
+// repetindo o desligamento em caso de falha
+func (h *HTTPRelay) Pulse(duracao time.Duration) error {
+	if _, err := h.get(h.urlAbrir); err != nil {
+		return err
+	}
+	if h.urlFechar == "" {
+		return nil
+	}
+
+	time.Sleep(duracao)
+	return desliga(h.endereco, func() error {
+		_, err := h.get(h.urlFechar)
+		return err
+	})
+}
+
+// State consulta o estado da cancela em urlEstado
+func (h *HTTPRelay) State() (Estado, error) {
+	if h.urlEstado == "" || h.aberta == "" {
+		return "", ErrNaoSuportado
+	}
+
+	body, err := h.get(h.urlEstado)
+	if err != nil {
+		return "", err
+	}
+	if strings.Contains(body, h.aberta) {
+		return Aberta, nil
+	}
+	return Fechada, nil
+}
+
+// get executa a requisição no caminho da placa de relé e retorna o corpo da resposta
+func (h *HTTPRelay) get(caminho string) (string, error) {
+	resp, err := h.client.Get(h.endereco + caminho)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("placa de relé respondeu %s", resp.Status)
+	}
+	return string(body), nil
+}
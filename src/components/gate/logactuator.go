@@ -0,0 +1,45 @@
+package gate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+// LogActuator é um acionador que apenas registra os acionamentos no log, para
+// testes e portarias sem cancela automatizada
+type LogActuator struct {
+	portaria string
+	mutex    sync.Mutex
+	pulsos   int
+	ultimo   time.Time
+}
+
+// NewLogActuator cria o acionador de log da portaria
+func NewLogActuator(portaria string) *LogActuator {
+	return &LogActuator{portaria: portaria}
+}
+
+// Pulse registra o acionamento no log
+func (l *LogActuator) Pulse(duracao time.Duration) error {
+	l.mutex.Lock()
+	l.pulsos++
+	l.ultimo = time.Now()
+	l.mutex.Unlock()
+
+	log.Log(logService, "Cancela ", l.portaria, " acionada (simulada) por ", duracao)
+	return nil
+}
+
+// State não é suportado pelo acionador de log
+func (l *LogActuator) State() (Estado, error) {
+	return "", ErrNaoSuportado
+}
+
+// Pulses retorna a quantidade de acionamentos e o horário do último
+func (l *LogActuator) Pulses() (int, time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.pulsos, l.ultimo
+}
@@ -0,0 +1,138 @@
+package gate
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+const (
+	funcReadDiscreteInputs = 0x02
+	funcWriteSingleCoil    = 0x05
+
+	coilLigada    = 0xFF00
+	coilDesligada = 0x0000
+)
+
+var errRespostaModbus = errors.New("Resposta modbus inválida")
+
+// ModbusRelay aciona a cancela através de um relé Modbus/TCP. O relé é ligado
+// escrevendo na bobina (coil) e o estado é lido em uma entrada discreta
+type ModbusRelay struct {
+	endereco string
+	unitID   byte
+	coil     uint16
+	sensor   int
+	mutex    sync.Mutex
+	transID  uint16
+}
+
+// NewModbusRelay cria o acionador Modbus/TCP. sensor negativo indica que não
+// há entrada de confirmação de abertura
+func NewModbusRelay(endereco string, unitID byte, coil uint16, sensor int) *ModbusRelay {
+	return &ModbusRelay{endereco: endereco, unitID: unitID, coil: coil, sensor: sensor}
+}
+
+// Pulse liga a bobina do relé, aguarda a duração do pulso e a desliga,
+// repetindo o desligamento em caso de falha
+func (m *ModbusRelay) Pulse(duracao time.Duration) error {
+	if err := m.escreveCoil(coilLigada); err != nil {
+		return err
+	}
+	time.Sleep(duracao)
+	return desliga(m.endereco, func() error {
+		return m.escreveCoil(coilDesligada)
+	})
+}
+
+// State lê a entrada discreta do sensor da cancela
+func (m *ModbusRelay) State() (Estado, error) {
+	if m.sensor < 0 {
+		return "", ErrNaoSuportado
+	}
+
+	pdu := make([]byte, 5)
+	pdu[0] = funcReadDiscreteInputs
+	binary.BigEndian.PutUint16(pdu[1:], uint16(m.sensor))
+	binary.BigEndian.PutUint16(pdu[3:], 1)
+
+	resp, err := m.requisicao(pdu)
+	if err != nil {
+		return "", err
+	}
+	if len(resp) < 3 || resp[1] < 1 {
+		return "", errRespostaModbus
+	}
+	if resp[2]&0x01 == 1 {
+		return Aberta, nil
+	}
+	return Fechada, nil
+}
+
+// escreveCoil escreve o valor na bobina do relé
+func (m *ModbusRelay) escreveCoil(valor uint16) error {
+	pdu := make([]byte, 5)
+	pdu[0] = funcWriteSingleCoil
+	binary.BigEndian.PutUint16(pdu[1:], m.coil)
+	binary.BigEndian.PutUint16(pdu[3:], valor)
+
+	_, err := m.requisicao(pdu)
+	return err
+}
+
+// requisicao envia a PDU com o cabeçalho MBAP e retorna a PDU da resposta
+func (m *ModbusRelay) requisicao(pdu []byte) ([]byte, error) {
+	m.mutex.Lock()
+	m.transID++
+	transID := m.transID
+	m.mutex.Unlock()
+
+	conn, err := net.DialTimeout("tcp", m.endereco, timeoutAcionamento)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(time.Now().Add(timeoutAcionamento)); err != nil {
+		return nil, err
+	}
+
+	// Cabeçalho MBAP: transação, protocolo (0), tamanho e unidade
+	frame := make([]byte, 7+len(pdu))
+	binary.BigEndian.PutUint16(frame[0:], transID)
+	binary.BigEndian.PutUint16(frame[2:], 0)
+	binary.BigEndian.PutUint16(frame[4:], uint16(len(pdu)+1))
+	frame[6] = m.unitID
+	copy(frame[7:], pdu)
+	if _, err := conn.Write(frame); err != nil {
+		return nil, err
+	}
+
+	cabecalho := make([]byte, 7)
+	if _, err := io.ReadFull(conn, cabecalho); err != nil {
+		return nil, err
+	}
+	tamanho := int(binary.BigEndian.Uint16(cabecalho[4:]))
+	if binary.BigEndian.Uint16(cabecalho[0:]) != transID || tamanho < 2 {
+		return nil, errRespostaModbus
+	}
+
+	resp := make([]byte, tamanho-1)
+	if _, err := io.ReadFull(conn, resp); err != nil {
+		return nil, err
+	}
+	if resp[0] == pdu[0]|0x80 {
+		// A resposta de exceção contém a função e o código da exceção
+		if len(resp) < 2 {
+			return nil, errRespostaModbus
+		}
+		return nil, fmt.Errorf("exceção modbus %d na função %d", resp[1], pdu[0])
+	}
+	if resp[0] != pdu[0] {
+		return nil, errRespostaModbus
+	}
+	return resp, nil
+}
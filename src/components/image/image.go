@@ -0,0 +1,20 @@
+// O pacote image define as estruturas dos frames trafegados entre as câmeras
+// e os serviços de processamento
+package image
+
+import (
+	"time"
+)
+
+// ImageStruct representa um frame JPEG recebido da câmera
+type ImageStruct struct {
+	Image       []byte    // conteúdo JPEG do frame
+	Time        time.Time // timestamp de captura calculado a partir do relógio da câmera
+	IsNightMode int       // 1 quando a câmera está em modo noturno
+}
+
+// ImageZoomID representa um frame da câmera zoom identificado pelo sci-zoom
+type ImageZoomID struct {
+	ZoomID int
+	Img    *ImageStruct
+}
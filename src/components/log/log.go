@@ -82,7 +82,7 @@ func Fatal(logService Service, data ...interface{}) {
 		if err := closeLogPackage(); err != nil {
 			createFatalLog("LOG", "Falha na tentativa de fechar o arquivo de log: ", err)
 		}
-		if config.Config != (config.SysConfig{}) {
+		if config.Loaded() {
 			fmt.Println("Não entendi")
 		} else {
 			createFatalLog("LOG", "Nao foi possivel enviar log via FTP (sem config.json)")
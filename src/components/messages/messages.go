@@ -2,26 +2,21 @@ package messages
 
 import (
 	"time"
-)
-
-// SglPackage representa a estrutura do pacote referente ao serviço de GPS
-type SglPackage struct {
-	ID    int
-	Dados gps.GPSPackage
-	Erro  error
-}
 
-type MsgSgl struct {
-	ID    int
-	Tempo time.Time
-}
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/recognition"
+)
 
 // Msg representa a estrutura do canal para comunicação entre scizoom e sdp
 type Msg struct {
-	ZoomID int
-	ID     int
-	Frame  image.ImageStruct
-	Err    error
+	Portaria string // portaria da câmera que capturou o frame
+	Camera   string // identificador da câmera
+	ZoomID   int
+	ID       int
+	Frame    image.ImageStruct
+	Err      error
 }
 
 // PanReceive representa a estrutura do canal para comunicação entre sdp e sci-pan
@@ -34,29 +29,51 @@ type PanReceive struct {
 type ScdPackage struct {
 	SlpOk     bool
 	PanOk     bool
-	GpsOk     bool
 	ZoomFrame []byte
-	PlateInfo jidosha.Reconhecimento
+	PlateInfo recognition.Reconhecimento
 	Time      time.Time
 	PanFrame  []byte
-	GpsData   SglPackage
 	Err       error
 }
 
+// PlatePackage representa uma placa reconhecida em uma portaria, enviada ao
+// serviço de eventos
+type PlatePackage struct {
+	Placa     string
+	Tempo     time.Time           // timestamp do frame em que a placa foi reconhecida
+	Portaria  string              // nome da portaria da câmera
+	Tipo      defaults.TipoEvento // sentido da passagem na portaria
+	Confianca float64
+	Revisar   bool // leitura semelhante a outra placa recente, deve ser revisada
+	ZoomFrame []byte
+	PanFrame  []byte
+}
+
 // SlpPackage representa a estrutra do pacote referente ao serviço slp
 type SlpPackage struct {
+	Portaria     string
+	Camera       string
 	ZoomID       int
 	ID           int
 	ZoomFrame    *image.ImageStruct
-	PlateInfo    jidosha.Reconhecimento
+	PlateInfo    recognition.Reconhecimento
 	JidoshaError error
 }
 
+// BufferPackage representa uma placa reconhecida mantida no buffer de placas
 type BufferPackage struct {
-	PlateInfo jidosha.Reconhecimento
+	Placa     string // chave canônica da placa (plate.Key)
+	PlateInfo recognition.Reconhecimento
 	Frame     *image.ImageStruct
 }
 
+// NewBufferPackage cria o pacote do buffer de placas, preenchendo a chave
+// canônica a partir da placa reconhecida
+func NewBufferPackage(info recognition.Reconhecimento, frame *image.ImageStruct) BufferPackage {
+	return BufferPackage{Placa: plate.Key(info.Placa), PlateInfo: info, Frame: frame}
+}
+
+// BufferPlate representa o buffer de placas indexado pela chave canônica da placa
 type BufferPlate map[string]BufferPackage
 
 // ScdBuffer representa o tipo de mapa para controle de buffer no scd
@@ -65,9 +82,6 @@ type ScdBuffer map[int]ScdPackage
 // PlateBuffer representa o tipo de mapa para controle de placa no scd
 type PlateBuffer map[string]time.Time
 
-// BufferGPS representa o tipo de mapa para controle de dados do GPS
-type BufferGPS map[time.Time]SglPackage
-
 var (
 
 	/*	Canais para comunicação entre serviços
@@ -79,19 +93,17 @@ var (
 		slpToScd	SlpPackage			slp			scd
 		zoomCh		[]byte				cam			sci-zoom
 		zoomToSdp	*image.ImageStruct	sci-zoom	sdp
-		sglToScd	SglPackage			sgl			scd
-		slpToSgl	MsgSgl			slp			sgl
+		plateToEvents	PlatePackage	scd			events
 	*/
 
-	panCh     = make(chan []byte, defaults.BufferChannel)
-	panToScd  = make(chan Msg, defaults.BufferChannel)
-	sdpToPan  = make(chan PanReceive, defaults.BufferChannel)
-	sdpToSlp  = make(chan Msg, defaults.BufferChannel)
-	slpToScd  = make(chan SlpPackage, defaults.BufferChannel)
-	zoomCh    = make(chan []byte, defaults.BufferChannel)
-	zoomToSdp = make(chan *image.ImageZoomID, defaults.BufferChannel)
-	sglToScd  = make(chan SglPackage, defaults.BufferChannel)
-	slpToSgl  = make(chan MsgSgl, defaults.BufferChannel)
+	panCh         = make(chan []byte, defaults.BufferChannel)
+	panToScd      = make(chan Msg, defaults.BufferChannel)
+	sdpToPan      = make(chan PanReceive, defaults.BufferChannel)
+	sdpToSlp      = make(chan Msg, defaults.BufferChannel)
+	slpToScd      = make(chan SlpPackage, defaults.BufferChannel)
+	zoomCh        = make(chan []byte, defaults.BufferChannel)
+	zoomToSdp     = make(chan *image.ImageZoomID, defaults.BufferChannel)
+	plateToEvents = make(chan PlatePackage, defaults.BufferChannel)
 )
 
 // GetChanPan retorna o canal para comunicação entre cam pan e sci-pan
@@ -159,22 +171,12 @@ func SendResultSdp(msg *image.ImageZoomID) {
 	zoomToSdp <- msg
 }
 
-// GetChanSgl retorna o canal para comunicação entre sgl e scd
-func GetChanSgl() chan SglPackage {
-	return sglToScd
-}
-
-// SendResultSglToScd envia dados para o canal entre sgl e scd
-func SendResultSglToScd(msg SglPackage) {
-	sglToScd <- msg
-}
-
-// GetChanSlp retorna o canal para comunicação entre o slp e sgl
-func GetChanSlp() chan MsgSgl {
-	return slpToSgl
+// GetPlateToEvents retorna o canal de placas reconhecidas para o serviço de eventos
+func GetPlateToEvents() chan PlatePackage {
+	return plateToEvents
 }
 
-// SendResultSlpToSgl envia dados de tempo para o canal entre o slp e sgl
-func SendResultSlpToSgl(msg MsgSgl) {
-	slpToSgl <- msg
+// SendPlateToEvents envia uma placa reconhecida para o serviço de eventos
+func SendPlateToEvents(msg PlatePackage) {
+	plateToEvents <- msg
 }
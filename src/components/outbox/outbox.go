@@ -0,0 +1,227 @@
+// O pacote outbox implementa uma fila de eventos persistida em disco. Todo
+// evento é gravado antes do envio ao banco e só é removido após o envio ter
+// sucesso, de forma que quedas de conexão ou reinícios do processo não
+// causem perda de registros. Cada evento recebe um ID estável, de forma que o
+// banco reconheça os reenvios de eventos já gravados.
+package outbox
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	logService log.Service = "OUTBOX"
+
+	extensao         = ".json"
+	backoffInicial   = time.Second     // espera após a primeira falha de envio
+	backoffMaximo    = 5 * time.Minute // espera máxima entre tentativas
+	fatorBackoff     = 2               // multiplicador da espera a cada falha consecutiva
+	intervaloInativo = time.Minute     // intervalo de verificação da fila quando vazia
+
+	// Falhas consecutivas de envio de um evento antes de movê-lo para o
+	// diretório de descartados, liberando a fila. Com o backoffMaximo o
+	// evento é repetido por cerca de 4 horas
+	tentativasMaximas = 60
+	dirDescartados    = "descartados" // subdiretório dos eventos que não puderam ser enviados
+)
+
+// SendFunc envia um evento ao destino final. Um erro mantém o evento na fila
+type SendFunc func(defaults.EventoVeiculo) error
+
+// Outbox representa a fila de eventos pendentes de envio
+type Outbox struct {
+	dir    string
+	send   SendFunc
+	mutex  sync.Mutex
+	fila   []string // nomes dos arquivos pendentes, em ordem de chegada
+	seq    uint64
+	notify chan struct{}
+}
+
+// New cria a fila no diretório dir, recuperando os eventos que ficaram
+// pendentes de execuções anteriores. Os eventos descartados ficam no
+// subdiretório dirDescartados e não são recuperados
+func New(dir string, send SendFunc) (*Outbox, error) {
+	arquivos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Join(dir, dirDescartados), 0755); err != nil {
+		return nil, err
+	}
+
+	o := &Outbox{dir: dir, send: send, notify: make(chan struct{}, 1)}
+	for _, f := range arquivos {
+		if !f.IsDir() && strings.HasSuffix(f.Name(), extensao) {
+			o.fila = append(o.fila, f.Name())
+		}
+	}
+	sort.Strings(o.fila)
+
+	if len(o.fila) > 0 {
+		log.Log(logService, "Recuperados ", len(o.fila), " eventos pendentes de envio")
+	}
+	return o, nil
+}
+
+// Add grava o evento em disco e o coloca na fila de envio. Quando Add retorna
+// sem erro o evento está persistido. Eventos sem ID recebem o nome do arquivo
+func (o *Outbox) Add(ev defaults.EventoVeiculo) error {
+	o.mutex.Lock()
+	o.seq++
+	nome := fmt.Sprintf("%020d-%06d%s", time.Now().UnixNano(), o.seq%1000000, extensao)
+	o.mutex.Unlock()
+
+	if ev.ID == "" {
+		ev.ID = idEvento(nome)
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return err
+	}
+
+	if err := gravaArquivo(filepath.Join(o.dir, nome), data); err != nil {
+		return err
+	}
+
+	o.mutex.Lock()
+	o.fila = append(o.fila, nome)
+	o.mutex.Unlock()
+
+	select {
+	case o.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Len retorna a quantidade de eventos aguardando envio
+func (o *Outbox) Len() int {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+	return len(o.fila)
+}
+
+// Run envia os eventos da fila em ordem de chegada. Em caso de falha o envio
+// é repetido com espera exponencial, mantendo a ordem dos eventos. Após
+// tentativasMaximas falhas consecutivas o evento é descartado da fila
+func (o *Outbox) Run() {
+	espera := backoffInicial
+	falhas := 0
+	for {
+		o.mutex.Lock()
+		if len(o.fila) == 0 {
+			o.mutex.Unlock()
+			select {
+			case <-o.notify:
+			case <-time.After(intervaloInativo):
+			}
+			continue
+		}
+		nome := o.fila[0]
+		o.mutex.Unlock()
+
+		if err := o.envia(nome); err != nil {
+			falhas++
+			if falhas < tentativasMaximas {
+				log.Log(logService, "Falha ao enviar evento ", nome, " - nova tentativa em ", espera,
+					" (", o.Len(), " eventos na fila): ", err)
+				time.Sleep(espera)
+				espera *= fatorBackoff
+				if espera > backoffMaximo {
+					espera = backoffMaximo
+				}
+				continue
+			}
+			if err := o.descarta(nome, falhas, err); err != nil {
+				// Sem mover o arquivo o evento é mantido na fila
+				log.Log(logService, "Erro ao descartar o evento ", nome, ": ", err)
+				time.Sleep(espera)
+				continue
+			}
+		}
+		espera = backoffInicial
+		falhas = 0
+
+		o.mutex.Lock()
+		o.fila = o.fila[1:]
+		o.mutex.Unlock()
+	}
+}
+
+// envia lê o evento do arquivo, envia e remove o arquivo após o sucesso
+func (o *Outbox) envia(nome string) error {
+	arquivo := filepath.Join(o.dir, nome)
+	data, err := ioutil.ReadFile(arquivo)
+	if err != nil {
+		return err
+	}
+
+	var ev defaults.EventoVeiculo
+	if err := json.Unmarshal(data, &ev); err != nil {
+		// Arquivo corrompido nunca poderá ser enviado, então é separado da fila
+		log.Log(logService, "Evento corrompido descartado da fila: ", nome, " - ", err)
+		return os.Rename(arquivo, arquivo+".corrompido")
+	}
+	// Eventos gravados antes do ID utilizam o nome do arquivo, que não muda
+	// entre os reenvios
+	if ev.ID == "" {
+		ev.ID = idEvento(nome)
+	}
+
+	if err := o.send(ev); err != nil {
+		return err
+	}
+	return os.Remove(arquivo)
+}
+
+// descarta move o evento para o diretório de descartados após falhas
+// consecutivas de envio. O arquivo pode ser movido de volta para o diretório
+// da fila para um novo envio após o próximo início
+func (o *Outbox) descarta(nome string, falhas int, err error) error {
+	destino := filepath.Join(o.dir, dirDescartados, nome)
+	if err := os.Rename(filepath.Join(o.dir, nome), destino); err != nil {
+		return err
+	}
+	log.Log(logService, "ALERTA: evento ", nome, " descartado da fila após ", falhas,
+		" falhas de envio, movido para ", destino, " - último erro: ", err)
+	return nil
+}
+
+// idEvento retorna o ID do evento gravado no arquivo nome
+func idEvento(nome string) string {
+	return strings.TrimSuffix(nome, extensao)
+}
+
+// gravaArquivo grava os dados em um arquivo temporário e o renomeia, garantindo
+// que a fila nunca contenha arquivos escritos pela metade
+func gravaArquivo(arquivo string, data []byte) error {
+	tmp := arquivo + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, arquivo)
+}
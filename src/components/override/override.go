@@ -0,0 +1,212 @@
+// O pacote override implementa a confirmação manual de acesso: eventos que
+// não podem ser decididos automaticamente ficam pendentes até que um operador
+// aprove ou negue a passagem, ou até o tempo limite de resposta
+package override
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "OVERRIDE"
+
+	// RegraOperador identifica as decisões tomadas pelo operador
+	RegraOperador = "operador"
+	// RegraTimeout identifica as decisões tomadas por falta de resposta do operador
+	RegraTimeout = "operador-timeout"
+
+	// Tipos de notificação enviados aos operadores
+	NotificacaoPendente  = "pendente"
+	NotificacaoResolvida = "resolvida"
+
+	timeoutPadrao      = time.Minute
+	bufferNotificacoes = 100
+)
+
+var (
+	// ErrNaoEncontrada indica que a pendência não existe ou já foi resolvida
+	ErrNaoEncontrada = errors.New("Decisão pendente não encontrada ou já resolvida")
+	// ErrMotivoVazio indica que o operador não informou o motivo da decisão
+	ErrMotivoVazio = errors.New("O motivo da decisão é obrigatório")
+)
+
+// Pending representa um evento aguardando a decisão do operador
+type Pending struct {
+	ID       string                  `json:"id"`
+	Evento   defaults.EventoVeiculo  `json:"evento"`
+	Criado   time.Time               `json:"criado"`
+	Expira   time.Time               `json:"expira"`
+	Zoom     bool                    `json:"zoom"`    // possui imagem da câmera zoom
+	Pan      bool                    `json:"pan"`     // possui imagem da câmera panorâmica
+	Decisao  *defaults.DecisaoAcesso `json:"decisao"` // preenchida quando resolvida
+	zoom     []byte
+	pan      []byte
+	resposta chan defaults.DecisaoAcesso
+}
+
+// Notificacao é enviada aos operadores quando uma pendência é criada ou resolvida
+type Notificacao struct {
+	Tipo     string
+	Pendente Pending
+}
+
+// Manager mantém as decisões pendentes
+type Manager struct {
+	Notificacoes chan Notificacao
+	mutex        sync.Mutex
+	pendentes    map[string]*Pending
+	timeout      time.Duration
+}
+
+// New cria o gerenciador de pendências. timeout é o tempo de espera pela
+// resposta do operador, após o qual o acesso é negado
+func New(timeout time.Duration) *Manager {
+	if timeout <= 0 {
+		timeout = timeoutPadrao
+	}
+	return &Manager{
+		Notificacoes: make(chan Notificacao, bufferNotificacoes),
+		pendentes:    map[string]*Pending{},
+		timeout:      timeout,
+	}
+}
+
+// Wait cria a pendência para o evento e aguarda a decisão do operador ou o
+// tempo limite. Retorna a decisão final, que mantém a decisão automática em
+// Automatica
+func (m *Manager) Wait(evento defaults.EventoVeiculo, zoom, pan []byte) defaults.DecisaoAcesso {
+	agora := time.Now()
+	p := &Pending{
+		ID:       storage.NewID(),
+		Evento:   evento,
+		Criado:   agora,
+		Expira:   agora.Add(m.timeout),
+		Zoom:     len(zoom) > 0,
+		Pan:      len(pan) > 0,
+		zoom:     zoom,
+		pan:      pan,
+		resposta: make(chan defaults.DecisaoAcesso, 1),
+	}
+
+	m.mutex.Lock()
+	m.pendentes[p.ID] = p
+	m.mutex.Unlock()
+	log.Log(logService, "Aguardando operador para a placa ", evento.Placa, " (", evento.Tipo, " ", evento.Portaria, ") - pendência ", p.ID)
+	m.notifica(NotificacaoPendente, *p)
+
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	var d defaults.DecisaoAcesso
+	select {
+	case d = <-p.resposta:
+	case <-timer.C:
+		// Remove a pendência antes de decidir, para que Resolve retorne
+		// ErrNaoEncontrada a partir daqui. Caso Resolve já a tenha removido,
+		// a resposta do operador está sendo enviada e prevalece
+		m.mutex.Lock()
+		_, aberta := m.pendentes[p.ID]
+		delete(m.pendentes, p.ID)
+		m.mutex.Unlock()
+
+		if !aberta {
+			d = <-p.resposta
+			break
+		}
+		d = defaults.DecisaoAcesso{
+			Resultado: defaults.Negado,
+			Regra:     RegraTimeout,
+			Motivo:    "sem resposta do operador",
+			Tempo:     time.Now(),
+		}
+		log.Log(logService, "Pendência ", p.ID, " da placa ", evento.Placa, " expirou sem resposta do operador")
+	}
+	d.Automatica = evento.Decisao
+
+	p.Decisao = &d
+	m.notifica(NotificacaoResolvida, *p)
+	return d
+}
+
+// Resolve registra a decisão do operador para a pendência
+func (m *Manager) Resolve(id string, permitido bool, operador, motivo string) error {
+	if strings.TrimSpace(motivo) == "" {
+		return ErrMotivoVazio
+	}
+
+	m.mutex.Lock()
+	p, ok := m.pendentes[id]
+	if ok {
+		// Remove antes de responder para que uma segunda resposta retorne ErrNaoEncontrada
+		delete(m.pendentes, id)
+	}
+	m.mutex.Unlock()
+	if !ok {
+		return ErrNaoEncontrada
+	}
+
+	d := defaults.DecisaoAcesso{
+		Resultado: defaults.Negado,
+		Regra:     RegraOperador,
+		Motivo:    motivo,
+		Operador:  operador,
+		Tempo:     time.Now(),
+	}
+	if permitido {
+		d.Resultado = defaults.Permitido
+	}
+	log.Log(logService, "Pendência ", id, " da placa ", p.Evento.Placa, " resolvida por ", operador, ": ", d.Resultado, " - ", motivo)
+
+	p.resposta <- d
+	return nil
+}
+
+// Pending retorna as pendências abertas, da mais antiga para a mais recente
+func (m *Manager) Pending() []Pending {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	pendentes := []Pending{}
+	for _, p := range m.pendentes {
+		pendentes = append(pendentes, *p)
+	}
+	sort.Slice(pendentes, func(i, j int) bool {
+		return pendentes[i].Criado.Before(pendentes[j].Criado)
+	})
+	return pendentes
+}
+
+// Get retorna a pendência aberta
+func (m *Manager) Get(id string) (Pending, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, ok := m.pendentes[id]
+	if !ok {
+		return Pending{}, ErrNaoEncontrada
+	}
+	return *p, nil
+}
+
+// Images retorna as imagens zoom e panorâmica da pendência aberta
+func (m *Manager) Images(id string) (zoom, pan []byte, err error) {
+	p, err := m.Get(id)
+	return p.zoom, p.pan, err
+}
+
+// notifica envia a notificação sem bloquear caso ninguém esteja consumindo
+func (m *Manager) notifica(tipo string, p Pending) {
+	select {
+	case m.Notificacoes <- Notificacao{Tipo: tipo, Pendente: p}:
+	default:
+		log.Log(logService, "Canal de notificações cheio, notificação ", tipo, " da pendência ", p.ID, " descartada")
+	}
+}
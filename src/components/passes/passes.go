@@ -0,0 +1,318 @@
+// O pacote passes implementa o pré-cadastro de visitantes e fornecedores:
+// passes com período de validade, portarias permitidas e anfitrião, que
+// liberam a entrada da placa durante o período e expiram em seguida
+package passes
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/registry"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "PASSES"
+
+	colecaoPasses = "passes"
+)
+
+// Status representa a situação de um passe
+type Status string
+
+// Situações possíveis de um passe
+const (
+	Ativo     Status = "ativo"     // pode ser utilizado dentro do período
+	Utilizado Status = "utilizado" // passe de uso único encerrado pela saída do veículo
+	Cancelado Status = "cancelado" // cancelado antes do fim do período
+	Expirado  Status = "expirado"  // período encerrado, calculado na consulta
+)
+
+var (
+	errPlacaVazia        = errors.New("A placa do passe é obrigatória")
+	errNomeVazio         = errors.New("O nome do visitante é obrigatório")
+	errAnfitriaoVazio    = errors.New("O anfitrião do visitante é obrigatório")
+	errPeriodoInvalido   = errors.New("Período inválido, o fim deve ser posterior ao início")
+	errCategoriaInvalida = errors.New("Categoria inválida, utilize visitante ou fornecedor")
+
+	// ErrEncerrado indica a tentativa de alterar um passe utilizado ou cancelado
+	ErrEncerrado = errors.New("Passe utilizado ou cancelado não pode ser alterado")
+	// ErrForaDoPeriodo indica que a placa possui passe, mas o período de validade ainda não começou
+	ErrForaDoPeriodo = errors.New("Passe fora do período de validade")
+	// ErrPortaria indica que a placa possui passe, mas não para a portaria
+	ErrPortaria = errors.New("Passe não permite a entrada pela portaria")
+)
+
+// Pass representa a autorização de entrada de um visitante ou fornecedor
+type Pass struct {
+	ID        string             `json:"id"`
+	Placa     string             `json:"placa"`
+	Chave     string             `json:"chave"`     // chave da placa (plate.Key), utilizada nas consultas
+	Nome      string             `json:"nome"`      // visitante ou empresa fornecedora
+	Categoria registry.Categoria `json:"categoria"` // visitante ou fornecedor
+	Anfitriao string             `json:"anfitriao"` // pessoa ou setor que recebe o visitante
+	Inicio    time.Time          `json:"inicio"`
+	Fim       time.Time          `json:"fim"`
+	Portarias []string           `json:"portarias"` // portarias permitidas. Vazio permite todas
+	UsoUnico  bool               `json:"usoUnico"`  // encerra o passe na saída do veículo
+	Status    Status             `json:"status"`
+	Entrada   time.Time          `json:"entrada"` // primeira entrada com o passe
+	Saida     time.Time          `json:"saida"`   // saída que encerrou o passe de uso único
+	CriadoPor string             `json:"criadoPor"`
+	Criado    time.Time          `json:"criado"`
+}
+
+// Validate verifica se os dados obrigatórios do passe são válidos
+func (p *Pass) Validate() error {
+	p.Placa = plate.Normalize(p.Placa)
+	if p.Placa == "" {
+		return errPlacaVazia
+	}
+	p.Chave = plate.Key(p.Placa)
+	if strings.TrimSpace(p.Nome) == "" {
+		return errNomeVazio
+	}
+	if strings.TrimSpace(p.Anfitriao) == "" {
+		return errAnfitriaoVazio
+	}
+	switch p.Categoria {
+	case "":
+		p.Categoria = registry.Visitante
+	case registry.Visitante, registry.Fornecedor:
+	default:
+		return errCategoriaInvalida
+	}
+	if p.Inicio.IsZero() || !p.Fim.After(p.Inicio) {
+		return errPeriodoInvalido
+	}
+	return nil
+}
+
+// Vigente indica se o passe pode ser utilizado no tempo t
+func (p Pass) Vigente(t time.Time) bool {
+	return p.Status == Ativo && !t.Before(p.Inicio) && !t.After(p.Fim)
+}
+
+// situacao retorna o passe com o status calculado no tempo t
+func (p Pass) situacao(t time.Time) Pass {
+	if p.Status == Ativo && t.After(p.Fim) {
+		p.Status = Expirado
+	}
+	return p
+}
+
+// permitePortaria verifica se o passe permite a portaria, sem diferenciar maiúsculas
+func (p Pass) permitePortaria(portaria string) bool {
+	if len(p.Portarias) == 0 {
+		return true
+	}
+	for _, pt := range p.Portarias {
+		if strings.EqualFold(pt, portaria) {
+			return true
+		}
+	}
+	return false
+}
+
+// Manager mantém os passes persistidos no banco
+type Manager struct {
+	mutex sync.Mutex
+	docs  storage.Documents
+}
+
+// New cria o gerenciador de passes que persiste os dados em docs
+func New(docs storage.Documents) *Manager {
+	m := &Manager{docs: docs}
+	m.migraChaves()
+	return m
+}
+
+// migraChaves grava a chave da placa nos passes criados antes da consulta
+// pela chave, que não seriam encontrados por lista
+func (m *Manager) migraChaves() {
+	todos := []Pass{}
+	if err := m.docs.List(colecaoPasses, &todos); err != nil {
+		log.Log(logService, "Erro ao listar passes para gravar a chave da placa: ", err)
+		return
+	}
+	for _, p := range todos {
+		if p.Chave != "" {
+			continue
+		}
+		p.Chave = plate.Key(p.Placa)
+		if err := m.docs.Put(colecaoPasses, p.ID, p); err != nil {
+			log.Log(logService, "Erro ao gravar a chave da placa do passe ", p.ID, ": ", err)
+		}
+	}
+}
+
+// Passes retorna os passes da placa informada, ou todos caso vazia, do mais
+// recente para o mais antigo
+func (m *Manager) Passes(placa string) ([]Pass, error) {
+	passes, err := m.lista(placa)
+	if err != nil {
+		return nil, err
+	}
+
+	agora := time.Now()
+	for i := range passes {
+		passes[i] = passes[i].situacao(agora)
+	}
+	sort.Slice(passes, func(i, j int) bool {
+		return passes[i].Inicio.After(passes[j].Inicio)
+	})
+	return passes, nil
+}
+
+// lista retorna os passes gravados da placa, ou todos caso vazia. Os passes
+// da placa são consultados no banco pela chave, em ambos os formatos
+func (m *Manager) lista(placa string) ([]Pass, error) {
+	passes := []Pass{}
+	var err error
+	if placa == "" {
+		err = m.docs.List(colecaoPasses, &passes)
+	} else {
+		err = m.docs.Where(colecaoPasses, "chave", plate.Key(placa), &passes)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return passes, nil
+}
+
+// Pass retorna o passe pelo ID. Retorna storage.ErrNotFound caso não exista
+func (m *Manager) Pass(id string) (Pass, error) {
+	var p Pass
+	if err := m.docs.Get(colecaoPasses, id, &p); err != nil {
+		return p, err
+	}
+	return p.situacao(time.Now()), nil
+}
+
+// Save cria ou atualiza o passe. Um ID é gerado para novos passes, que são
+// criados ativos. Passes encerrados não podem ser alterados
+func (m *Manager) Save(p *Pass) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if p.ID == "" {
+		p.ID = storage.NewID()
+		p.Status = Ativo
+		p.Entrada, p.Saida = time.Time{}, time.Time{}
+		p.Criado = time.Now()
+	} else {
+		var antes Pass
+		if err := m.docs.Get(colecaoPasses, p.ID, &antes); err != nil {
+			return err
+		}
+		if antes.Status != Ativo {
+			return ErrEncerrado
+		}
+		// A situação, o uso e a criação são mantidos pelo sistema
+		p.Status, p.Entrada, p.Saida = antes.Status, antes.Entrada, antes.Saida
+		p.CriadoPor, p.Criado = antes.CriadoPor, antes.Criado
+	}
+
+	if err := m.docs.Put(colecaoPasses, p.ID, p); err != nil {
+		return err
+	}
+	log.Log(logService, "Passe ", p.ID, " da placa ", p.Placa, " salvo: ", p.Nome, " (anfitrião ", p.Anfitriao, ") de ",
+		p.Inicio.Format("02/01/2006 15:04"), " a ", p.Fim.Format("02/01/2006 15:04"))
+	return nil
+}
+
+// Cancel encerra o passe antes do fim do período. O passe é mantido para consulta
+func (m *Manager) Cancel(id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	var p Pass
+	if err := m.docs.Get(colecaoPasses, id, &p); err != nil {
+		return err
+	}
+	if p.Status != Ativo {
+		return ErrEncerrado
+	}
+	p.Status = Cancelado
+	if err := m.docs.Put(colecaoPasses, p.ID, p); err != nil {
+		return err
+	}
+	log.Log(logService, "Passe ", p.ID, " da placa ", p.Placa, " cancelado")
+	return nil
+}
+
+// Find retorna o passe que permite a entrada da placa pela portaria no tempo
+// t. Retorna storage.ErrNotFound caso a placa não possua passe ativo, como
+// quando todos os passes já expiraram, ErrForaDoPeriodo caso possua um passe
+// que ainda não começou e ErrPortaria caso o passe vigente não permita a
+// portaria
+func (m *Manager) Find(placa, portaria string, t time.Time) (Pass, error) {
+	passes, err := m.lista(placa)
+	if err != nil {
+		return Pass{}, err
+	}
+
+	motivo := storage.ErrNotFound
+	for _, p := range passes {
+		switch {
+		case p.Status != Ativo, t.After(p.Fim):
+		case !p.Vigente(t):
+			motivo = ErrForaDoPeriodo
+		case !p.permitePortaria(portaria):
+			if motivo == storage.ErrNotFound {
+				motivo = ErrPortaria
+			}
+		default:
+			return p, nil
+		}
+	}
+	return Pass{}, motivo
+}
+
+// Entry registra a primeira entrada da placa com o passe vigente
+func (m *Manager) Entry(placa, portaria string, t time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	p, err := m.Find(placa, portaria, t)
+	if err != nil || !p.Entrada.IsZero() {
+		return
+	}
+	p.Entrada = t
+	if err := m.docs.Put(colecaoPasses, p.ID, p); err != nil {
+		log.Log(logService, "Erro ao registrar entrada do passe ", p.ID, ": ", err)
+	}
+}
+
+// Exit encerra os passes de uso único da placa que registraram entrada
+func (m *Manager) Exit(placa string, t time.Time) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	passes, err := m.lista(placa)
+	if err != nil {
+		log.Log(logService, "Erro ao consultar passes da placa ", placa, ": ", err)
+		return
+	}
+	for _, p := range passes {
+		if !p.UsoUnico || p.Status != Ativo || p.Entrada.IsZero() {
+			continue
+		}
+		p.Status = Utilizado
+		p.Saida = t
+		if err := m.docs.Put(colecaoPasses, p.ID, p); err != nil {
+			log.Log(logService, "Erro ao encerrar passe ", p.ID, ": ", err)
+			continue
+		}
+		log.Log(logService, "Passe de uso único ", p.ID, " da placa ", p.Placa, " encerrado pela saída")
+	}
+}
@@ -0,0 +1,193 @@
+package plate
+
+import (
+	"time"
+)
+
+const (
+	// Valores utilizados pelo Matcher quando não configurados
+	ConfiancaMinimaPadrao  = 0.8
+	ConfiancaRevisaoPadrao = 0.95
+
+	custoConfusao = 0.25 // custo da troca entre caracteres confundíveis, as demais custam 1
+	pesoTempo     = 0.1  // redução máxima da confiança pela distância no tempo
+)
+
+// confusoes lista os caracteres que o reconhecimento costuma trocar
+var confusoes = map[[2]byte]bool{
+	{'0', 'O'}: true, {'0', 'D'}: true, {'0', 'Q'}: true, {'1', 'I'}: true,
+	{'8', 'B'}: true, {'5', 'S'}: true, {'2', 'Z'}: true, {'6', 'G'}: true,
+}
+
+// custoTroca retorna o custo de substituir o caractere a por b
+func custoTroca(a, b byte) float64 {
+	switch {
+	case a == b:
+		return 0
+	case confusoes[[2]byte{a, b}] || confusoes[[2]byte{b, a}]:
+		return custoConfusao
+	}
+	return 1
+}
+
+// Similarity compara duas placas pela distância de edição, em que as trocas
+// entre caracteres confundíveis (0/O, 1/I, 8/B...) custam menos. As placas
+// são comparadas normalizadas e pela chave, de forma que as formas antiga e
+// Mercosul da mesma placa são idênticas. Retorna um valor entre 0
+// (diferentes) e 1 (idênticas)
+func Similarity(a, b string) float64 {
+	if Equal(a, b) {
+		return 1
+	}
+	s := distancia(Normalize(a), Normalize(b))
+	if k := distancia(Key(a), Key(b)); k > s {
+		s = k
+	}
+	return s
+}
+
+// distancia retorna a similaridade das placas normalizadas a partir da
+// distância de edição com os custos de custoTroca
+func distancia(a, b string) float64 {
+	maior := len(a)
+	if len(b) > maior {
+		maior = len(b)
+	}
+	if maior == 0 {
+		return 1
+	}
+
+	anterior := make([]float64, len(b)+1)
+	atual := make([]float64, len(b)+1)
+	for j := range anterior {
+		anterior[j] = float64(j)
+	}
+	for i := 1; i <= len(a); i++ {
+		atual[0] = float64(i)
+		for j := 1; j <= len(b); j++ {
+			atual[j] = minimo(anterior[j]+1, atual[j-1]+1, anterior[j-1]+custoTroca(a[i-1], b[j-1]))
+		}
+		anterior, atual = atual, anterior
+	}
+	return 1 - anterior[len(b)]/float64(maior)
+}
+
+// confundiveis verifica se as placas diferem apenas por trocas entre
+// caracteres confundíveis, nas formas normalizadas ou pela chave
+func confundiveis(a, b string) bool {
+	return soConfusoes(Normalize(a), Normalize(b)) || soConfusoes(Key(a), Key(b))
+}
+
+// soConfusoes verifica se as placas têm o mesmo tamanho e todos os caracteres
+// diferentes são confundíveis
+func soConfusoes(a, b string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := 0; i < len(a); i++ {
+		if custoTroca(a[i], b[i]) > custoConfusao {
+			return false
+		}
+	}
+	return true
+}
+
+// minimo retorna o menor dos valores
+func minimo(valores ...float64) float64 {
+	m := valores[0]
+	for _, v := range valores[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// Candidate representa uma leitura anterior com a qual a placa pode ser pareada
+type Candidate struct {
+	Placa string
+	Tempo time.Time
+}
+
+// Match é o resultado do pareamento de uma leitura com os candidatos
+type Match struct {
+	Indice    int     // índice do candidato escolhido, -1 sem correspondência
+	Placa     string  // placa do candidato escolhido
+	Confianca float64 // 1 para a mesma placa
+	Revisar   bool    // confiança abaixo de Revisao, o pareamento deve ser revisado
+}
+
+// Matcher pareia leituras da mesma placa com erros de reconhecimento,
+// pontuando os candidatos pela similaridade dos caracteres e pela distância
+// no tempo. Apenas as trocas entre caracteres confundíveis são toleradas:
+// placas que diferem em qualquer outro caractere são de outro veículo.
+// Campos zerados utilizam os valores padrão
+type Matcher struct {
+	Minima  float64       // confiança mínima para aceitar um candidato
+	Revisao float64       // confiança abaixo da qual o pareamento é marcado para revisão
+	Janela  time.Duration // distância no tempo em que a confiança atinge a redução máxima (0 desconsidera o tempo)
+}
+
+// comPadroes retorna o Matcher com os valores padrão nos campos zerados
+func (m Matcher) comPadroes() Matcher {
+	if m.Minima <= 0 {
+		m.Minima = ConfiancaMinimaPadrao
+	}
+	if m.Revisao <= 0 {
+		m.Revisao = ConfiancaRevisaoPadrao
+	}
+	return m
+}
+
+// Score retorna a confiança de que a leitura da placa no tempo t e o
+// candidato são o mesmo veículo. Leituras com a mesma chave têm confiança 1 e
+// leituras com trocas além das confundíveis têm confiança 0. As demais perdem
+// até pesoTempo da similaridade conforme a distância no tempo
+func (m Matcher) Score(placa string, t time.Time, c Candidate) float64 {
+	s := Similarity(placa, c.Placa)
+	if s == 1 {
+		return s
+	}
+	if !confundiveis(placa, c.Placa) {
+		return 0
+	}
+	if m.Janela <= 0 {
+		return s
+	}
+
+	dt := t.Sub(c.Tempo)
+	if dt < 0 {
+		dt = -dt
+	}
+	proporcao := float64(dt) / float64(m.Janela)
+	if proporcao > 1 {
+		proporcao = 1
+	}
+	return s * (1 - pesoTempo*proporcao)
+}
+
+// Best retorna o candidato com a maior confiança para a leitura da placa no
+// tempo t. Entre candidatos com a mesma confiança é escolhido o mais próximo
+// no tempo. Retorna Indice -1 caso nenhum atinja a confiança mínima
+func (m Matcher) Best(placa string, t time.Time, candidatos []Candidate) Match {
+	m = m.comPadroes()
+
+	melhor := Match{Indice: -1}
+	var melhorDt time.Duration
+	for i, c := range candidatos {
+		s := m.Score(placa, t, c)
+		if s < m.Minima {
+			continue
+		}
+		dt := t.Sub(c.Tempo)
+		if dt < 0 {
+			dt = -dt
+		}
+		if melhor.Indice < 0 || s > melhor.Confianca || (s == melhor.Confianca && dt < melhorDt) {
+			melhor = Match{Indice: i, Placa: c.Placa, Confianca: s}
+			melhorDt = dt
+		}
+	}
+	melhor.Revisar = melhor.Indice >= 0 && melhor.Confianca < m.Revisao
+	return melhor
+}
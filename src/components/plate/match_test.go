@@ -0,0 +1,127 @@
+package plate
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// proximo verifica se os valores são iguais, desconsiderando o arredondamento
+func proximo(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSimilarity(t *testing.T) {
+	casos := []struct {
+		a, b string
+		quer float64
+	}{
+		{"ABC1234", "ABC1234", 1},
+		{"ABC1D23", "ABC1323", 1},          // formas antiga e Mercosul da mesma placa
+		{"abc-1234", "ABC1234", 1},         // normalização
+		{"EXI7254", "EX17254", 1 - 0.25/7}, // I/1 confundíveis
+		{"OBC1234", "0BC1234", 1 - 0.25/7}, // O/0 confundíveis
+		{"ABC1234", "ABC1284", 1 - 1.0/7},  // troca comum
+		{"ABC1234", "ABC123", 1 - 1.0/7},   // caractere não lido
+		{"ABC1234", "AB8C1234", 1 - 1.0/8}, // caractere a mais
+		{"EXI7254", "EX1725", 1 - 1.25/7},  // confusão e caractere não lido
+		{"ABC1234", "XYZ9876", 0},          // placas diferentes
+		{"BBC1234", "8BC1234", 1 - 0.25/7}, // B/8 confundíveis
+		{"ABC1234", "", 0},                 // leitura vazia
+	}
+	for _, c := range casos {
+		if got := Similarity(c.a, c.b); !proximo(got, c.quer) {
+			t.Errorf("Similarity(%q, %q) = %.4f, esperado %.4f", c.a, c.b, got, c.quer)
+		}
+		if got := Similarity(c.b, c.a); !proximo(got, c.quer) {
+			t.Errorf("Similarity(%q, %q) = %.4f, esperado %.4f (simetria)", c.b, c.a, got, c.quer)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	agora := time.Now()
+	m := Matcher{Janela: 10 * time.Minute}
+	s := Similarity("EXI7254", "EX17254")
+
+	casos := []struct {
+		nome  string
+		m     Matcher
+		placa string
+		dt    time.Duration
+		quer  float64
+	}{
+		{"mesma placa não perde confiança no tempo", m, "EXI7254", time.Hour, 1},
+		{"sem distância no tempo", m, "EX17254", 0, s},
+		{"metade da janela", m, "EX17254", 5 * time.Minute, s * (1 - pesoTempo/2)},
+		{"fim da janela", m, "EX17254", 10 * time.Minute, s * (1 - pesoTempo)},
+		{"após a janela a redução é limitada", m, "EX17254", time.Hour, s * (1 - pesoTempo)},
+		{"leitura anterior ao candidato", m, "EX17254", -5 * time.Minute, s * (1 - pesoTempo/2)},
+		{"sem janela o tempo é desconsiderado", Matcher{}, "EX17254", time.Hour, s},
+		{"troca comum tem confiança 0", m, "EXI7284", 0, 0},
+		{"caractere a mais tem confiança 0", Matcher{}, "EXI72554", 0, 0},
+	}
+	for _, c := range casos {
+		got := c.m.Score(c.placa, agora.Add(c.dt), Candidate{Placa: "EXI7254", Tempo: agora})
+		if !proximo(got, c.quer) {
+			t.Errorf("%s: Score = %.4f, esperado %.4f", c.nome, got, c.quer)
+		}
+	}
+}
+
+func TestBest(t *testing.T) {
+	agora := time.Now()
+	s := Similarity("EXI7254", "EX17254") // 0.964
+	candidatos := []Candidate{
+		{Placa: "ABC1234", Tempo: agora.Add(-time.Minute)},
+		{Placa: "EXI7254", Tempo: agora.Add(-time.Hour)},
+	}
+
+	casos := []struct {
+		nome       string
+		m          Matcher
+		placa      string
+		candidatos []Candidate
+		indice     int
+		revisar    bool
+	}{
+		{"mesma placa", Matcher{}, "EXI7254", candidatos, 1, false},
+		{"forma Mercosul da mesma placa", Matcher{}, "ABC1C34", candidatos, 0, false},
+		{"leitura com confusão acima da revisão padrão", Matcher{}, "EX17254", candidatos, 1, false},
+		{"confiança igual à mínima é aceita", Matcher{Minima: s}, "EX17254", candidatos, 1, false},
+		{"confiança abaixo da mínima", Matcher{Minima: s + 1e-9}, "EX17254", candidatos, -1, false},
+		{"confiança igual à revisão não é revisada", Matcher{Revisao: s}, "EX17254", candidatos, 1, false},
+		{"confiança abaixo da revisão", Matcher{Revisao: s + 1e-9}, "EX17254", candidatos, 1, true},
+		{"duas confusões são aceitas para revisão", Matcher{}, "EX17Z54", candidatos, 1, true},
+		{"troca comum é outra placa", Matcher{}, "EXI7284", candidatos, -1, false},
+		{"troca comum com mínima reduzida é outra placa", Matcher{Minima: 0.1}, "EXI7284", candidatos, -1, false},
+		{"último dígito diferente é outra placa", Matcher{}, "ABC1235", candidatos, -1, false},
+		{"caractere não lido não é pareado", Matcher{}, "EXI725", candidatos, -1, false},
+		{"confusão e troca comum", Matcher{}, "EX17284", candidatos, -1, false},
+		{"sem candidatos", Matcher{}, "EXI7254", nil, -1, false},
+		{"distância no tempo abaixo da mínima", Matcher{Minima: 0.9, Janela: time.Minute}, "EX17254", candidatos, -1, false},
+	}
+	for _, c := range casos {
+		got := c.m.Best(c.placa, agora, c.candidatos)
+		if got.Indice != c.indice || got.Revisar != c.revisar {
+			t.Errorf("%s: Best = %+v, esperado índice %d e revisar %v", c.nome, got, c.indice, c.revisar)
+			continue
+		}
+		if got.Indice >= 0 && got.Placa != c.candidatos[got.Indice].Placa {
+			t.Errorf("%s: placa %q não corresponde ao candidato %d", c.nome, got.Placa, got.Indice)
+		}
+	}
+}
+
+func TestBestEmpate(t *testing.T) {
+	agora := time.Now()
+	candidatos := []Candidate{
+		{Placa: "EXI7254", Tempo: agora.Add(-2 * time.Hour)},
+		{Placa: "EXI7254", Tempo: agora.Add(-10 * time.Minute)},
+		{Placa: "EXI7254", Tempo: agora.Add(-time.Hour)},
+	}
+	// Com a mesma confiança é escolhido o candidato mais próximo no tempo
+	if got := (Matcher{}).Best("EX17254", agora, candidatos); got.Indice != 1 {
+		t.Errorf("Best = %+v, esperado o candidato mais recente (1)", got)
+	}
+}
@@ -0,0 +1,138 @@
+// O pacote plate implementa a normalização e a validação das placas
+// brasileiras nos formatos antigo (ABC-1234) e Mercosul (ABC1D23). Placas
+// convertidas para o Mercosul mantêm a mesma chave da placa antiga, de forma
+// que as duas formas sejam tratadas como o mesmo veículo
+package plate
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// Formato representa o padrão da placa
+type Formato string
+
+// Formatos de placa reconhecidos
+const (
+	Antigo   Formato = "antigo"   // ABC1234
+	Mercosul Formato = "mercosul" // ABC1D23
+	Invalido Formato = "invalido" // fora dos padrões, mantido como lido
+)
+
+var (
+	// ErrFormatoInvalido indica uma placa fora dos formatos antigo e Mercosul
+	ErrFormatoInvalido = errors.New("Placa inválida, utilize o formato ABC-1234 ou ABC1D23")
+	// ErrSemEquivalente indica uma placa Mercosul sem equivalente no formato
+	// antigo (quinto caractere entre K e Z)
+	ErrSemEquivalente = errors.New("Placa Mercosul sem equivalente no formato antigo")
+)
+
+// Normalize remove os espaços, hífens e pontos e converte a placa para
+// maiúsculas. É a forma gravada e exibida da placa
+func Normalize(placa string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(placa) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// Parse retorna o formato da placa normalizada
+func Parse(placa string) Formato {
+	p := Normalize(placa)
+	if len(p) != 7 || !letras(p[:3]) || !digitos(p[3:4]) || !digitos(p[5:]) {
+		return Invalido
+	}
+	switch {
+	case digitos(p[4:5]):
+		return Antigo
+	case letras(p[4:5]):
+		return Mercosul
+	}
+	return Invalido
+}
+
+// Validate verifica se a placa está em um dos formatos reconhecidos
+func Validate(placa string) error {
+	if Parse(placa) == Invalido {
+		return ErrFormatoInvalido
+	}
+	return nil
+}
+
+// ToMercosul converte a placa antiga para o formato Mercosul. Placas Mercosul
+// são retornadas normalizadas
+func ToMercosul(placa string) (string, error) {
+	p := Normalize(placa)
+	switch Parse(p) {
+	case Mercosul:
+		return p, nil
+	case Antigo:
+		return p[:4] + string('A'+p[4]-'0') + p[5:], nil
+	}
+	return "", ErrFormatoInvalido
+}
+
+// ToAntigo converte a placa Mercosul para o formato antigo. Placas antigas
+// são retornadas normalizadas
+func ToAntigo(placa string) (string, error) {
+	p := Normalize(placa)
+	switch Parse(p) {
+	case Antigo:
+		return p, nil
+	case Mercosul:
+		if p[4] > 'J' {
+			return "", ErrSemEquivalente
+		}
+		return p[:4] + string('0'+p[4]-'A') + p[5:], nil
+	}
+	return "", ErrFormatoInvalido
+}
+
+// Key retorna a chave canônica da placa, utilizada para gravar, comparar e
+// indexar placas: a forma antiga para as placas que possuem equivalente e a
+// placa normalizada nos demais casos
+func Key(placa string) string {
+	if antiga, err := ToAntigo(placa); err == nil {
+		return antiga
+	}
+	return Normalize(placa)
+}
+
+// Equal verifica se as placas representam o mesmo veículo
+func Equal(a, b string) bool {
+	return Key(a) == Key(b)
+}
+
+// Format retorna a placa normalizada para exibição: ABC-1234 no formato
+// antigo e ABC1D23 no Mercosul
+func Format(placa string) string {
+	p := Normalize(placa)
+	if Parse(p) == Antigo {
+		return p[:3] + "-" + p[3:]
+	}
+	return p
+}
+
+// letras verifica se s contém apenas letras de A a Z
+func letras(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < 'A' || s[i] > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// digitos verifica se s contém apenas dígitos
+func digitos(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
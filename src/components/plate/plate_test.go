@@ -0,0 +1,121 @@
+package plate
+
+import "testing"
+
+func TestNormalize(t *testing.T) {
+	casos := []struct{ placa, quer string }{
+		{"abc-1234", "ABC1234"},
+		{" ABC 1D23 ", "ABC1D23"},
+		{"abc.1234", "ABC1234"},
+		{"", ""},
+	}
+	for _, c := range casos {
+		if got := Normalize(c.placa); got != c.quer {
+			t.Errorf("Normalize(%q) = %q, esperado %q", c.placa, got, c.quer)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	casos := []struct {
+		placa string
+		quer  Formato
+	}{
+		{"ABC1234", Antigo},
+		{"abc-1234", Antigo},
+		{"ABC1D23", Mercosul},
+		{"ABC1Z23", Mercosul},
+		{"AB12345", Invalido},
+		{"ABC12345", Invalido},
+		{"ABC123", Invalido},
+		{"EX17254", Invalido},
+		{"ABCD123", Invalido},
+		{"ABC1DD3", Invalido},
+		{"", Invalido},
+	}
+	for _, c := range casos {
+		if got := Parse(c.placa); got != c.quer {
+			t.Errorf("Parse(%q) = %s, esperado %s", c.placa, got, c.quer)
+		}
+		if err := Validate(c.placa); (err == nil) != (c.quer != Invalido) {
+			t.Errorf("Validate(%q) = %v", c.placa, err)
+		}
+	}
+}
+
+func TestConversao(t *testing.T) {
+	casos := []struct {
+		placa            string
+		mercosul, antigo string
+		errAntigo        error
+	}{
+		{"ABC1234", "ABC1C34", "ABC1234", nil},
+		{"ABC-1034", "ABC1A34", "ABC1034", nil},
+		{"ABC1934", "ABC1J34", "ABC1934", nil},
+		{"ABC1D23", "ABC1D23", "ABC1323", nil},
+		{"ABC1J23", "ABC1J23", "ABC1923", nil},
+		{"ABC1K23", "ABC1K23", "", ErrSemEquivalente},
+		{"ABC1Z23", "ABC1Z23", "", ErrSemEquivalente},
+		{"EX17254", "", "", ErrFormatoInvalido},
+	}
+	for _, c := range casos {
+		mercosul, err := ToMercosul(c.placa)
+		if c.mercosul == "" {
+			if err != ErrFormatoInvalido {
+				t.Errorf("ToMercosul(%q) = %q, %v, esperado ErrFormatoInvalido", c.placa, mercosul, err)
+			}
+		} else if err != nil || mercosul != c.mercosul {
+			t.Errorf("ToMercosul(%q) = %q, %v, esperado %q", c.placa, mercosul, err, c.mercosul)
+		}
+
+		antigo, err := ToAntigo(c.placa)
+		if err != c.errAntigo || antigo != c.antigo {
+			t.Errorf("ToAntigo(%q) = %q, %v, esperado %q, %v", c.placa, antigo, err, c.antigo, c.errAntigo)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	casos := []struct {
+		a, b  string
+		igual bool
+	}{
+		{"ABC1D23", "ABC1323", true},
+		{"ABC-1323", "abc1d23", true},
+		{"ABC1A34", "ABC1034", true},
+		{"ABC1K23", "ABC1K23", true},
+		{"ABC1K23", "ABC1023", false},
+		{"EXI7254", "EX17254", false},
+		{"ABC1234", "ABC1235", false},
+	}
+	for _, c := range casos {
+		if got := Equal(c.a, c.b); got != c.igual {
+			t.Errorf("Equal(%q, %q) = %v, esperado %v", c.a, c.b, got, c.igual)
+		}
+	}
+
+	chaves := []struct{ placa, quer string }{
+		{"ABC1D23", "ABC1323"},
+		{"abc-1323", "ABC1323"},
+		{"ABC1K23", "ABC1K23"},
+		{"EX17254", "EX17254"},
+	}
+	for _, c := range chaves {
+		if got := Key(c.placa); got != c.quer {
+			t.Errorf("Key(%q) = %q, esperado %q", c.placa, got, c.quer)
+		}
+	}
+}
+
+func TestFormat(t *testing.T) {
+	casos := []struct{ placa, quer string }{
+		{"abc1234", "ABC-1234"},
+		{"ABC1D23", "ABC1D23"},
+		{"ex17254", "EX17254"},
+	}
+	for _, c := range casos {
+		if got := Format(c.placa); got != c.quer {
+			t.Errorf("Format(%q) = %q, esperado %q", c.placa, got, c.quer)
+		}
+	}
+}
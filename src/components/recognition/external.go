@@ -0,0 +1,150 @@
+package recognition
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// resposta representa a saída dos motores externos: o formato próprio
+// (candidatos) ou o do OpenALPR (results)
+type resposta struct {
+	Candidatos []Candidate `json:"candidatos"`
+	Results    []struct {
+		Plate       string  `json:"plate"`
+		Confidence  float64 `json:"confidence"` // entre 0 e 100
+		Coordinates []struct {
+			X int `json:"x"`
+			Y int `json:"y"`
+		} `json:"coordinates"`
+		Candidates []struct {
+			Plate      string  `json:"plate"`
+			Confidence float64 `json:"confidence"`
+		} `json:"candidates"`
+	} `json:"results"`
+}
+
+// decodifica converte a saída do motor externo nos candidatos
+func decodifica(saida []byte) ([]Candidate, error) {
+	var r resposta
+	if err := json.Unmarshal(saida, &r); err != nil {
+		return nil, fmt.Errorf("resposta inválida do reconhecimento: %v", err)
+	}
+
+	candidatos := r.Candidatos
+	for _, res := range r.Results {
+		// O OpenALPR informa os quatro cantos da placa
+		var caixa Box
+		var x2, y2 int
+		for i, c := range res.Coordinates {
+			if i == 0 || c.X < caixa.X {
+				caixa.X = c.X
+			}
+			if i == 0 || c.Y < caixa.Y {
+				caixa.Y = c.Y
+			}
+			if c.X > x2 {
+				x2 = c.X
+			}
+			if c.Y > y2 {
+				y2 = c.Y
+			}
+		}
+		if len(res.Coordinates) > 0 {
+			caixa.Largura, caixa.Altura = x2-caixa.X, y2-caixa.Y
+		}
+
+		if len(res.Candidates) == 0 {
+			candidatos = append(candidatos, Candidate{Placa: res.Plate, Confianca: res.Confidence / 100, Caixa: caixa})
+		}
+		for _, c := range res.Candidates {
+			candidatos = append(candidatos, Candidate{Placa: c.Plate, Confianca: c.Confidence / 100, Caixa: caixa})
+		}
+	}
+	return ordena(candidatos), nil
+}
+
+// Command reconhece as placas executando um processo a cada imagem. O JPEG é
+// escrito na entrada padrão e os candidatos são lidos da saída padrão
+type Command struct {
+	comando    string
+	argumentos []string
+}
+
+// NewCommand cria o motor que executa o comando com os argumentos, como
+// "alpr -j -c br -" ou "python3 scripts/recognize.py"
+func NewCommand(comando string, argumentos ...string) *Command {
+	return &Command{comando: comando, argumentos: argumentos}
+}
+
+// Recognize executa o comando com a imagem. Ao fim do contexto o processo é
+// encerrado e o reconhecimento retorna sem aguardar os processos filhos que
+// ainda mantenham a saída aberta
+func (c *Command) Recognize(ctx context.Context, jpeg []byte) ([]Candidate, error) {
+	var saida, erros bytes.Buffer
+	cmd := exec.Command(c.comando, c.argumentos...)
+	cmd.Stdin = bytes.NewReader(jpeg)
+	cmd.Stdout = &saida
+	cmd.Stderr = &erros
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("%s: %v", c.comando, err)
+	}
+	fim := make(chan error, 1)
+	go func() { fim <- cmd.Wait() }()
+
+	select {
+	case err := <-fim:
+		if err != nil {
+			if msg := strings.TrimSpace(erros.String()); msg != "" {
+				return nil, fmt.Errorf("%s: %v: %s", c.comando, err, msg)
+			}
+			return nil, fmt.Errorf("%s: %v", c.comando, err)
+		}
+		return decodifica(saida.Bytes())
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		return nil, ctx.Err()
+	}
+}
+
+// HTTPRecognizer reconhece as placas através de um serviço auxiliar, que
+// recebe a imagem por POST (image/jpeg) e responde os candidatos em JSON
+type HTTPRecognizer struct {
+	endereco string
+	client   http.Client
+}
+
+// NewHTTPRecognizer cria o motor que envia as imagens ao endereço
+func NewHTTPRecognizer(endereco string) *HTTPRecognizer {
+	return &HTTPRecognizer{endereco: endereco}
+}
+
+// Recognize envia a imagem ao serviço. A requisição é cancelada ao fim do contexto
+func (h *HTTPRecognizer) Recognize(ctx context.Context, jpeg []byte) ([]Candidate, error) {
+	req, err := http.NewRequest(http.MethodPost, h.endereco, bytes.NewReader(jpeg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "image/jpeg")
+
+	resp, err := h.client.Do(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("serviço de reconhecimento respondeu %s", resp.Status)
+	}
+	return decodifica(body)
+}
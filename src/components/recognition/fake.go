@@ -0,0 +1,78 @@
+package recognition
+
+import (
+	"context"
+	"crypto/sha1"
+	"sync"
+	"time"
+)
+
+// Fake é um motor de reconhecimento determinístico para testes e
+// demonstrações: cada imagem retorna os candidatos cadastrados para ela, ou
+// os candidatos padrão, com atraso e erro configuráveis
+type Fake struct {
+	mutex      sync.Mutex
+	resultados map[[sha1.Size]byte][]Candidate
+	padrao     []Candidate
+	atraso     time.Duration
+	erro       error
+	chamadas   int
+}
+
+// NewFake cria o motor simulado que retorna os candidatos padrão para as
+// imagens não cadastradas
+func NewFake(padrao ...Candidate) *Fake {
+	return &Fake{resultados: map[[sha1.Size]byte][]Candidate{}, padrao: padrao}
+}
+
+// Set cadastra os candidatos retornados para a imagem
+func (f *Fake) Set(jpeg []byte, candidatos ...Candidate) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.resultados[sha1.Sum(jpeg)] = candidatos
+}
+
+// SetDelay define o tempo de cada reconhecimento, para simular motores lentos
+func (f *Fake) SetDelay(atraso time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.atraso = atraso
+}
+
+// SetError define o erro retornado pelos reconhecimentos (nil para nenhum)
+func (f *Fake) SetError(err error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.erro = err
+}
+
+// Calls retorna a quantidade de reconhecimentos executados
+func (f *Fake) Calls() int {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.chamadas
+}
+
+// Recognize retorna os candidatos cadastrados para a imagem após o atraso
+func (f *Fake) Recognize(ctx context.Context, jpeg []byte) ([]Candidate, error) {
+	f.mutex.Lock()
+	f.chamadas++
+	candidatos, ok := f.resultados[sha1.Sum(jpeg)]
+	if !ok {
+		candidatos = f.padrao
+	}
+	atraso, erro := f.atraso, f.erro
+	f.mutex.Unlock()
+
+	if atraso > 0 {
+		select {
+		case <-time.After(atraso):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	if erro != nil {
+		return nil, erro
+	}
+	return ordena(append([]Candidate{}, candidatos...)), nil
+}
@@ -0,0 +1,172 @@
+// O pacote recognition define o reconhecimento de placas. O motor de
+// reconhecimento é um Recognizer: um processo externo executado a cada
+// imagem (Command), um serviço HTTP auxiliar (HTTPRecognizer) ou o Fake
+// determinístico para testes. New cria o motor descrito na configuração,
+// limitado ao timeout e à quantidade de reconhecimentos simultâneos
+//
+// Os motores externos recebem a imagem JPEG (stdin do processo ou corpo do
+// POST) e respondem em JSON no formato
+//
+//	{"candidatos": [{"placa": "ABC1D23", "confianca": 0.93,
+//	  "caixa": {"x": 410, "y": 380, "largura": 120, "altura": 40}}]}
+//
+// A saída do OpenALPR (alpr -j) também é aceita, de forma que o alpr ou um
+// script Python como scripts/vehicle_detection.py possam ser utilizados
+package recognition
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	logService log.Service = "RECOGNITION"
+
+	// Tipos de motores de reconhecimento configuráveis
+	TipoComando = "comando"
+	TipoHTTP    = "http"
+	TipoFake    = "fake"
+
+	timeoutPadrao = 2 * time.Second
+)
+
+var (
+	// ErrSemPlaca indica que nenhuma placa foi reconhecida na imagem
+	ErrSemPlaca = errors.New("Nenhuma placa reconhecida na imagem")
+	// ErrTimeout indica que o reconhecimento excedeu o tempo configurado
+	ErrTimeout = errors.New("Tempo limite do reconhecimento excedido")
+
+	errTipoInvalido = errors.New("Tipo de reconhecimento inválido, utilize comando, http ou fake")
+	errSemComando   = errors.New("Comando do reconhecimento não configurado")
+	errSemEndereco  = errors.New("Endereço do reconhecimento não configurado")
+)
+
+// Box representa a região da placa na imagem, em pixels
+type Box struct {
+	X       int `json:"x"`
+	Y       int `json:"y"`
+	Largura int `json:"largura"`
+	Altura  int `json:"altura"`
+}
+
+// Candidate representa uma possível leitura da placa na imagem
+type Candidate struct {
+	Placa     string  `json:"placa"`
+	Confianca float64 `json:"confianca"` // entre 0 e 1
+	Caixa     Box     `json:"caixa"`
+}
+
+// Reconhecimento representa a placa reconhecida em um frame
+type Reconhecimento struct {
+	Placa     string  // texto da placa reconhecida
+	Confianca float64 // confiança do reconhecimento, entre 0 e 1
+	Caixa     Box     // região da placa no frame
+}
+
+// Recognizer representa o motor de reconhecimento de placas
+type Recognizer interface {
+	// Recognize retorna as placas candidatas na imagem JPEG, da maior para a
+	// menor confiança. Uma imagem sem placas retorna a lista vazia
+	Recognize(ctx context.Context, jpeg []byte) ([]Candidate, error)
+}
+
+// New cria o motor de reconhecimento descrito na configuração, limitado ao
+// timeout e à quantidade de reconhecimentos simultâneos configurados. O Fake
+// deve ser configurado explicitamente, o tipo vazio é inválido
+func New(cfg config.CfgJidosha) (Recognizer, error) {
+	var r Recognizer
+	switch cfg.Tipo {
+	case TipoComando:
+		if cfg.Comando == "" {
+			return nil, errSemComando
+		}
+		r = NewCommand(cfg.Comando, cfg.Argumentos...)
+	case TipoHTTP:
+		if cfg.Endereco == "" {
+			return nil, errSemEndereco
+		}
+		r = NewHTTPRecognizer(cfg.Endereco)
+	case TipoFake:
+		log.Log(logService, "Reconhecimento simulado, nenhuma placa será reconhecida")
+		r = NewFake()
+	default:
+		return nil, errTipoInvalido
+	}
+	return Limit(r, time.Duration(cfg.Timeout)*time.Millisecond, cfg.NumThreads), nil
+}
+
+// Best retorna o candidato de maior confiança como o reconhecimento da
+// imagem. Retorna ErrSemPlaca caso não haja candidatos
+func Best(candidatos []Candidate) (Reconhecimento, error) {
+	if len(candidatos) == 0 {
+		return Reconhecimento{}, ErrSemPlaca
+	}
+	melhor := candidatos[0]
+	for _, c := range candidatos[1:] {
+		if c.Confianca > melhor.Confianca {
+			melhor = c
+		}
+	}
+	return Reconhecimento{Placa: melhor.Placa, Confianca: melhor.Confianca, Caixa: melhor.Caixa}, nil
+}
+
+// ordena ordena os candidatos da maior para a menor confiança
+func ordena(candidatos []Candidate) []Candidate {
+	sort.SliceStable(candidatos, func(i, j int) bool {
+		return candidatos[i].Confianca > candidatos[j].Confianca
+	})
+	return candidatos
+}
+
+// limitado aplica o timeout e o limite de reconhecimentos simultâneos a um motor
+type limitado struct {
+	r       Recognizer
+	timeout time.Duration
+	vagas   chan struct{}
+}
+
+// Limit limita o motor a workers reconhecimentos simultâneos, cada um com no
+// máximo timeout, incluindo a espera por uma vaga. Valores zerados utilizam
+// 2 segundos e um reconhecimento por vez
+func Limit(r Recognizer, timeout time.Duration, workers int) Recognizer {
+	if timeout <= 0 {
+		timeout = timeoutPadrao
+	}
+	if workers <= 0 {
+		workers = 1
+	}
+	return &limitado{r: r, timeout: timeout, vagas: make(chan struct{}, workers)}
+}
+
+// Recognize aguarda uma vaga e executa o reconhecimento dentro do timeout.
+// Retorna ErrTimeout caso o tempo seja excedido
+func (l *limitado) Recognize(ctx context.Context, jpeg []byte) ([]Candidate, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	select {
+	case l.vagas <- struct{}{}:
+	case <-ctx.Done():
+		return nil, erroContexto(ctx)
+	}
+	defer func() { <-l.vagas }()
+
+	candidatos, err := l.r.Recognize(ctx, jpeg)
+	if err != nil && ctx.Err() != nil {
+		return nil, erroContexto(ctx)
+	}
+	return candidatos, err
+}
+
+// erroContexto converte o fim do contexto em ErrTimeout quando o prazo expirou
+func erroContexto(ctx context.Context) error {
+	if ctx.Err() == context.DeadlineExceeded {
+		return ErrTimeout
+	}
+	return ctx.Err()
+}
@@ -0,0 +1,291 @@
+// O pacote registry implementa o cadastro de pessoas e de seus veículos
+package registry
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "REGISTRY"
+
+	colecaoPessoas  = "pessoas"
+	colecaoVeiculos = "veiculos"
+)
+
+// Categoria representa o vínculo da pessoa com o campus
+type Categoria string
+
+// Categorias de pessoas cadastradas
+const (
+	Estudante   Categoria = "estudante"
+	Funcionario Categoria = "funcionario"
+	Visitante   Categoria = "visitante"
+	Fornecedor  Categoria = "fornecedor"
+)
+
+var (
+	errNomeVazio         = errors.New("O nome da pessoa é obrigatório")
+	errCategoriaInvalida = errors.New("Categoria inválida, utilize estudante, funcionario, visitante ou fornecedor")
+	errPlacaVazia        = errors.New("A placa do veículo é obrigatória")
+	errPessoaVazia       = errors.New("O veículo deve pertencer a uma pessoa cadastrada")
+
+	// ErrPlacaDeOutraPessoa indica que a placa já está cadastrada para outra pessoa
+	ErrPlacaDeOutraPessoa = errors.New("Placa já cadastrada para outra pessoa")
+)
+
+// Person representa uma pessoa cadastrada
+type Person struct {
+	ID        string    `json:"id"`
+	Nome      string    `json:"nome"`
+	RA        string    `json:"ra,omitempty"` // registro acadêmico ou funcional
+	Telefone  string    `json:"telefone,omitempty"`
+	Categoria Categoria `json:"categoria"`
+	Validade  time.Time `json:"validade"`         // data de expiração do cadastro (zero não expira)
+	Placas    []string  `json:"placas,omitempty"` // preenchido a partir dos veículos cadastrados
+}
+
+// Vehicle representa um veículo cadastrado, vinculado a uma pessoa
+type Vehicle struct {
+	Placa    string    `json:"placa"`
+	PessoaID string    `json:"pessoaId"`
+	Modelo   string    `json:"modelo,omitempty"`
+	Cor      string    `json:"cor,omitempty"`
+	Validade time.Time `json:"validade"` // data de expiração do cadastro (zero não expira)
+}
+
+// Expirado indica se o cadastro da pessoa expirou no tempo t
+func (p Person) Expirado(t time.Time) bool {
+	return !p.Validade.IsZero() && t.After(p.Validade)
+}
+
+// Expirado indica se o cadastro do veículo expirou no tempo t
+func (v Vehicle) Expirado(t time.Time) bool {
+	return !v.Validade.IsZero() && t.After(v.Validade)
+}
+
+// Validate verifica se os dados obrigatórios da pessoa são válidos
+func (p *Person) Validate() error {
+	if strings.TrimSpace(p.Nome) == "" {
+		return errNomeVazio
+	}
+	switch p.Categoria {
+	case Estudante, Funcionario, Visitante, Fornecedor:
+	default:
+		return errCategoriaInvalida
+	}
+	for i := range p.Placas {
+		p.Placas[i] = plate.Normalize(p.Placas[i])
+		if p.Placas[i] == "" {
+			return errPlacaVazia
+		}
+	}
+	return nil
+}
+
+// Validate verifica se os dados obrigatórios do veículo são válidos
+func (v *Vehicle) Validate() error {
+	v.Placa = plate.Normalize(v.Placa)
+	if v.Placa == "" {
+		return errPlacaVazia
+	}
+	if v.PessoaID == "" {
+		return errPessoaVazia
+	}
+	return nil
+}
+
+// Registry implementa o cadastro de pessoas e veículos sobre o storage
+type Registry struct {
+	docs storage.Documents
+}
+
+// New cria o cadastro que persiste os dados em docs
+func New(docs storage.Documents) *Registry {
+	return &Registry{docs: docs}
+}
+
+// Persons retorna todas as pessoas cadastradas
+func (r *Registry) Persons() ([]Person, error) {
+	pessoas := []Person{}
+	if err := r.docs.List(colecaoPessoas, &pessoas); err != nil {
+		return nil, err
+	}
+
+	veiculos, err := r.Vehicles()
+	if err != nil {
+		return nil, err
+	}
+	placas := map[string][]string{}
+	for _, v := range veiculos {
+		placas[v.PessoaID] = append(placas[v.PessoaID], v.Placa)
+	}
+	for i := range pessoas {
+		pessoas[i].Placas = placas[pessoas[i].ID]
+	}
+	return pessoas, nil
+}
+
+// Person retorna a pessoa pelo ID. Retorna storage.ErrNotFound caso não exista
+func (r *Registry) Person(id string) (Person, error) {
+	var p Person
+	if err := r.docs.Get(colecaoPessoas, id, &p); err != nil {
+		return p, err
+	}
+
+	veiculos, err := r.VehiclesOf(id)
+	if err != nil {
+		return p, err
+	}
+	for _, v := range veiculos {
+		p.Placas = append(p.Placas, v.Placa)
+	}
+	return p, nil
+}
+
+// SavePerson cria ou atualiza a pessoa. Um ID é gerado para novas pessoas e
+// as placas informadas são cadastradas como veículos da pessoa
+func (r *Registry) SavePerson(p *Person) error {
+	if err := p.Validate(); err != nil {
+		return err
+	}
+	if p.ID == "" {
+		p.ID = storage.NewID()
+	}
+
+	// Garante que as placas não pertencem a outra pessoa antes de gravar
+	for _, placa := range p.Placas {
+		if v, err := r.Vehicle(placa); err == nil && v.PessoaID != p.ID {
+			return ErrPlacaDeOutraPessoa
+		} else if err != nil && err != storage.ErrNotFound {
+			return err
+		}
+	}
+
+	// As placas são persistidas apenas como veículos
+	pessoa := *p
+	pessoa.Placas = nil
+	if err := r.docs.Put(colecaoPessoas, p.ID, pessoa); err != nil {
+		return err
+	}
+
+	for _, placa := range p.Placas {
+		if _, err := r.Vehicle(placa); err == nil {
+			continue
+		}
+		if err := r.docs.Put(colecaoVeiculos, plate.Key(placa), Vehicle{Placa: placa, PessoaID: p.ID}); err != nil {
+			return err
+		}
+	}
+
+	log.Log(logService, "Pessoa cadastrada: ", p.ID, " - ", p.Nome)
+	return nil
+}
+
+// DeletePerson remove a pessoa e os seus veículos
+func (r *Registry) DeletePerson(id string) error {
+	veiculos, err := r.VehiclesOf(id)
+	if err != nil {
+		return err
+	}
+
+	if err := r.docs.Delete(colecaoPessoas, id); err != nil {
+		return err
+	}
+	for _, v := range veiculos {
+		if err := r.removeVeiculo(v.Placa); err != nil && err != storage.ErrNotFound {
+			return err
+		}
+	}
+
+	log.Log(logService, "Pessoa removida: ", id)
+	return nil
+}
+
+// Vehicles retorna todos os veículos cadastrados
+func (r *Registry) Vehicles() ([]Vehicle, error) {
+	veiculos := []Vehicle{}
+	if err := r.docs.List(colecaoVeiculos, &veiculos); err != nil {
+		return nil, err
+	}
+	return veiculos, nil
+}
+
+// VehiclesOf retorna os veículos da pessoa, consultados no banco pelo pessoaId
+func (r *Registry) VehiclesOf(pessoaID string) ([]Vehicle, error) {
+	veiculos := []Vehicle{}
+	if err := r.docs.Where(colecaoVeiculos, "pessoaId", pessoaID, &veiculos); err != nil {
+		return nil, err
+	}
+	return veiculos, nil
+}
+
+// Vehicle retorna o veículo pela placa. Retorna storage.ErrNotFound caso não exista
+func (r *Registry) Vehicle(placa string) (Vehicle, error) {
+	var v Vehicle
+	err := r.docs.Get(colecaoVeiculos, plate.Key(placa), &v)
+	if err == storage.ErrNotFound && plate.Normalize(placa) != plate.Key(placa) {
+		// Veículos Mercosul gravados antes da chave canônica
+		err = r.docs.Get(colecaoVeiculos, plate.Normalize(placa), &v)
+	}
+	return v, err
+}
+
+// SaveVehicle cria ou atualiza o veículo. A pessoa do veículo deve existir.
+// Retorna ErrPlacaDeOutraPessoa caso a placa pertença a outra pessoa
+func (r *Registry) SaveVehicle(v *Vehicle) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+
+	var p Person
+	if err := r.docs.Get(colecaoPessoas, v.PessoaID, &p); err != nil {
+		return err
+	}
+	if atual, err := r.Vehicle(v.Placa); err == nil && atual.PessoaID != v.PessoaID {
+		return ErrPlacaDeOutraPessoa
+	} else if err != nil && err != storage.ErrNotFound {
+		return err
+	}
+
+	if err := r.docs.Put(colecaoVeiculos, plate.Key(v.Placa), v); err != nil {
+		return err
+	}
+
+	log.Log(logService, "Veículo cadastrado: ", v.Placa, " - pessoa: ", v.PessoaID)
+	return nil
+}
+
+// DeleteVehicle remove o veículo
+func (r *Registry) DeleteVehicle(placa string) error {
+	if err := r.removeVeiculo(placa); err != nil {
+		return err
+	}
+
+	log.Log(logService, "Veículo removido: ", placa)
+	return nil
+}
+
+// removeVeiculo remove o documento do veículo pela chave canônica ou, para
+// veículos gravados antes da chave canônica, pela placa normalizada
+func (r *Registry) removeVeiculo(placa string) error {
+	err := r.docs.Delete(colecaoVeiculos, plate.Key(placa))
+	if err == storage.ErrNotFound && plate.Normalize(placa) != plate.Key(placa) {
+		err = r.docs.Delete(colecaoVeiculos, plate.Normalize(placa))
+	}
+	return err
+}
+
+// Owner retorna a pessoa dona do veículo com a placa informada
+func (r *Registry) Owner(placa string) (Person, error) {
+	v, err := r.Vehicle(placa)
+	if err != nil {
+		return Person{}, err
+	}
+	return r.Person(v.PessoaID)
+}
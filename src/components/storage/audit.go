@@ -0,0 +1,42 @@
+package storage
+
+import (
+	"github.com/gustavolimam/control-access/src/components/audit"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+)
+
+// storeAuditado registra no registro de auditoria as gravações de eventos
+// realizadas pelo Store
+type storeAuditado struct {
+	Store
+}
+
+// RecordEntry salva o evento de entrada e registra a gravação na auditoria
+func (s storeAuditado) RecordEntry(event defaults.EventoVeiculo) error {
+	err := s.Store.RecordEntry(event)
+	auditaEvento(audit.AcaoEntrada, event, err)
+	return err
+}
+
+// RecordExit salva o evento de saída e registra a gravação na auditoria
+func (s storeAuditado) RecordExit(event defaults.EventoVeiculo) error {
+	err := s.Store.RecordExit(event)
+	auditaEvento(audit.AcaoSaida, event, err)
+	return err
+}
+
+// auditaEvento registra a gravação do evento e o seu resultado
+func auditaEvento(acao string, event defaults.EventoVeiculo, err error) {
+	resultado := "ok"
+	if err != nil {
+		resultado = err.Error()
+	}
+	audit.Record(audit.Entry{
+		Ator:      audit.AtorSistema,
+		Acao:      acao,
+		Recurso:   "registros/" + event.Placa,
+		Origem:    event.Portaria,
+		Resultado: resultado,
+		Depois:    audit.Valor(event),
+	})
+}
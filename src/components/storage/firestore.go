@@ -0,0 +1,450 @@
+package storage
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"golang.org/x/net/context"
+
+	firebase "firebase.google.com/go"
+	"google.golang.org/api/option"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	colecaoRegistros = "registro-veiculos" // prefixo das coleções diárias de registros
+	formatoColecao   = "-2006-01-02"       // sufixo com a data de cada coleção
+	diasVisitaAberta = 7                   // dias anteriores consultados na busca por visitas abertas
+)
+
+// FirestoreStore implementa o Store utilizando o Firestore
+type FirestoreStore struct {
+	client *firestore.Client
+}
+
+// NewFirestore cria a conexão com o Firestore a partir do arquivo de credenciais
+func NewFirestore(credentialsFile string) (*FirestoreStore, error) {
+	// Inicializando o Firebase
+	opt := option.WithCredentialsFile(credentialsFile)
+	app, err := firebase.NewApp(context.Background(), nil, opt)
+	if err != nil {
+		log.Log(logService, "Erro inicializar app:", err)
+		return nil, err
+	}
+
+	// Criando a conexão com o banco Firestore
+	client, err := app.Firestore(context.Background())
+	if err != nil {
+		log.Log(logService, "Erro ao inicializar o Firestore: ", err)
+		return nil, err
+	}
+
+	return &FirestoreStore{client: client}, nil
+}
+
+// colecao retorna a coleção diária de registros referente ao tempo t
+func (s *FirestoreStore) colecao(t time.Time) *firestore.CollectionRef {
+	return s.client.Collection(colecaoRegistros + t.Format(formatoColecao))
+}
+
+// leitor executa as consultas ao Firestore, dentro ou fora de uma transação
+type leitor func(q firestore.Query) ([]*firestore.DocumentSnapshot, error)
+
+// leituraDireta executa a consulta fora de transação
+func leituraDireta(q firestore.Query) ([]*firestore.DocumentSnapshot, error) {
+	return q.Documents(context.Background()).GetAll()
+}
+
+// leituraTransacao executa as consultas na transação tx
+func leituraTransacao(tx *firestore.Transaction) leitor {
+	return func(q firestore.Query) ([]*firestore.DocumentSnapshot, error) {
+		return tx.Documents(q).GetAll()
+	}
+}
+
+// RecordEntry envia os dados de evento de entrada para o Firestore. A leitura
+// das visitas abertas e a gravação são feitas em uma transação, de forma que
+// eventos simultâneos da mesma placa não se sobreponham
+func (s *FirestoreStore) RecordEntry(event defaults.EventoVeiculo) error {
+	log.Log(logService, "Enviando registro de entrada de veiculo para base Firestore")
+
+	err := s.client.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		le := leituraTransacao(tx)
+
+		// A entrada é gravada na coleção do dia do evento
+		if gravado, err := s.eventoGravado(le, "EventoEntrada", event, 0); err != nil || gravado {
+			return err
+		}
+
+		if entradaNegada(event) {
+			registro := novaEntrada(event, false)
+			return tx.Set(s.documento(event), &registro)
+		}
+
+		registros, refs, err := s.visitasPlaca(le, event.Placa, event.Tempo)
+		if err != nil {
+			return err
+		}
+
+		// Encerra as visitas que ficaram abertas, a saída anterior não foi registrada
+		duplicada := false
+		for i, r := range registros {
+			if !r.Aberto() || r.Tempo.After(event.Tempo) {
+				continue
+			}
+			duplicada = true
+			log.Log(logService, "Nova entrada da placa ", event.Placa, " com visita aberta desde ", r.Tempo)
+			if err := tx.Set(refs[i], map[string]interface{}{"Status": StatusSemSaida}, firestore.MergeAll); err != nil {
+				return err
+			}
+		}
+
+		registro := novaEntrada(event, duplicada)
+		return tx.Set(s.documento(event), &registro)
+	})
+	if err != nil {
+		log.Log(logService, "Erro ao tentar enviar registro de entrada de veiculo para o firestore - ", err)
+	}
+	return err
+}
+
+// RecordExit envia os dados de evento de saída para o Firestore, pareando a
+// saída em uma transação com a leitura das visitas abertas
+func (s *FirestoreStore) RecordExit(event defaults.EventoVeiculo) error {
+	log.Log(logService, "Enviando registro de saída de veiculo para base Firestore")
+
+	return s.client.RunTransaction(context.Background(), func(ctx context.Context, tx *firestore.Transaction) error {
+		le := leituraTransacao(tx)
+
+		// A saída pode fechar visitas de até diasVisitaAberta dias anteriores
+		if gravado, err := s.eventoGravado(le, "EventoSaida", event, diasVisitaAberta); err != nil || gravado {
+			return err
+		}
+
+		registros, refs, err := s.visitasPlaca(le, event.Placa, event.Tempo)
+		if err != nil {
+			return err
+		}
+
+		// Sem visita aberta da mesma placa, a entrada pode ter sido lida com erro
+		if ultimaAberta(registros, event.Placa, event.Tempo) < 0 {
+			if registros, refs, err = s.visitasAbertas(le, event.Tempo); err != nil {
+				return err
+			}
+		}
+
+		m := pareiaSaida(registros, event)
+		if m.Indice < 0 {
+			log.Log(logService, "Saída sem entrada aberta, registrando saída órfã - placa: ", event.Placa)
+			orfa := novaSaidaOrfa(event)
+			return tx.Set(s.documento(event), &orfa)
+		}
+
+		r := registros[m.Indice]
+		r.fecha(event, m)
+		return tx.Set(refs[m.Indice], map[string]interface{}{
+			"TempoSaida":          r.TempoSaida,
+			"PortariaSaida":       r.PortariaSaida,
+			"Status":              r.Status,
+			"DecisaoSaida":        r.DecisaoSaida,
+			"PlacaSaida":          r.PlacaSaida,
+			"ConfiancaPareamento": r.ConfiancaPareamento,
+			"Revisar":             r.Revisar,
+			"EventoSaida":         r.EventoSaida,
+		}, firestore.MergeAll)
+	})
+}
+
+// documento retorna o novo documento do registro do evento, na coleção do
+// dia do evento. O ID do documento é o ID do evento, quando informado
+func (s *FirestoreStore) documento(event defaults.EventoVeiculo) *firestore.DocumentRef {
+	if event.ID == "" {
+		return s.colecao(event.Tempo).NewDoc()
+	}
+	return s.colecao(event.Tempo).Doc(event.ID)
+}
+
+// eventoGravado indica se o evento já foi gravado por um envio anterior,
+// buscando o seu ID no campo dos registros das coleções do dia do evento e
+// dos dias anteriores
+func (s *FirestoreStore) eventoGravado(le leitor, campo string, event defaults.EventoVeiculo, dias int) (bool, error) {
+	if event.ID == "" {
+		return false, nil
+	}
+	for d := 0; d <= dias; d++ {
+		docs, err := le(s.colecao(event.Tempo.AddDate(0, 0, -d)).Where(campo, "==", event.ID).Limit(1))
+		if err != nil {
+			return false, err
+		}
+		if len(docs) > 0 {
+			log.Log(logService, "Evento ", event.ID, " da placa ", event.Placa, " já registrado, ignorado")
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// visitasPlaca busca os registros da placa nas coleções diárias dos
+// diasVisitaAberta dias anteriores a t, retornando também a referência de
+// cada documento
+func (s *FirestoreStore) visitasPlaca(le leitor, placa string, t time.Time) ([]RegistroVeicular, []*firestore.DocumentRef, error) {
+	registros := []RegistroVeicular{}
+	refs := []*firestore.DocumentRef{}
+	for d := 0; d <= diasVisitaAberta; d++ {
+		docs, err := documentosPlaca(le, s.colecao(t.AddDate(0, 0, -d)).Query, placa)
+		if err != nil {
+			return nil, nil, err
+		}
+		if registros, refs, err = acrescentaRegistros(registros, refs, docs, nil); err != nil {
+			return nil, nil, err
+		}
+	}
+	return registros, refs, nil
+}
+
+// visitasAbertas busca as visitas abertas nas coleções diárias dos
+// diasVisitaAberta dias anteriores a t, retornando também a referência de
+// cada documento. Os registros gravados antes do pareamento de visitas não
+// possuem Status e não podem ser filtrados na consulta, por isso as coleções
+// são lidas por inteiro e filtradas por RegistroVeicular.Aberto
+func (s *FirestoreStore) visitasAbertas(le leitor, t time.Time) ([]RegistroVeicular, []*firestore.DocumentRef, error) {
+	registros := []RegistroVeicular{}
+	refs := []*firestore.DocumentRef{}
+	for d := 0; d <= diasVisitaAberta; d++ {
+		docs, err := le(s.colecao(t.AddDate(0, 0, -d)).Query)
+		if err != nil {
+			return nil, nil, err
+		}
+		if registros, refs, err = acrescentaRegistros(registros, refs, docs, RegistroVeicular.Aberto); err != nil {
+			return nil, nil, err
+		}
+	}
+	return registros, refs, nil
+}
+
+// acrescentaRegistros decodifica os documentos aceitos pela função f (nil
+// aceita todos), acrescentando os registros e as referências
+func acrescentaRegistros(registros []RegistroVeicular, refs []*firestore.DocumentRef,
+	docs []*firestore.DocumentSnapshot, f func(RegistroVeicular) bool) ([]RegistroVeicular, []*firestore.DocumentRef, error) {
+	for _, doc := range docs {
+		var r RegistroVeicular
+		if err := doc.DataTo(&r); err != nil {
+			return nil, nil, err
+		}
+		if f != nil && !f(r) {
+			continue
+		}
+		r.ID = doc.Ref.ID
+		registros = append(registros, r)
+		refs = append(refs, doc.Ref)
+	}
+	return registros, refs, nil
+}
+
+// documentosPlaca busca os documentos da placa pela chave canônica e, para
+// os registros gravados antes da chave canônica, pela placa normalizada
+func documentosPlaca(le leitor, query firestore.Query, placa string) ([]*firestore.DocumentSnapshot, error) {
+	docs, err := le(query.Where("Chave", "==", plate.Key(placa)))
+	if err != nil {
+		return nil, err
+	}
+	antigos, err := le(query.Where("Placa", "==", plate.Normalize(placa)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, doc := range antigos {
+		if _, err := doc.DataAt("Chave"); err == nil {
+			continue // já encontrado pela chave
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// FindRecords percorre as coleções diárias entre inicio e fim buscando os registros da placa
+func (s *FirestoreStore) FindRecords(placa string, inicio, fim time.Time) ([]RegistroVeicular, error) {
+	registros := []RegistroVeicular{}
+	primeiroDia := time.Date(inicio.Year(), inicio.Month(), inicio.Day(), 0, 0, 0, 0, inicio.Location())
+	for dia := primeiroDia; !dia.After(fim); dia = dia.AddDate(0, 0, 1) {
+		doDia, err := s.registrosDia(placa, dia, inicio, fim)
+		if err != nil {
+			return nil, err
+		}
+		registros = append(registros, doDia...)
+	}
+	return registros, nil
+}
+
+// PageRecords percorre as coleções diárias do dia do fim do período, ou do
+// registro apos, até o dia do início, encerrando a leitura assim que a
+// página é preenchida. Cada coleção diária é ordenada após a leitura, pois as
+// saídas órfãs são posicionadas pela saída
+func (s *FirestoreStore) PageRecords(placa string, inicio, fim time.Time, apos Posicao, limite int) ([]RegistroVeicular, error) {
+	if apos.ID != "" && apos.Tempo.Before(fim) {
+		fim = apos.Tempo
+	}
+
+	registros := []RegistroVeicular{}
+	primeiroDia := time.Date(inicio.Year(), inicio.Month(), inicio.Day(), 0, 0, 0, 0, inicio.Location())
+	ultimo := fim.In(inicio.Location())
+	ultimoDia := time.Date(ultimo.Year(), ultimo.Month(), ultimo.Day(), 0, 0, 0, 0, inicio.Location())
+	for dia := ultimoDia; !dia.Before(primeiroDia); dia = dia.AddDate(0, 0, -1) {
+		doDia, err := s.registrosDia(placa, dia, inicio, fim)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(doDia, func(i, j int) bool {
+			return antes(doDia[j], doDia[i])
+		})
+
+		for _, r := range doDia {
+			if apos.ID != "" && !antes(r, RegistroVeicular{ID: apos.ID, Tempo: apos.Tempo}) {
+				continue
+			}
+			registros = append(registros, r)
+			if limite > 0 && len(registros) == limite {
+				return registros, nil
+			}
+		}
+	}
+	return registros, nil
+}
+
+// registrosDia retorna os registros da placa da coleção do dia com tempo de
+// referência entre inicio e fim
+func (s *FirestoreStore) registrosDia(placa string, dia, inicio, fim time.Time) ([]RegistroVeicular, error) {
+	var (
+		docs []*firestore.DocumentSnapshot
+		err  error
+	)
+	if placa != "" {
+		docs, err = documentosPlaca(leituraDireta, s.colecao(dia).Query, placa)
+	} else {
+		docs, err = s.colecao(dia).Documents(context.Background()).GetAll()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	registros := []RegistroVeicular{}
+	for _, doc := range docs {
+		var r RegistroVeicular
+		if err := doc.DataTo(&r); err != nil {
+			return nil, err
+		}
+		if r.Referencia().Before(inicio) || r.Referencia().After(fim) {
+			continue
+		}
+		r.ID = doc.Ref.ID
+		registros = append(registros, r)
+	}
+	return registros, nil
+}
+
+// OpenVisits retorna os registros sem saída dos últimos diasVisitaAberta dias
+func (s *FirestoreStore) OpenVisits() ([]RegistroVeicular, error) {
+	fim := time.Now()
+	registros, err := s.FindRecords("", fim.AddDate(0, 0, -diasVisitaAberta), fim)
+	if err != nil {
+		return nil, err
+	}
+
+	abertos := []RegistroVeicular{}
+	for _, r := range registros {
+		if r.Aberto() {
+			abertos = append(abertos, r)
+		}
+	}
+	return abertos, nil
+}
+
+// Put grava o documento na coleção do Firestore. O documento é convertido
+// via JSON para que os nomes dos campos sigam as tags json da estrutura
+func (s *FirestoreStore) Put(colecao, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	campos := map[string]interface{}{}
+	if err := json.Unmarshal(data, &campos); err != nil {
+		return err
+	}
+
+	_, err = s.client.Collection(colecao).Doc(id).Set(context.Background(), campos)
+	return err
+}
+
+// Get lê o documento da coleção do Firestore
+func (s *FirestoreStore) Get(colecao, id string, v interface{}) error {
+	doc, err := s.client.Collection(colecao).Doc(id).Get(context.Background())
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(doc.Data())
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// List lê todos os documentos da coleção do Firestore
+func (s *FirestoreStore) List(colecao string, v interface{}) error {
+	docs, err := s.client.Collection(colecao).OrderBy(firestore.DocumentID, firestore.Asc).
+		Documents(context.Background()).GetAll()
+	if err != nil {
+		return err
+	}
+	return listaDocumentos(docs, v)
+}
+
+// Where lê os documentos da coleção do Firestore com o campo igual a valor
+func (s *FirestoreStore) Where(colecao, campo, valor string, v interface{}) error {
+	docs, err := s.client.Collection(colecao).Where(campo, "==", valor).
+		Documents(context.Background()).GetAll()
+	if err != nil {
+		return err
+	}
+	return listaDocumentos(docs, v)
+}
+
+// listaDocumentos decodifica os documentos do Firestore no slice apontado por v
+func listaDocumentos(docs []*firestore.DocumentSnapshot, v interface{}) error {
+	lista := make([]json.RawMessage, 0, len(docs))
+	for _, doc := range docs {
+		data, err := json.Marshal(doc.Data())
+		if err != nil {
+			return err
+		}
+		lista = append(lista, data)
+	}
+	return listaJSON(lista, v)
+}
+
+// Delete remove o documento da coleção do Firestore
+func (s *FirestoreStore) Delete(colecao, id string) error {
+	ref := s.client.Collection(colecao).Doc(id)
+	if _, err := ref.Get(context.Background()); status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	_, err := ref.Delete(context.Background())
+	return err
+}
+
+// Close encerra a conexão com o Firestore
+func (s *FirestoreStore) Close() error {
+	return s.client.Close()
+}
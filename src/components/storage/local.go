@@ -0,0 +1,411 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+)
+
+const (
+	timeoutAbertura = time.Second // tempo máximo de espera pelo lock do arquivo do banco
+)
+
+var (
+	bucketRegistros   = []byte("registros")
+	bucketReferencias = []byte("registros-referencia") // índice dos registros pelo tempo de referência
+)
+
+// LocalStore implementa o Store em um banco embarcado (BoltDB), para uso
+// em máquinas sem acesso à internet
+type LocalStore struct {
+	db *bolt.DB
+}
+
+// NewLocal abre (ou cria) o banco embarcado no arquivo informado
+func NewLocal(file string) (*LocalStore, error) {
+	db, err := bolt.Open(file, 0600, &bolt.Options{Timeout: timeoutAbertura})
+	if err != nil {
+		log.Log(logService, "Erro ao abrir banco local ", file, ": ", err)
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(bucketRegistros)
+		if err != nil {
+			return err
+		}
+		if tx.Bucket(bucketReferencias) != nil {
+			return nil
+		}
+		// Bancos criados antes do índice têm os registros indexados na abertura
+		indice, err := tx.CreateBucket(bucketReferencias)
+		if err != nil {
+			return err
+		}
+		log.Log(logService, "Criando índice de ", b.Stats().KeyN, " registros do banco local")
+		return b.ForEach(func(k, v []byte) error {
+			var r RegistroVeicular
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			return indice.Put(chaveReferencia(r.posicao()), k)
+		})
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &LocalStore{db: db}, nil
+}
+
+// chave converte o ID sequencial do registro para a chave do bucket. A chave é
+// big endian para que a iteração do bucket respeite a ordem de inserção
+func chave(id uint64) []byte {
+	k := make([]byte, 8)
+	binary.BigEndian.PutUint64(k, id)
+	return k
+}
+
+// chaveReferencia converte a posição do registro para a chave do índice de
+// referências, ordenada pelo tempo e desempatada pelo ID como o histórico
+func chaveReferencia(p Posicao) []byte {
+	k := make([]byte, 8, 8+len(p.ID))
+	if nano := p.Tempo.UnixNano(); nano > 0 {
+		binary.BigEndian.PutUint64(k, uint64(nano))
+	}
+	return append(k, p.ID...)
+}
+
+// tempoReferencia retorna o tempo de referência da chave do índice
+func tempoReferencia(k []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(k)))
+}
+
+// RecordEntry salva o evento de entrada no banco local
+func (s *LocalStore) RecordEntry(event defaults.EventoVeiculo) error {
+	log.Log(logService, "Salvando registro de entrada de veiculo no banco local")
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRegistros)
+		if gravado, err := contemEvento(b, event.ID); err != nil || gravado {
+			return err
+		}
+		if entradaNegada(event) {
+			return adicionaRegistro(b, novaEntrada(event, false))
+		}
+
+		// Encerra as visitas que ficaram abertas, a saída anterior não foi registrada
+		duplicada := false
+		chave := plate.Key(event.Placa)
+		err := alteraRegistros(b, func(r *RegistroVeicular) bool {
+			if r.chave() != chave || !r.Aberto() || r.Tempo.After(event.Tempo) {
+				return false
+			}
+			log.Log(logService, "Nova entrada da placa ", event.Placa, " com visita aberta desde ", r.Tempo)
+			r.Status = StatusSemSaida
+			duplicada = true
+			return true
+		})
+		if err != nil {
+			return err
+		}
+
+		return adicionaRegistro(b, novaEntrada(event, duplicada))
+	})
+}
+
+// RecordExit fecha o registro aberto mais recente da placa no banco local ou,
+// sem ele, o registro aberto pareado por similaridade da placa
+func (s *LocalStore) RecordExit(event defaults.EventoVeiculo) error {
+	log.Log(logService, "Salvando registro de saída de veiculo no banco local")
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRegistros)
+
+		registros := []RegistroVeicular{}
+		chaves := [][]byte{}
+		gravado := false
+		err := b.ForEach(func(k, v []byte) error {
+			var r RegistroVeicular
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			gravado = gravado || r.contemEvento(event.ID)
+			if r.Aberto() {
+				registros = append(registros, r)
+				chaves = append(chaves, k)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if gravado {
+			log.Log(logService, "Evento ", event.ID, " da placa ", event.Placa, " já registrado, ignorado")
+			return nil
+		}
+
+		m := pareiaSaida(registros, event)
+		if m.Indice < 0 {
+			log.Log(logService, "Saída sem entrada aberta, registrando saída órfã - placa: ", event.Placa)
+			return adicionaRegistro(b, novaSaidaOrfa(event))
+		}
+
+		r := registros[m.Indice]
+		r.fecha(event, m)
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		return b.Put(chaves[m.Indice], data)
+	})
+}
+
+// contemEvento indica se algum registro do bucket já contém o evento id
+func contemEvento(b *bolt.Bucket, id string) (bool, error) {
+	if id == "" {
+		return false, nil
+	}
+	gravado := false
+	err := b.ForEach(func(k, v []byte) error {
+		var r RegistroVeicular
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		if r.contemEvento(id) {
+			gravado = true
+			log.Log(logService, "Evento ", id, " da placa ", r.Placa, " já registrado, ignorado")
+		}
+		return nil
+	})
+	return gravado, err
+}
+
+// adicionaRegistro grava um novo registro no bucket com o próximo ID
+// sequencial e o inclui no índice de referências. O tempo de referência não
+// é alterado após a gravação, por isso o índice não é atualizado nas alterações
+func adicionaRegistro(b *bolt.Bucket, r RegistroVeicular) error {
+	id, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	r.ID = strconv.FormatUint(id, 10)
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	if err := b.Put(chave(id), data); err != nil {
+		return err
+	}
+	return b.Tx().Bucket(bucketReferencias).Put(chaveReferencia(r.posicao()), chave(id))
+}
+
+// alteraRegistros percorre os registros do bucket regravando aqueles em que a
+// função f retornar true
+func alteraRegistros(b *bolt.Bucket, f func(*RegistroVeicular) bool) error {
+	alterados := map[string][]byte{}
+	err := b.ForEach(func(k, v []byte) error {
+		var r RegistroVeicular
+		if err := json.Unmarshal(v, &r); err != nil {
+			return err
+		}
+		if !f(&r) {
+			return nil
+		}
+
+		data, err := json.Marshal(r)
+		if err != nil {
+			return err
+		}
+		alterados[string(k)] = data
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// O bolt não permite alterar o bucket durante o ForEach
+	for k, data := range alterados {
+		if err := b.Put([]byte(k), data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FindRecords retorna os registros da placa com entrada entre inicio e fim
+func (s *LocalStore) FindRecords(placa string, inicio, fim time.Time) ([]RegistroVeicular, error) {
+	chave := plate.Key(placa)
+	return s.filtra(func(r RegistroVeicular) bool {
+		return (placa == "" || r.chave() == chave) && !r.Referencia().Before(inicio) && !r.Referencia().After(fim)
+	})
+}
+
+// PageRecords percorre o índice de referências do fim do período, ou do
+// registro seguinte a apos, até o início, lendo apenas os registros retornados
+func (s *LocalStore) PageRecords(placa string, inicio, fim time.Time, apos Posicao, limite int) ([]RegistroVeicular, error) {
+	chave := plate.Key(placa)
+	primeira := chaveReferencia(Posicao{Tempo: fim.Add(time.Nanosecond)})
+	if apos.ID != "" {
+		if k := chaveReferencia(apos); bytes.Compare(k, primeira) < 0 {
+			primeira = k
+		}
+	}
+
+	registros := []RegistroVeicular{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRegistros)
+		c := tx.Bucket(bucketReferencias).Cursor()
+
+		// O cursor é posicionado na chave anterior à primeira
+		k, v := c.Seek(primeira)
+		if k == nil {
+			k, v = c.Last()
+		} else {
+			k, v = c.Prev()
+		}
+		for ; k != nil && !tempoReferencia(k).Before(inicio); k, v = c.Prev() {
+			data := b.Get(v)
+			if data == nil {
+				continue
+			}
+			var r RegistroVeicular
+			if err := json.Unmarshal(data, &r); err != nil {
+				return err
+			}
+			if placa != "" && r.chave() != chave {
+				continue
+			}
+			registros = append(registros, r)
+			if limite > 0 && len(registros) == limite {
+				return nil
+			}
+		}
+		return nil
+	})
+	return registros, err
+}
+
+// OpenVisits retorna os registros que ainda não possuem saída
+func (s *LocalStore) OpenVisits() ([]RegistroVeicular, error) {
+	return s.filtra(RegistroVeicular.Aberto)
+}
+
+// filtra percorre todos os registros retornando os aceitos pela função f
+func (s *LocalStore) filtra(f func(RegistroVeicular) bool) ([]RegistroVeicular, error) {
+	registros := []RegistroVeicular{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketRegistros).ForEach(func(k, v []byte) error {
+			var r RegistroVeicular
+			if err := json.Unmarshal(v, &r); err != nil {
+				return err
+			}
+			if f(r) {
+				registros = append(registros, r)
+			}
+			return nil
+		})
+	})
+	return registros, err
+}
+
+// Put grava o documento em JSON no bucket da coleção
+func (s *LocalStore) Put(colecao, id string, v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(colecao))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(id), data)
+	})
+}
+
+// Get lê o documento do bucket da coleção
+func (s *LocalStore) Get(colecao, id string, v interface{}) error {
+	return s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(colecao))
+		if b == nil {
+			return ErrNotFound
+		}
+		data := b.Get([]byte(id))
+		if data == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(data, v)
+	})
+}
+
+// List lê todos os documentos do bucket da coleção
+func (s *LocalStore) List(colecao string, v interface{}) error {
+	docs := []json.RawMessage{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(colecao))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, data []byte) error {
+			// data só é válido durante a transação
+			docs = append(docs, append(json.RawMessage(nil), data...))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return listaJSON(docs, v)
+}
+
+// Where lê os documentos do bucket da coleção com o campo igual a valor
+func (s *LocalStore) Where(colecao, campo, valor string, v interface{}) error {
+	docs := []json.RawMessage{}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(colecao))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, data []byte) error {
+			campos := map[string]interface{}{}
+			if err := json.Unmarshal(data, &campos); err != nil {
+				return err
+			}
+			if campos[campo] == valor {
+				docs = append(docs, append(json.RawMessage(nil), data...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	return listaJSON(docs, v)
+}
+
+// Delete remove o documento do bucket da coleção
+func (s *LocalStore) Delete(colecao, id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(colecao))
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+// Close fecha o arquivo do banco local
+func (s *LocalStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/plate"
+)
+
+const (
+	// LimitePadrao é a quantidade de registros por página quando não informada
+	LimitePadrao = 50
+	// LimiteMaximo é a maior quantidade de registros por página
+	LimiteMaximo = 500
+
+	periodoPadrao = 7 * 24 * time.Hour // período consultado quando o início não é informado
+)
+
+var (
+	// ErrCursorInvalido indica que o cursor de paginação não foi gerado por Query
+	ErrCursorInvalido = errors.New("Cursor de paginação inválido")
+	// ErrPeriodoInvalido indica que o início da consulta é posterior ao fim
+	ErrPeriodoInvalido = errors.New("Início do período posterior ao fim")
+)
+
+// Filtro define os critérios da consulta ao histórico de registros. Campos
+// vazios não restringem a consulta
+type Filtro struct {
+	Placa        string              // placa exata
+	PrefixoPlaca string              // início da placa
+	Portaria     string              // portaria de entrada ou de saída
+	Inicio       time.Time           // padrão: 7 dias antes do fim
+	Fim          time.Time           // padrão: agora
+	Tipo         defaults.TipoEvento // registros com entrada ou com saída
+	Status       string              // StatusAberta ou StatusFechada
+	Revisar      bool                // apenas registros marcados para revisão
+	Cursor       string              // Proximo da página anterior
+	Limite       int                 // registros por página, até LimiteMaximo (0 utiliza LimitePadrao)
+}
+
+// Pagina é o resultado de uma consulta ao histórico, do registro mais recente
+// para o mais antigo
+type Pagina struct {
+	Registros []RegistroVeicular `json:"registros"`
+	Proximo   string             `json:"proximo,omitempty"` // cursor da próxima página, vazio na última
+}
+
+// Query consulta o histórico de registros do store aplicando o filtro. Os
+// registros são lidos do store em lotes a partir do cursor, até que a página
+// seja preenchida ou o período termine
+func Query(s Store, f Filtro) (Pagina, error) {
+	if f.Fim.IsZero() {
+		f.Fim = time.Now()
+	}
+	if f.Inicio.IsZero() {
+		f.Inicio = f.Fim.Add(-periodoPadrao)
+	}
+	if f.Inicio.After(f.Fim) {
+		return Pagina{}, ErrPeriodoInvalido
+	}
+	if f.Limite <= 0 {
+		f.Limite = LimitePadrao
+	} else if f.Limite > LimiteMaximo {
+		f.Limite = LimiteMaximo
+	}
+
+	var apos Posicao
+	if f.Cursor != "" {
+		var err error
+		if apos.Tempo, apos.ID, err = decodificaCursor(f.Cursor); err != nil {
+			return Pagina{}, err
+		}
+	}
+
+	// Um registro além do limite indica que existe a próxima página
+	lote := f.Limite + 1
+	pagina := Pagina{Registros: []RegistroVeicular{}}
+	for {
+		registros, err := s.PageRecords(plate.Normalize(f.Placa), f.Inicio, f.Fim, apos, lote)
+		if err != nil {
+			return Pagina{}, err
+		}
+		for _, r := range registros {
+			if !f.aceita(r) {
+				continue
+			}
+			if len(pagina.Registros) == f.Limite {
+				ultimo := pagina.Registros[len(pagina.Registros)-1]
+				pagina.Proximo = codificaCursor(ultimo.Referencia(), ultimo.ID)
+				return pagina, nil
+			}
+			pagina.Registros = append(pagina.Registros, r)
+		}
+		if len(registros) < lote {
+			return pagina, nil
+		}
+		apos = registros[len(registros)-1].posicao()
+	}
+}
+
+// aceita verifica se o registro atende aos critérios do filtro
+func (f Filtro) aceita(r RegistroVeicular) bool {
+	if f.PrefixoPlaca != "" && !strings.HasPrefix(plate.Normalize(r.Placa), plate.Normalize(f.PrefixoPlaca)) {
+		return false
+	}
+
+	switch f.Tipo {
+	case defaults.Entrada:
+		if r.Tempo.IsZero() || (f.Portaria != "" && !strings.EqualFold(r.Portaria, f.Portaria)) {
+			return false
+		}
+	case defaults.Saida:
+		if r.TempoSaida.IsZero() || (f.Portaria != "" && !strings.EqualFold(r.PortariaSaida, f.Portaria)) {
+			return false
+		}
+	default:
+		if f.Portaria != "" && !strings.EqualFold(r.Portaria, f.Portaria) && !strings.EqualFold(r.PortariaSaida, f.Portaria) {
+			return false
+		}
+	}
+
+	if f.Revisar && !r.Revisar {
+		return false
+	}
+
+	switch f.Status {
+	case StatusAberta:
+		return r.Aberto()
+	case StatusFechada:
+		return !r.Aberto()
+	}
+	return true
+}
+
+// antes define a ordem do histórico pelo tempo de referência, desempatando
+// pelo ID para que a paginação seja estável
+func antes(a, b RegistroVeicular) bool {
+	ta, tb := a.Referencia(), b.Referencia()
+	if !ta.Equal(tb) {
+		return ta.Before(tb)
+	}
+	return a.ID < b.ID
+}
+
+// codificaCursor gera o cursor opaco que aponta para o último registro da página
+func codificaCursor(t time.Time, id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strconv.FormatInt(t.UnixNano(), 10) + "|" + id))
+}
+
+// decodificaCursor retorna o tempo e o ID do registro apontado pelo cursor
+func decodificaCursor(cursor string) (time.Time, string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", ErrCursorInvalido
+	}
+	partes := strings.SplitN(string(data), "|", 2)
+	if len(partes) != 2 {
+		return time.Time{}, "", ErrCursorInvalido
+	}
+	nano, err := strconv.ParseInt(partes[0], 10, 64)
+	if err != nil {
+		return time.Time{}, "", ErrCursorInvalido
+	}
+	return time.Unix(0, nano), partes[1], nil
+}
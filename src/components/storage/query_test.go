@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+)
+
+// gravaHistorico grava entradas de placas diferentes fora da ordem do tempo e
+// saídas órfãs, retornando a base dos tempos
+func gravaHistorico(t *testing.T, s *LocalStore) time.Time {
+	t.Helper()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+	minutos := []int{30, 0, 90, 60, 60, 15, 120, 45, 5, 75, 100, 1440}
+	for i, m := range minutos {
+		tipo := defaults.Entrada
+		if i%4 == 3 {
+			tipo = defaults.Saida // sem entrada, gera uma saída órfã
+		}
+		ev := evento(tipo, fmt.Sprintf("ABC%04d", i), base, m)
+		var err error
+		if tipo == defaults.Saida {
+			err = s.RecordExit(ev)
+		} else {
+			err = s.RecordEntry(ev)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return base
+}
+
+// paginas consulta todas as páginas do filtro, retornando os registros em ordem
+func paginas(t *testing.T, s Store, f Filtro) []RegistroVeicular {
+	t.Helper()
+	todos := []RegistroVeicular{}
+	for i := 0; ; i++ {
+		pagina, err := Query(s, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(pagina.Registros) > f.Limite {
+			t.Fatalf("página %d com %d registros, limite %d", i, len(pagina.Registros), f.Limite)
+		}
+		todos = append(todos, pagina.Registros...)
+		if pagina.Proximo == "" {
+			return todos
+		}
+		f.Cursor = pagina.Proximo
+	}
+}
+
+func TestQueryPaginacao(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := gravaHistorico(t, s)
+
+	casos := []struct {
+		nome   string
+		filtro Filtro
+		quer   int
+	}{
+		{"todos os registros", Filtro{}, 12},
+		{"período", Filtro{Inicio: base.Add(30 * time.Minute), Fim: base.Add(90 * time.Minute)}, 6},
+		{"filtro aplicado entre os lotes", Filtro{Tipo: defaults.Saida}, 3},
+		{"placa", Filtro{Placa: "ABC0004"}, 1},
+	}
+	for _, c := range casos {
+		for _, limite := range []int{1, 2, 5, LimiteMaximo} {
+			t.Run(fmt.Sprintf("%s com limite %d", c.nome, limite), func(t *testing.T) {
+				f := c.filtro
+				if f.Inicio.IsZero() {
+					f.Inicio = base
+				}
+				if f.Fim.IsZero() {
+					f.Fim = base.Add(48 * time.Hour)
+				}
+				f.Limite = limite
+
+				rs := paginas(t, s, f)
+				if len(rs) != c.quer {
+					t.Fatalf("%d registros, esperado %d: %+v", len(rs), c.quer, rs)
+				}
+				for i := 1; i < len(rs); i++ {
+					if !antes(rs[i], rs[i-1]) {
+						t.Errorf("registro %s após %s fora da ordem", rs[i].ID, rs[i-1].ID)
+					}
+				}
+				for _, r := range rs {
+					if !f.aceita(r) || r.Referencia().Before(f.Inicio) || r.Referencia().After(f.Fim) {
+						t.Errorf("registro %+v fora do filtro", r)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestLocalStoreIndiceReferencias(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := gravaHistorico(t, s)
+
+	// Simula um banco criado antes do índice de referências
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket(bucketReferencias)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	arquivo := s.db.Path()
+	s.Close()
+	s, err = NewLocal(arquivo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rs, err := s.PageRecords("", base, base.Add(48*time.Hour), Posicao{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 12 {
+		t.Fatalf("%d registros indexados, esperado 12", len(rs))
+	}
+
+	// A nova gravação é incluída no índice
+	if err := s.RecordEntry(evento(defaults.Entrada, "XYZ9876", base, 10)); err != nil {
+		t.Fatal(err)
+	}
+	rs, err = s.PageRecords("XYZ9876", base, base.Add(48*time.Hour), Posicao{}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rs) != 1 || rs[0].Placa != "XYZ9876" {
+		t.Errorf("registro gravado após a criação do índice = %+v", rs)
+	}
+}
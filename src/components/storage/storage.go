@@ -1,96 +1,337 @@
 package storage
 
 import (
-	"path"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"time"
 
-	"cloud.google.com/go/firestore"
+	"github.com/gustavolimam/control-access/src/components/config"
 	"github.com/gustavolimam/control-access/src/components/defaults"
 	"github.com/gustavolimam/control-access/src/components/log"
-	"golang.org/x/net/context"
+	"github.com/gustavolimam/control-access/src/components/plate"
+)
+
+const (
+	logService log.Service = "STORAGE"
 
-	firebase "firebase.google.com/go"
-	"google.golang.org/api/option"
+	// DriverFirestore seleciona o banco Firestore (necessita de internet)
+	DriverFirestore = "firestore"
+	// DriverLocal seleciona o banco embarcado em disco
+	DriverLocal = "local"
 )
 
+var (
+	errDriverInvalido      = errors.New("Driver de banco de dados inválido")
+	errStoreNaoConfigurado = errors.New("Banco de dados não configurado")
+
+	// ErrNotFound indica que o documento buscado não existe
+	ErrNotFound = errors.New("Documento não encontrado")
+
+	defaultStore Store
+)
+
+// Situação de um registro veicular (visita)
 const (
-	logService log.Service = "FIRESTORE"
+	StatusAberta    = "aberta"     // veículo entrou e ainda não saiu
+	StatusFechada   = "fechada"    // saída pareada com a entrada
+	StatusSaidaOrfa = "saida-orfa" // saída sem entrada aberta correspondente
+	StatusSemSaida  = "sem-saida"  // entrada encerrada por uma nova entrada da mesma placa
+	StatusNegada    = "negada"     // entrada com acesso negado, o veículo não entrou
 )
 
-// RegistroVeicular estrutura à ser enviado para o BD
+// RegistroVeicular estrutura à ser enviado para o BD. Cada registro representa
+// uma visita: a entrada de um veículo e a saída pareada a ela
 type RegistroVeicular struct {
-	Placa    string    `json:"placa,omitempty"`
-	Tempo    time.Time `json:"time,omitempty"`
-	Portaria string    `json:"portaria,omitempty"`
+	ID               string    `json:"id,omitempty" firestore:"-"`
+	Placa            string    `json:"placa,omitempty"`
+	Chave            string    `json:"chave,omitempty"` // chave canônica da placa (plate.Key)
+	Tempo            time.Time `json:"time,omitempty"`
+	Portaria         string    `json:"portaria,omitempty"`
+	TempoSaida       time.Time `json:"tempoSaida,omitempty"`
+	PortariaSaida    string    `json:"portariaSaida,omitempty"`
+	Status           string    `json:"status,omitempty"`
+	EntradaDuplicada bool      `json:"entradaDuplicada,omitempty"` // entrada ocorreu com outra visita da placa aberta
+
+	PlacaSaida          string  `json:"placaSaida,omitempty"`          // leitura da saída, quando diferente da entrada
+	ConfiancaPareamento float64 `json:"confiancaPareamento,omitempty"` // confiança do pareamento da saída com a entrada
+	Revisar             bool    `json:"revisar,omitempty"`             // leitura ou pareamento com baixa confiança, deve ser revisado
+
+	DecisaoEntrada *defaults.DecisaoAcesso `json:"decisaoEntrada,omitempty"`
+	DecisaoSaida   *defaults.DecisaoAcesso `json:"decisaoSaida,omitempty"`
+
+	// IDs dos eventos gravados no registro, utilizados para ignorar os reenvios
+	EventoEntrada string `json:"eventoEntrada,omitempty"`
+	EventoSaida   string `json:"eventoSaida,omitempty"`
 }
 
-// SendEntryToDB função responsável por criar conexão com o banco e enviar os dados de Evento de Entrada.
-func SendEntryToDB(event defaults.EventoVeiculo) error {
-	log.Log(logService, "Enviando registro de entrada de veiculo para base Firestore")
+// Aberto indica se o veículo ainda não registrou saída. Registros sem Status
+// foram gravados antes do pareamento de visitas e são avaliados pela saída
+func (r RegistroVeicular) Aberto() bool {
+	if r.Status == "" {
+		return r.TempoSaida.IsZero()
+	}
+	return r.Status == StatusAberta
+}
 
-	// Retorna a data atual
-	tempo := time.Now().Format("-2006-01-02")
+// contemEvento indica se o evento id já foi gravado no registro por um envio anterior
+func (r RegistroVeicular) contemEvento(id string) bool {
+	return id != "" && (r.EventoEntrada == id || r.EventoSaida == id)
+}
 
-	// Inicializando o Firebase
-	opt := option.WithCredentialsFile(path.Join("util", "controle-acesso-port-firebase-adminsdk-ts97s-dec0edb44a.json"))
-	app, err := firebase.NewApp(context.Background(), nil, opt)
-	if err != nil {
-		return err
+// chave retorna a chave canônica da placa do registro. Registros gravados
+// antes da chave canônica a calculam a partir da placa
+func (r RegistroVeicular) chave() string {
+	if r.Chave != "" {
+		return r.Chave
 	}
+	return plate.Key(r.Placa)
+}
 
-	// Criando a conexão com o banco Firestore
-	client, err := app.Firestore(context.Background())
-	if err != nil {
-		client.Close()
-		return err
+// Referencia retorna o tempo que posiciona o registro no histórico: a entrada,
+// ou a saída no caso de saídas órfãs
+func (r RegistroVeicular) Referencia() time.Time {
+	if r.Tempo.IsZero() {
+		return r.TempoSaida
 	}
+	return r.Tempo
+}
 
-	// Enviando informação para o Database - Firestore
-	_, _, err = client.Collection("registro-veiculos"+tempo).Add(context.Background(), &RegistroVeicular{Placa: event.Placa,
-		Tempo: event.Tempo, Portaria: event.Portaria})
-	if err != nil {
-		log.Log(logService, "Erro ao tentar enviar registro de entrada de veiculo para o firestore - ", err)
-		client.Close()
-		return err
+// Posicao aponta um registro no histórico pelo tempo de referência e pelo ID
+type Posicao struct {
+	Tempo time.Time
+	ID    string
+}
+
+// posicao retorna a posição do registro no histórico
+func (r RegistroVeicular) posicao() Posicao {
+	return Posicao{Tempo: r.Referencia(), ID: r.ID}
+}
+
+// entradaNegada indica se o acesso do evento foi negado, caso em que o
+// veículo não entrou e nenhuma visita é aberta
+func entradaNegada(event defaults.EventoVeiculo) bool {
+	return event.Decisao != nil && event.Decisao.Resultado == defaults.Negado
+}
+
+// novaEntrada cria o registro de uma visita aberta, ou negada conforme a decisão de acesso
+func novaEntrada(event defaults.EventoVeiculo, duplicada bool) RegistroVeicular {
+	if entradaNegada(event) {
+		return RegistroVeicular{
+			Placa:          plate.Normalize(event.Placa),
+			Chave:          plate.Key(event.Placa),
+			Tempo:          event.Tempo,
+			Portaria:       event.Portaria,
+			Status:         StatusNegada,
+			DecisaoEntrada: event.Decisao,
+			EventoEntrada:  event.ID,
+			Revisar:        event.Revisar,
+		}
 	}
 
-	return nil
+	return RegistroVeicular{
+		Placa:            plate.Normalize(event.Placa),
+		Chave:            plate.Key(event.Placa),
+		Tempo:            event.Tempo,
+		Portaria:         event.Portaria,
+		Status:           StatusAberta,
+		EntradaDuplicada: duplicada,
+		DecisaoEntrada:   event.Decisao,
+		EventoEntrada:    event.ID,
+		Revisar:          event.Revisar,
+	}
 }
 
-//SendExitToDB função responsável por enviar os dados de evento de saída para o banco de dados
-func SendExitToDB(event defaults.EventoVeiculo) error {
-	log.Log(logService, "Enviando registro de saída de veiculo para base Firestore")
+// novaSaidaOrfa cria o registro de uma saída que não encontrou entrada aberta
+func novaSaidaOrfa(event defaults.EventoVeiculo) RegistroVeicular {
+	return RegistroVeicular{
+		Placa:         plate.Normalize(event.Placa),
+		Chave:         plate.Key(event.Placa),
+		TempoSaida:    event.Tempo,
+		PortariaSaida: event.Portaria,
+		Status:        StatusSaidaOrfa,
+		DecisaoSaida:  event.Decisao,
+		EventoSaida:   event.ID,
+		Revisar:       event.Revisar,
+	}
+}
 
-	// Retorna a data atual
-	tempo := time.Now().Format("-2006-01-02")
+// fecha registra a saída na visita. Saídas pareadas com uma leitura
+// diferente da entrada guardam a leitura e a confiança do pareamento
+func (r *RegistroVeicular) fecha(event defaults.EventoVeiculo, m plate.Match) {
+	r.TempoSaida = event.Tempo
+	r.PortariaSaida = event.Portaria
+	r.Status = StatusFechada
+	r.DecisaoSaida = event.Decisao
+	r.EventoSaida = event.ID
+	if m.Confianca < 1 {
+		r.PlacaSaida = plate.Normalize(event.Placa)
+		r.ConfiancaPareamento = m.Confianca
+		r.Revisar = r.Revisar || m.Revisar
+		log.Log(logService, "Saída da placa ", r.PlacaSaida, " pareada com a entrada da placa ", r.Placa,
+			fmt.Sprintf(" (confiança %.2f)", m.Confianca))
+	}
+	if event.Revisar {
+		r.Revisar = true
+	}
+}
 
-	// Inicializando o Firebase
-	opt := option.WithCredentialsFile(path.Join("util", "controle-acesso-port-firebase-adminsdk-ts97s-dec0edb44a.json"))
-	app, err := firebase.NewApp(context.Background(), nil, opt)
-	if err != nil {
-		log.Log(logService, "Erro inicializar app:", err)
-		return err
+// MatcherPareamento retorna o comparador de placas configurado em
+// config.Config.Plate, utilizado no pareamento das saídas com as entradas
+func MatcherPareamento() plate.Matcher {
+	cfg := config.Config.Plate
+	return plate.Matcher{
+		Minima:  cfg.ConfiancaMinima,
+		Revisao: cfg.ConfiancaRevisao,
+		Janela:  time.Duration(cfg.JanelaPareamento) * time.Minute,
 	}
+}
 
-	// Criando a conexão com o banco Firestore
-	client, err := app.Firestore(context.Background())
-	if err != nil {
-		log.Log(logService, "Erro ao inicializar o Firestore: ", err)
-		client.Close()
-		return err
+// pareiaSaida escolhe a visita aberta que a saída encerra: a mais recente da
+// mesma placa ou, sem ela, a visita de placa mais similar segundo o
+// MatcherPareamento. Retorna Indice -1 caso nenhuma visita corresponda
+func pareiaSaida(registros []RegistroVeicular, event defaults.EventoVeiculo) plate.Match {
+	if i := ultimaAberta(registros, event.Placa, event.Tempo); i >= 0 {
+		return plate.Match{Indice: i, Placa: registros[i].Placa, Confianca: 1}
+	}
+
+	indices := []int{}
+	candidatos := []plate.Candidate{}
+	for i, r := range registros {
+		if !r.Aberto() || r.Tempo.After(event.Tempo) {
+			continue
+		}
+		indices = append(indices, i)
+		candidatos = append(candidatos, plate.Candidate{Placa: r.Placa, Tempo: r.Tempo})
+	}
+
+	m := MatcherPareamento().Best(event.Placa, event.Tempo, candidatos)
+	if m.Indice >= 0 {
+		m.Indice = indices[m.Indice]
+	}
+	return m
+}
+
+// ultimaAberta retorna o índice da visita aberta mais recente da placa que
+// iniciou até o tempo t, ou -1 caso não exista
+func ultimaAberta(registros []RegistroVeicular, placa string, t time.Time) int {
+	idx := -1
+	chave := plate.Key(placa)
+	for i, r := range registros {
+		if r.chave() != chave || !r.Aberto() || r.Tempo.After(t) {
+			continue
+		}
+		if idx < 0 || r.Tempo.After(registros[idx].Tempo) {
+			idx = i
+		}
 	}
+	return idx
+}
 
-	Document, err := client.Collection("registro-veiculos"+tempo).Where("Placa", "==", "EXI7254").Documents(context.Background()).GetAll()
+// Store define as operações de persistência dos eventos de veículos,
+// independente do banco utilizado
+type Store interface {
+	// RecordEntry registra a entrada de um veículo. Visitas da mesma placa que
+	// ainda estejam abertas são encerradas com StatusSemSaida e a nova entrada
+	// é sinalizada como duplicada. Eventos com ID já gravado são ignorados
+	RecordEntry(event defaults.EventoVeiculo) error
+	// RecordExit fecha a visita aberta mais recente da placa, mesmo que tenha
+	// iniciado em outro dia. Sem visita aberta, grava uma saída órfã. Eventos
+	// com ID já gravado são ignorados
+	RecordExit(event defaults.EventoVeiculo) error
+	// FindRecords retorna os registros da placa entre inicio e fim. Placa vazia
+	// retorna os registros de todas as placas
+	FindRecords(placa string, inicio, fim time.Time) ([]RegistroVeicular, error)
+	// PageRecords retorna até limite registros da placa entre inicio e fim, do
+	// mais recente para o mais antigo pelo tempo de referência e pelo ID,
+	// começando após o registro apontado por apos (Posicao zero começa pelo
+	// fim do período). Limite não positivo retorna todos. Placa vazia retorna
+	// os registros de todas as placas
+	PageRecords(placa string, inicio, fim time.Time, apos Posicao, limite int) ([]RegistroVeicular, error)
+	// OpenVisits retorna os registros que ainda não possuem saída
+	OpenVisits() ([]RegistroVeicular, error)
+	// Close encerra a conexão com o banco
+	Close() error
+
+	Documents
+}
+
+// Documents define a persistência genérica de documentos, utilizada pelos
+// subsistemas que não tratam de eventos de veículos (cadastros, usuários...).
+// Os documentos são serializados em JSON, respeitando as tags json da estrutura
+type Documents interface {
+	// Put grava (ou substitui) o documento id da coleção
+	Put(colecao, id string, v interface{}) error
+	// Get lê o documento id da coleção em v. Retorna ErrNotFound caso não exista
+	Get(colecao, id string, v interface{}) error
+	// List lê todos os documentos da coleção, ordenados pelo id, em v, que
+	// deve ser um ponteiro para slice
+	List(colecao string, v interface{}) error
+	// Where lê em v os documentos da coleção cujo campo (tag json) é igual a
+	// valor, ordenados pelo id
+	Where(colecao, campo, valor string, v interface{}) error
+	// Delete remove o documento id da coleção. Retorna ErrNotFound caso não exista
+	Delete(colecao, id string) error
+}
+
+// NewID gera um identificador aleatório para novos documentos
+func NewID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
+
+// New cria o Store de acordo com a configuração informada
+func New(cfg config.StorageConfig) (Store, error) {
+	switch cfg.Driver {
+	case DriverFirestore, "":
+		return NewFirestore(cfg.CredentialsFile)
+	case DriverLocal:
+		return NewLocal(cfg.LocalPath)
+	}
+	return nil, errDriverInvalido
+}
+
+// Setup cria o Store configurado em config.Config e o define como padrão
+// para as funções SendEntryToDB e SendExitToDB. As gravações de eventos do
+// Store retornado são registradas na auditoria
+func Setup() (Store, error) {
+	s, err := New(config.Config.Storage)
 	if err != nil {
-		client.Close()
-		return err
+		return nil, err
 	}
+	log.Log(logService, "Banco de dados inicializado - driver: ", config.Config.Storage.Driver)
+
+	store := storeAuditado{s}
+	defaultStore = store
+	return store, nil
+}
 
-	_, err = Document[0].Ref.Set(context.Background(), map[string]interface{}{"TempoSaida": event.Tempo, "PortariaSaida": event.Portaria}, firestore.MergeAll)
+// SendEntryToDB envia os dados de evento de entrada para o banco padrão
+func SendEntryToDB(event defaults.EventoVeiculo) error {
+	if defaultStore == nil {
+		return errStoreNaoConfigurado
+	}
+	return defaultStore.RecordEntry(event)
+}
+
+// SendExitToDB envia os dados de evento de saída para o banco padrão
+func SendExitToDB(event defaults.EventoVeiculo) error {
+	if defaultStore == nil {
+		return errStoreNaoConfigurado
+	}
+	return defaultStore.RecordExit(event)
+}
+
+// listaJSON decodifica a lista de documentos JSON no slice apontado por v
+func listaJSON(docs []json.RawMessage, v interface{}) error {
+	data, err := json.Marshal(docs)
 	if err != nil {
-		client.Close()
 		return err
 	}
-
-	return nil
+	return json.Unmarshal(data, v)
 }
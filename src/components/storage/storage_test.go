@@ -0,0 +1,291 @@
+package storage
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+)
+
+// novoLocal cria um banco local temporário. A função retornada remove o banco
+func novoLocal(t *testing.T) (*LocalStore, func()) {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "storage")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewLocal(filepath.Join(dir, "registros.db"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() {
+		s.Close()
+		os.RemoveAll(dir)
+	}
+}
+
+// evento cria o evento da placa na portaria, minutos após base
+func evento(tipo defaults.TipoEvento, placa string, base time.Time, minutos int) defaults.EventoVeiculo {
+	return defaults.EventoVeiculo{Placa: placa, Tempo: base.Add(time.Duration(minutos) * time.Minute), Portaria: "P1", Tipo: tipo}
+}
+
+// registros retorna todos os registros do banco, em ordem de gravação
+func registros(t *testing.T, s *LocalStore) []RegistroVeicular {
+	t.Helper()
+	rs, err := s.filtra(func(RegistroVeicular) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rs
+}
+
+func TestUltimaAberta(t *testing.T) {
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+	rs := []RegistroVeicular{
+		{Placa: "ABC1234", Tempo: base, Status: StatusSemSaida},
+		{Placa: "ABC1234", Tempo: base.Add(time.Hour), Status: StatusAberta},
+		{Placa: "ABC1C34", Chave: "ABC1234", Tempo: base.Add(2 * time.Hour), Status: StatusAberta},
+		{Placa: "ABC1234", Tempo: base.Add(3 * time.Hour), Status: StatusFechada},
+		{Placa: "XYZ9876", Tempo: base.Add(-24 * time.Hour)},                                        // sem Status, sem saída
+		{Placa: "XYZ9877", Tempo: base.Add(-24 * time.Hour), TempoSaida: base.Add(-23 * time.Hour)}, // sem Status, com saída
+	}
+
+	casos := []struct {
+		nome  string
+		placa string
+		t     time.Time
+		quer  int
+	}{
+		{"aberta mais recente pela chave", "ABC1234", base.Add(4 * time.Hour), 2},
+		{"forma Mercosul da placa", "ABC1C34", base.Add(4 * time.Hour), 2},
+		{"ignora as visitas após o tempo", "ABC1234", base.Add(90 * time.Minute), 1},
+		{"antes de todas as visitas", "ABC1234", base.Add(-time.Minute), -1},
+		{"registro sem Status e sem saída", "XYZ9876", base, 4},
+		{"registro sem Status com saída", "XYZ9877", base, -1},
+		{"placa sem visitas", "QWE1234", base, -1},
+	}
+	for _, c := range casos {
+		if got := ultimaAberta(rs, c.placa, c.t); got != c.quer {
+			t.Errorf("%s: ultimaAberta = %d, esperado %d", c.nome, got, c.quer)
+		}
+	}
+}
+
+func TestPareiaSaida(t *testing.T) {
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+	rs := []RegistroVeicular{
+		{Placa: "EXI7254", Tempo: base, Status: StatusAberta},
+		{Placa: "ABC1234", Tempo: base.Add(time.Hour), Status: StatusAberta},
+		{Placa: "QWE5678", Tempo: base.Add(time.Hour), Status: StatusFechada},
+	}
+
+	casos := []struct {
+		nome    string
+		placa   string
+		minutos int
+		indice  int
+		exata   bool
+	}{
+		{"mesma placa", "ABC1234", 120, 1, true},
+		{"leitura com erro pareada por similaridade", "EX17254", 120, 0, false},
+		{"visita fechada não é pareada", "QWE5678", 120, -1, false},
+		{"placa sem visita similar", "XYZ9876", 120, -1, false},
+		{"último dígito diferente é outro veículo", "ABC1235", 120, -1, false},
+		{"visita iniciada após a saída", "ABC1234", 30, -1, false},
+	}
+	for _, c := range casos {
+		m := pareiaSaida(rs, evento(defaults.Saida, c.placa, base, c.minutos))
+		if m.Indice != c.indice {
+			t.Errorf("%s: pareiaSaida = %+v, esperado índice %d", c.nome, m, c.indice)
+			continue
+		}
+		if m.Indice >= 0 && (m.Confianca == 1) != c.exata {
+			t.Errorf("%s: confiança %.3f, pareamento exato esperado: %v", c.nome, m.Confianca, c.exata)
+		}
+	}
+}
+
+func TestLocalStoreVisitas(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+
+	passos := []struct {
+		ev  defaults.EventoVeiculo
+		err error
+	}{
+		{evento(defaults.Entrada, "ABC1234", base, 0), nil},
+		{evento(defaults.Entrada, "ABC1C34", base, 10), nil}, // mesma placa no formato Mercosul, sem saída da anterior
+		{evento(defaults.Entrada, "EXI7254", base, 20), nil},
+		{evento(defaults.Saida, "ABC1234", base, 60), nil},
+		{evento(defaults.Saida, "EX17254", base, 70), nil}, // leitura com erro da placa
+		{evento(defaults.Saida, "XYZ9876", base, 80), nil}, // sem entrada
+	}
+	for _, p := range passos {
+		var err error
+		if p.ev.Tipo == defaults.Saida {
+			err = s.RecordExit(p.ev)
+		} else {
+			err = s.RecordEntry(p.ev)
+		}
+		if err != p.err {
+			t.Fatalf("%s %s: %v", p.ev.Tipo, p.ev.Placa, err)
+		}
+	}
+
+	quer := []struct {
+		placa      string
+		status     string
+		duplicada  bool
+		placaSaida string
+	}{
+		{"ABC1234", StatusSemSaida, false, ""},
+		{"ABC1C34", StatusFechada, true, ""},
+		{"EXI7254", StatusFechada, false, "EX17254"},
+		{"XYZ9876", StatusSaidaOrfa, false, ""},
+	}
+	rs := registros(t, s)
+	if len(rs) != len(quer) {
+		t.Fatalf("%d registros gravados, esperado %d: %+v", len(rs), len(quer), rs)
+	}
+	for i, q := range quer {
+		r := rs[i]
+		if r.Placa != q.placa || r.Status != q.status || r.EntradaDuplicada != q.duplicada || r.PlacaSaida != q.placaSaida {
+			t.Errorf("registro %d = %+v, esperado %+v", i, r, q)
+		}
+	}
+	if r := rs[2]; r.ConfiancaPareamento <= 0 || r.ConfiancaPareamento >= 1 {
+		t.Errorf("pareamento por similaridade sem confiança: %+v", r)
+	}
+
+	abertas, err := s.OpenVisits()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(abertas) != 0 {
+		t.Errorf("visitas abertas após todas as saídas: %+v", abertas)
+	}
+}
+
+func TestLocalStoreSaidaOutraPlaca(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+
+	if err := s.RecordEntry(evento(defaults.Entrada, "ABC1234", base, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordExit(evento(defaults.Saida, "ABC1235", base, 30)); err != nil {
+		t.Fatal(err)
+	}
+
+	// A saída de outro veículo não encerra a visita aberta
+	rs := registros(t, s)
+	if len(rs) != 2 || rs[0].Status != StatusAberta || rs[1].Status != StatusSaidaOrfa {
+		t.Fatalf("saída de ABC1235 pareada com a entrada de ABC1234: %+v", rs)
+	}
+}
+
+func TestLocalStoreEntradaNegada(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+
+	if err := s.RecordEntry(evento(defaults.Entrada, "ABC1234", base, 0)); err != nil {
+		t.Fatal(err)
+	}
+	negada := evento(defaults.Entrada, "ABC1234", base, 10)
+	negada.Decisao = &defaults.DecisaoAcesso{Resultado: defaults.Negado}
+	if err := s.RecordEntry(negada); err != nil {
+		t.Fatal(err)
+	}
+
+	// A entrada negada não encerra a visita aberta
+	rs := registros(t, s)
+	if len(rs) != 2 || rs[0].Status != StatusAberta || rs[1].Status != StatusNegada {
+		t.Fatalf("registros após entrada negada: %+v", rs)
+	}
+	if err := s.RecordExit(evento(defaults.Saida, "ABC1234", base, 20)); err != nil {
+		t.Fatal(err)
+	}
+	if rs := registros(t, s); rs[0].Status != StatusFechada || rs[1].Status != StatusNegada {
+		t.Errorf("saída pareada com a entrada negada: %+v", rs)
+	}
+}
+
+func TestLocalStoreRegistroSemStatus(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+
+	// Registros gravados antes do pareamento de visitas, sem Status e sem chave
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucketRegistros)
+		if err := adicionaRegistro(b, RegistroVeicular{Placa: "EXI7254", Tempo: base.AddDate(0, 0, -2)}); err != nil {
+			return err
+		}
+		return adicionaRegistro(b, RegistroVeicular{Placa: "ABC1234", Tempo: base.AddDate(0, 0, -1)})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RecordExit(evento(defaults.Saida, "ABC1234", base, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RecordExit(evento(defaults.Saida, "EX17254", base, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	rs := registros(t, s)
+	if len(rs) != 2 {
+		t.Fatalf("saídas de registros antigos gravadas como órfãs: %+v", rs)
+	}
+	for _, r := range rs {
+		if r.Status != StatusFechada || r.TempoSaida.IsZero() {
+			t.Errorf("registro antigo não fechado: %+v", r)
+		}
+	}
+}
+
+func TestLocalStoreReenvio(t *testing.T) {
+	s, remove := novoLocal(t)
+	defer remove()
+	base := time.Date(2020, 3, 10, 8, 0, 0, 0, time.Local)
+
+	entrada := evento(defaults.Entrada, "ABC1234", base, 0)
+	entrada.ID = "entrada-1"
+	saida := evento(defaults.Saida, "ABC1234", base, 30)
+	saida.ID = "saida-1"
+	orfa := evento(defaults.Saida, "XYZ9876", base, 40)
+	orfa.ID = "saida-2"
+
+	// Cada evento é enviado duas vezes, como após uma falha na confirmação do envio
+	for _, ev := range []defaults.EventoVeiculo{entrada, entrada, saida, saida, orfa, orfa} {
+		var err error
+		if ev.Tipo == defaults.Saida {
+			err = s.RecordExit(ev)
+		} else {
+			err = s.RecordEntry(ev)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rs := registros(t, s)
+	if len(rs) != 2 {
+		t.Fatalf("reenvios gravaram registros duplicados: %+v", rs)
+	}
+	if r := rs[0]; r.Status != StatusFechada || r.EntradaDuplicada || r.EventoEntrada != entrada.ID || r.EventoSaida != saida.ID {
+		t.Errorf("visita alterada pelo reenvio: %+v", r)
+	}
+	if r := rs[1]; r.Status != StatusSaidaOrfa || r.EventoSaida != orfa.ID {
+		t.Errorf("saída órfã alterada pelo reenvio: %+v", r)
+	}
+}
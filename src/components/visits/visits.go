@@ -0,0 +1,220 @@
+// O pacote visits transforma os eventos de entrada e saída em visitas e mantém
+// em memória a ocupação atual do campus, por portaria e no total
+package visits
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "VISITS"
+)
+
+// Status representa a situação de uma visita
+type Status string
+
+// Situações possíveis de uma visita
+const (
+	Aberta   Status = "aberta"    // veículo dentro do campus
+	Fechada  Status = "fechada"   // entrada e saída registradas
+	Orfa     Status = "orfa"      // saída sem entrada correspondente
+	Excedida Status = "excedida"  // veículo dentro do campus além do tempo permitido
+	SemSaida Status = "sem-saida" // entrada encerrada por uma nova entrada da mesma placa
+	Negada   Status = "negada"    // entrada com acesso negado
+)
+
+// Visit representa a passagem de um veículo pelo campus
+type Visit struct {
+	ID              string        `json:"id,omitempty"`
+	Placa           string        `json:"placa"`
+	Entrada         time.Time     `json:"entrada,omitempty"`
+	PortariaEntrada string        `json:"portariaEntrada,omitempty"`
+	Saida           time.Time     `json:"saida,omitempty"`
+	PortariaSaida   string        `json:"portariaSaida,omitempty"`
+	Permanencia     time.Duration `json:"permanencia"`
+	Status          Status        `json:"status"`
+	Duplicada       bool          `json:"duplicada,omitempty"`
+}
+
+// Occupancy representa a quantidade de veículos dentro do campus
+type Occupancy struct {
+	Total     int            `json:"total"`
+	Portarias map[string]int `json:"portarias"` // veículos dentro do campus por portaria de entrada
+}
+
+// FromRecord converte um registro do banco em visita. Visitas abertas têm a
+// permanência calculada até agora e são marcadas como excedidas quando
+// ultrapassam maxPermanencia (zero desabilita a verificação)
+func FromRecord(r storage.RegistroVeicular, agora time.Time, maxPermanencia time.Duration) Visit {
+	v := Visit{
+		ID:              r.ID,
+		Placa:           r.Placa,
+		Entrada:         r.Tempo,
+		PortariaEntrada: r.Portaria,
+		Saida:           r.TempoSaida,
+		PortariaSaida:   r.PortariaSaida,
+		Duplicada:       r.EntradaDuplicada,
+	}
+
+	switch {
+	case r.Status == storage.StatusSaidaOrfa:
+		v.Status = Orfa
+	case r.Status == storage.StatusSemSaida:
+		v.Status = SemSaida
+	case r.Status == storage.StatusNegada:
+		v.Status = Negada
+	case r.Aberto():
+		v.Status = Aberta
+		v.Permanencia = agora.Sub(r.Tempo)
+	default:
+		v.Status = Fechada
+		v.Permanencia = r.TempoSaida.Sub(r.Tempo)
+	}
+
+	if v.Status == Aberta && maxPermanencia > 0 && v.Permanencia > maxPermanencia {
+		v.Status = Excedida
+	}
+	return v
+}
+
+// Tracker mantém as visitas abertas e a ocupação do campus em memória
+type Tracker struct {
+	mutex          sync.Mutex
+	abertas        map[string]Visit // visitas abertas indexadas pela chave da placa (plate.Key)
+	maxPermanencia time.Duration
+}
+
+// NewTracker cria o Tracker a partir das visitas abertas persistidas no
+// store, de forma que a ocupação sobrevive a reinícios do processo
+func NewTracker(store storage.Store, maxPermanencia time.Duration) (*Tracker, error) {
+	t := &Tracker{abertas: map[string]Visit{}, maxPermanencia: maxPermanencia}
+
+	registros, err := store.OpenVisits()
+	if err != nil {
+		return nil, err
+	}
+
+	agora := time.Now()
+	for _, r := range registros {
+		v := FromRecord(r, agora, maxPermanencia)
+		chave := plate.Key(v.Placa)
+		if atual, ok := t.abertas[chave]; !ok || v.Entrada.After(atual.Entrada) {
+			t.abertas[chave] = v
+		}
+	}
+	log.Log(logService, "Ocupação inicial carregada: ", len(t.abertas), " veículos")
+
+	return t, nil
+}
+
+// Registra atualiza as visitas abertas com o evento de entrada ou saída
+func (t *Tracker) Registra(ev defaults.EventoVeiculo) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	chave := plate.Key(ev.Placa)
+	switch ev.Tipo {
+	case defaults.Saida:
+		if _, ok := t.abertas[chave]; !ok {
+			// A entrada pode ter sido lida com erro, pareia como o storage
+			if chave = t.pareiaSaida(ev); chave == "" {
+				log.Log(logService, "Saída sem visita aberta - placa: ", ev.Placa)
+			}
+		}
+		delete(t.abertas, chave)
+	default:
+		if ev.Decisao != nil && ev.Decisao.Resultado == defaults.Negado {
+			// Acesso negado, o veículo não entrou no campus
+			return
+		}
+		_, duplicada := t.abertas[chave]
+		t.abertas[chave] = Visit{
+			Placa:           plate.Normalize(ev.Placa),
+			Entrada:         ev.Tempo,
+			PortariaEntrada: ev.Portaria,
+			Status:          Aberta,
+			Duplicada:       duplicada,
+		}
+	}
+}
+
+// Inside verifica se a placa possui visita aberta no tempo t. A placa deve
+// corresponder exatamente (plate.Key): com a tolerância aos erros de
+// reconhecimento da saída, a entrada de um veículo de placa parecida com a de
+// outro no campus seria tratada como saída
+func (t *Tracker) Inside(placa string, tempo time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	v, ok := t.abertas[plate.Key(placa)]
+	return ok && !v.Entrada.After(tempo)
+}
+
+// pareiaSaida retorna a chave da visita aberta de placa mais similar à saída,
+// segundo storage.MatcherPareamento, ou vazio caso nenhuma corresponda
+func (t *Tracker) pareiaSaida(ev defaults.EventoVeiculo) string {
+	chaves := []string{}
+	candidatos := []plate.Candidate{}
+	for k, v := range t.abertas {
+		if v.Entrada.After(ev.Tempo) {
+			continue
+		}
+		chaves = append(chaves, k)
+		candidatos = append(candidatos, plate.Candidate{Placa: v.Placa, Tempo: v.Entrada})
+	}
+
+	m := storage.MatcherPareamento().Best(ev.Placa, ev.Tempo, candidatos)
+	if m.Indice < 0 {
+		return ""
+	}
+	return chaves[m.Indice]
+}
+
+// Occupancy retorna a quantidade de veículos dentro do campus
+func (t *Tracker) Occupancy() Occupancy {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	o := Occupancy{Total: len(t.abertas), Portarias: map[string]int{}}
+	for _, v := range t.abertas {
+		o.Portarias[v.PortariaEntrada]++
+	}
+	return o
+}
+
+// Open retorna as visitas abertas ordenadas pela entrada, com a permanência atualizada
+func (t *Tracker) Open() []Visit {
+	t.mutex.Lock()
+	agora := time.Now()
+	visitas := make([]Visit, 0, len(t.abertas))
+	for _, v := range t.abertas {
+		v.Permanencia = agora.Sub(v.Entrada)
+		v.Status = Aberta
+		if t.maxPermanencia > 0 && v.Permanencia > t.maxPermanencia {
+			v.Status = Excedida
+		}
+		visitas = append(visitas, v)
+	}
+	t.mutex.Unlock()
+
+	sort.Slice(visitas, func(i, j int) bool { return visitas[i].Entrada.Before(visitas[j].Entrada) })
+	return visitas
+}
+
+// Overstayed retorna as visitas abertas que excederam o tempo de permanência
+func (t *Tracker) Overstayed() []Visit {
+	excedidas := []Visit{}
+	for _, v := range t.Open() {
+		if v.Status == Excedida {
+			excedidas = append(excedidas, v)
+		}
+	}
+	return excedidas
+}
@@ -0,0 +1,169 @@
+package watchlist
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	timeoutEncaminhamento = 10 * time.Second
+)
+
+// Alert representa a passagem de uma placa observada por uma portaria
+type Alert struct {
+	ID             string              `json:"id"`
+	Placa          string              `json:"placa"`     // placa observada
+	PlacaLida      string              `json:"placaLida"` // placa reconhecida na portaria
+	Similaridade   float64             `json:"similaridade"`
+	Categoria      Categoria           `json:"categoria"`
+	Severidade     Severidade          `json:"severidade"`
+	Motivo         string              `json:"motivo"`
+	Portaria       string              `json:"portaria"`
+	Tipo           defaults.TipoEvento `json:"tipo"`
+	Tempo          time.Time           `json:"tempo"`
+	Reconhecido    bool                `json:"reconhecido"` // operador tomou ciência do alerta
+	ReconhecidoPor string              `json:"reconhecidoPor,omitempty"`
+	ReconhecidoEm  time.Time           `json:"reconhecidoEm"`
+}
+
+// Check compara a placa do evento com a lista de observação e gera um
+// alerta para cada placa encontrada. Os alertas são gravados, enviados em
+// Alertas e encaminhados por webhook e e-mail conforme a severidade
+func (w *Watchlist) Check(evento defaults.EventoVeiculo) []Alert {
+	alertas := []Alert{}
+	for _, m := range w.Match(evento.Placa) {
+		a := Alert{
+			ID:           storage.NewID(),
+			Placa:        m.Placa,
+			PlacaLida:    evento.Placa,
+			Similaridade: m.Similaridade,
+			Categoria:    m.Categoria,
+			Severidade:   m.Severidade,
+			Motivo:       m.Motivo,
+			Portaria:     evento.Portaria,
+			Tipo:         evento.Tipo,
+			Tempo:        evento.Tempo,
+		}
+		log.Log(logService, "ALERTA ", a.Severidade, ": placa ", a.Placa, " (", a.Categoria, ") lida como ", a.PlacaLida,
+			fmt.Sprintf(" (%.2f)", a.Similaridade), " em ", a.Tipo, " ", a.Portaria)
+
+		if err := w.docs.Put(colecaoAlertas, a.ID, a); err != nil {
+			log.Log(logService, "Erro ao gravar alerta ", a.ID, ": ", err)
+		}
+
+		select {
+		case w.Alertas <- a:
+		default:
+			log.Log(logService, "Canal de alertas cheio, alerta ", a.ID, " não enviado aos operadores")
+		}
+
+		if a.Severidade.nivel() >= Severidade(w.cfg.SeveridadeMinima).nivel() {
+			go w.encaminha(a)
+		}
+		alertas = append(alertas, a)
+	}
+	return alertas
+}
+
+// Alerts retorna os alertas gravados, do mais recente para o mais antigo.
+// pendentes restringe aos alertas não reconhecidos e limite à quantidade
+// retornada (0 retorna todos)
+func (w *Watchlist) Alerts(pendentes bool, limite int) ([]Alert, error) {
+	todos := []Alert{}
+	if err := w.docs.List(colecaoAlertas, &todos); err != nil {
+		return nil, err
+	}
+	sort.Slice(todos, func(i, j int) bool {
+		return todos[i].Tempo.After(todos[j].Tempo)
+	})
+
+	alertas := []Alert{}
+	for _, a := range todos {
+		if pendentes && a.Reconhecido {
+			continue
+		}
+		if limite > 0 && len(alertas) >= limite {
+			break
+		}
+		alertas = append(alertas, a)
+	}
+	return alertas, nil
+}
+
+// Acknowledge registra que o operador tomou ciência do alerta
+func (w *Watchlist) Acknowledge(id, operador string) (Alert, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	var a Alert
+	if err := w.docs.Get(colecaoAlertas, id, &a); err != nil {
+		return a, err
+	}
+	if a.Reconhecido {
+		return a, nil
+	}
+	a.Reconhecido = true
+	a.ReconhecidoPor = operador
+	a.ReconhecidoEm = time.Now()
+	if err := w.docs.Put(colecaoAlertas, a.ID, a); err != nil {
+		return a, err
+	}
+	log.Log(logService, "Alerta ", a.ID, " da placa ", a.Placa, " reconhecido por ", operador)
+	return a, nil
+}
+
+// encaminha envia o alerta ao webhook e aos e-mails configurados
+func (w *Watchlist) encaminha(a Alert) {
+	if w.cfg.Webhook != "" {
+		if err := enviaWebhook(w.cfg.Webhook, a); err != nil {
+			log.Log(logService, "Erro ao enviar alerta ", a.ID, " ao webhook: ", err)
+		}
+	}
+	if w.cfg.SMTP != "" && len(w.cfg.Destinatarios) > 0 {
+		if err := enviaEmail(w.cfg.SMTP, w.cfg.Remetente, w.cfg.Destinatarios, a); err != nil {
+			log.Log(logService, "Erro ao enviar alerta ", a.ID, " por e-mail: ", err)
+		}
+	}
+}
+
+// enviaWebhook envia o alerta em JSON por POST
+func enviaWebhook(url string, a Alert) error {
+	data, err := json.Marshal(a)
+	if err != nil {
+		return err
+	}
+	client := http.Client{Timeout: timeoutEncaminhamento}
+	resp, err := client.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("resposta %s", resp.Status)
+	}
+	return nil
+}
+
+// enviaEmail envia o alerta pelo relay SMTP local, sem autenticação
+func enviaEmail(relay, remetente string, destinatarios []string, a Alert) error {
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", remetente)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(destinatarios, ", "))
+	fmt.Fprintf(&msg, "Subject: Alerta %s: placa %s (%s) na portaria %s\r\n", a.Severidade, a.Placa, a.Categoria, a.Portaria)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Placa observada: %s\r\n", a.Placa)
+	fmt.Fprintf(&msg, "Placa lida: %s (similaridade %.2f)\r\n", a.PlacaLida, a.Similaridade)
+	fmt.Fprintf(&msg, "Categoria: %s\r\nSeveridade: %s\r\nMotivo: %s\r\n", a.Categoria, a.Severidade, a.Motivo)
+	fmt.Fprintf(&msg, "Evento: %s na portaria %s em %s\r\n", a.Tipo, a.Portaria, a.Tempo.Format("02/01/2006 15:04:05"))
+	return smtp.SendMail(relay, nil, remetente, destinatarios, msg.Bytes())
+}
@@ -0,0 +1,235 @@
+// O pacote watchlist implementa a lista de observação de placas (veículos
+// roubados, pessoas banidas, VIPs): cada placa reconhecida é comparada com a
+// lista, tolerando as confusões comuns do reconhecimento, e as placas
+// encontradas geram alertas enviados aos operadores, gravados no banco e
+// encaminhados por webhook ou e-mail
+package watchlist
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/plate"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	logService log.Service = "WATCHLIST"
+
+	colecaoObservadas = "observadas"
+	colecaoAlertas    = "alertas"
+
+	similaridadePadrao = 0.9
+	bufferAlertas      = 100
+)
+
+// Categoria representa o motivo da observação da placa
+type Categoria string
+
+// Categorias de placas observadas
+const (
+	Roubado  Categoria = "roubado"  // veículo com queixa de roubo ou furto
+	Banido   Categoria = "banido"   // pessoa proibida de entrar no campus
+	VIP      Categoria = "vip"      // visitante que deve ser recebido
+	Suspeito Categoria = "suspeito" // veículo sob observação da segurança
+)
+
+// Severidade representa a urgência do alerta
+type Severidade string
+
+// Severidades dos alertas, da menor para a maior
+const (
+	Baixa   Severidade = "baixa"
+	Media   Severidade = "media"
+	Alta    Severidade = "alta"
+	Critica Severidade = "critica"
+)
+
+// nivel retorna a ordem da severidade (0 para severidades inválidas)
+func (s Severidade) nivel() int {
+	switch s {
+	case Baixa:
+		return 1
+	case Media:
+		return 2
+	case Alta:
+		return 3
+	case Critica:
+		return 4
+	}
+	return 0
+}
+
+var (
+	errPlacaVazia           = errors.New("A placa observada é obrigatória")
+	errCategoriaInvalida    = errors.New("Categoria inválida, utilize roubado, banido, vip ou suspeito")
+	errSeveridadeInvalida   = errors.New("Severidade inválida, utilize baixa, media, alta ou critica")
+	errSimilaridadeInvalida = errors.New("Similaridade inválida, utilize um valor entre 0 e 1")
+)
+
+// Entry representa uma placa da lista de observação
+type Entry struct {
+	Placa      string     `json:"placa"`
+	Categoria  Categoria  `json:"categoria"`
+	Severidade Severidade `json:"severidade"`
+	Motivo     string     `json:"motivo"`
+	CriadoPor  string     `json:"criadoPor"`
+	Criado     time.Time  `json:"criado"`
+}
+
+// Validate verifica se os dados obrigatórios da placa observada são válidos
+func (e *Entry) Validate() error {
+	e.Placa = plate.Normalize(e.Placa)
+	if e.Placa == "" {
+		return errPlacaVazia
+	}
+	switch e.Categoria {
+	case Roubado, Banido, VIP, Suspeito:
+	default:
+		return errCategoriaInvalida
+	}
+	if e.Severidade.nivel() == 0 {
+		return errSeveridadeInvalida
+	}
+	return nil
+}
+
+// Match representa a correspondência entre uma leitura e a placa observada
+type Match struct {
+	Entry
+	Similaridade float64 `json:"similaridade"` // 1 para leituras idênticas
+}
+
+// Watchlist mantém a lista de observação em memória e persistida no banco
+type Watchlist struct {
+	Alertas chan Alert
+
+	mutex     sync.RWMutex
+	docs      storage.Documents
+	cfg       config.AlertasConfig
+	observada map[string]Entry
+}
+
+// New cria a lista de observação, carregando as placas persistidas em docs
+func New(docs storage.Documents, cfg config.AlertasConfig) (*Watchlist, error) {
+	if cfg.Similaridade == 0 {
+		cfg.Similaridade = similaridadePadrao
+	}
+	if cfg.Similaridade < 0 || cfg.Similaridade > 1 {
+		return nil, errSimilaridadeInvalida
+	}
+	if cfg.SeveridadeMinima == "" {
+		cfg.SeveridadeMinima = string(Alta)
+	}
+	if Severidade(cfg.SeveridadeMinima).nivel() == 0 {
+		return nil, errSeveridadeInvalida
+	}
+
+	w := &Watchlist{
+		Alertas:   make(chan Alert, bufferAlertas),
+		docs:      docs,
+		cfg:       cfg,
+		observada: map[string]Entry{},
+	}
+
+	entradas := []Entry{}
+	if err := docs.List(colecaoObservadas, &entradas); err != nil {
+		return nil, err
+	}
+	for _, e := range entradas {
+		w.observada[plate.Key(e.Placa)] = e
+	}
+	log.Log(logService, "Lista de observação carregada: ", len(entradas), " placas")
+	return w, nil
+}
+
+// Entries retorna as placas observadas, ordenadas pela placa
+func (w *Watchlist) Entries() []Entry {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	entradas := []Entry{}
+	for _, e := range w.observada {
+		entradas = append(entradas, e)
+	}
+	sort.Slice(entradas, func(i, j int) bool {
+		return entradas[i].Placa < entradas[j].Placa
+	})
+	return entradas
+}
+
+// Entry retorna a placa observada. Retorna storage.ErrNotFound caso não exista
+func (w *Watchlist) Entry(placa string) (Entry, error) {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	e, ok := w.observada[plate.Key(placa)]
+	if !ok {
+		return e, storage.ErrNotFound
+	}
+	return e, nil
+}
+
+// Save cria ou atualiza a placa observada
+func (w *Watchlist) Save(e *Entry) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if antes, ok := w.observada[plate.Key(e.Placa)]; ok {
+		e.CriadoPor, e.Criado = antes.CriadoPor, antes.Criado
+	} else {
+		e.Criado = time.Now()
+	}
+	if err := w.docs.Put(colecaoObservadas, plate.Key(e.Placa), e); err != nil {
+		return err
+	}
+	w.observada[plate.Key(e.Placa)] = *e
+
+	log.Log(logService, "Placa ", e.Placa, " observada: ", e.Categoria, " (", e.Severidade, ") - ", e.Motivo)
+	return nil
+}
+
+// Delete remove a placa da lista de observação
+func (w *Watchlist) Delete(placa string) error {
+	placa = plate.Key(placa)
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if _, ok := w.observada[placa]; !ok {
+		return storage.ErrNotFound
+	}
+	if err := w.docs.Delete(colecaoObservadas, placa); err != nil && err != storage.ErrNotFound {
+		return err
+	}
+	delete(w.observada, placa)
+
+	log.Log(logService, "Placa ", placa, " removida da lista de observação")
+	return nil
+}
+
+// Match retorna as placas observadas com similaridade à leitura igual ou
+// superior à configurada, da mais para a menos similar
+func (w *Watchlist) Match(placa string) []Match {
+	w.mutex.RLock()
+	defer w.mutex.RUnlock()
+
+	encontradas := []Match{}
+	for _, e := range w.observada {
+		if s := plate.Similarity(placa, e.Placa); s >= w.cfg.Similaridade {
+			encontradas = append(encontradas, Match{Entry: e, Similaridade: s})
+		}
+	}
+	sort.Slice(encontradas, func(i, j int) bool {
+		return encontradas[i].Similaridade > encontradas[j].Similaridade
+	})
+	return encontradas
+}
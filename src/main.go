@@ -2,11 +2,25 @@ package main
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/gustavolimam/control-access/src/components/access"
+	"github.com/gustavolimam/control-access/src/components/audit"
+	"github.com/gustavolimam/control-access/src/components/auth"
 	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/gate"
 	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/override"
+	"github.com/gustavolimam/control-access/src/components/passes"
+	"github.com/gustavolimam/control-access/src/components/recognition"
+	"github.com/gustavolimam/control-access/src/components/registry"
 	"github.com/gustavolimam/control-access/src/components/report"
+	"github.com/gustavolimam/control-access/src/components/storage"
+	"github.com/gustavolimam/control-access/src/components/visits"
+	"github.com/gustavolimam/control-access/src/components/watchlist"
 	"github.com/gustavolimam/control-access/src/services/events"
+	"github.com/gustavolimam/control-access/src/services/ingest"
+	"github.com/gustavolimam/control-access/src/services/slp"
 	"github.com/gustavolimam/control-access/src/services/web"
 )
 
@@ -28,15 +42,92 @@ func main() {
 		log.Fatal(logService, "Erro ao criar arquivo de log: ", err)
 	}
 
+	// Registro de auditoria, iniciado antes do banco para registrar as gravações de eventos
+	auditLog, err := audit.Setup()
+	if err != nil {
+		log.Fatal(logService, "Erro ao abrir registro de auditoria: ", err)
+	}
+	defer auditLog.Close()
+
+	// Conecta ao banco de dados configurado
+	store, err := storage.Setup()
+	if err != nil {
+		log.Fatal(logService, "Erro ao conectar ao banco de dados: ", err)
+	}
+	defer store.Close()
+
+	// Checkpoint da auditoria no banco, para detectar a remoção das últimas entradas
+	if err := auditLog.Checkpoints(store); err != nil {
+		log.Fatal(logService, "Erro ao verificar registro de auditoria: ", err)
+	}
+
+	// Carrega as visitas abertas para o controle de ocupação
+	maxPermanencia := time.Duration(config.Config.Visitas.PermanenciaMaxima) * time.Minute
+	tracker, err := visits.NewTracker(store, maxPermanencia)
+	if err != nil {
+		log.Fatal(logService, "Erro ao carregar visitas abertas: ", err)
+	}
+
+	// Cadastro de pessoas e veículos
+	reg := registry.New(store)
+
+	// Passes de visitantes e fornecedores
+	pas := passes.New(store)
+
+	// Lista de observação de placas
+	wl, err := watchlist.New(store, config.Config.Alertas)
+	if err != nil {
+		log.Fatal(logService, "Erro ao carregar lista de observação: ", err)
+	}
+
+	// Motor de decisão de acesso
+	engine, err := access.New(reg, pas, store)
+	if err != nil {
+		log.Fatal(logService, "Erro ao carregar regras de acesso: ", err)
+	}
+
+	// Acionadores das cancelas das portarias
+	gates, err := gate.NewManager(config.Config.Cancelas)
+	if err != nil {
+		log.Fatal(logService, "Erro ao configurar cancelas: ", err)
+	}
+
+	// Usuários e sessões do serviço web
+	authService, err := auth.New(store, config.Config.Web)
+	if err != nil {
+		log.Fatal(logService, "Erro ao configurar autenticação: ", err)
+	}
+
+	// Eventos que aguardam a decisão do operador
+	pending := override.New(time.Duration(config.Config.Eventos.TimeoutOperador) * time.Second)
+
+	// Reconhecimento das placas dos frames das câmeras
+	rec, err := recognition.New(config.Config.Jidosha)
+	if err != nil {
+		log.Fatal(logService, "Erro ao configurar reconhecimento de placas: ", err)
+	}
+	lp, err := slp.New(rec, config.Config.Jidosha)
+	if err != nil {
+		log.Fatal(logService, "Erro ao criar Serviço de Leitura de Placas: ", err)
+	}
+	go lp.Run()
+
+	// Câmeras das portarias
+	in, err := ingest.New(config.Config.Portarias)
+	if err != nil {
+		log.Fatal(logService, "Erro ao configurar câmeras das portarias: ", err)
+	}
+	go in.Run()
+
 	// Start events service
-	if ev := events.New(); ev == nil {
+	ev := events.New(store, tracker, engine, gates, pending, pas, wl)
+	if ev == nil {
 		log.Fatal(logService, "Erro ao criar Serviço de Eventos")
-	} else {
-		go ev.Run()
 	}
+	go ev.Run()
 
 	// Start web service
-	if ws := web.New(); ws == nil {
+	if ws := web.New(authService, store, tracker, reg, pas, wl, engine, gates, pending, in, ev.WebCh); ws == nil {
 		log.Fatal(logService, "Erro ao criar Sistema Web")
 	} else {
 		go ws.Run()
@@ -2,13 +2,14 @@ package scipan
 
 import (
 	"errors"
-	"fmt"
+	"time"
 
 	"github.com/gustavolimam/control-access/src/components/buffer"
 	"github.com/gustavolimam/control-access/src/components/camera"
 	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/image"
 	"github.com/gustavolimam/control-access/src/components/log"
-	"github.com/gustavolimam/control-access/src/components/messages"
 )
 
 const (
@@ -18,87 +19,75 @@ const (
 var (
 	errLateImg  = errors.New("Imagem do buffer fora do intervalo de tempo permitido (Atrasada)")
 	errEarlyImg = errors.New("Imagem do buffer fora do intervalo de tempo permitido (Adiantada)")
-	erroImgNull = errors.New("Imagem não encontrada no buffer")
 )
 
-// SciPan representa a estrutura do canal sci-pan
+// SciPan representa a estrutura do serviço sci-pan de uma câmera panorâmica
 type SciPan struct {
-	sdpCh  chan messages.PanReceive
-	cam    *camera.Camera
-	buffer *buffer.FrameBuffer
+	portaria string
+	id       string
+	cam      camera.FrameSource
+	buffer   *buffer.FrameBuffer
+	relay    *camera.Relay
 }
 
-// New inicia um novo serviço do SCI-PAN
-func New() *SciPan {
-	log.Log(logService, "Serviço criado")
+// New inicia um novo serviço do SCI-PAN para a câmera panorâmica da portaria
+func New(portaria string, cfg config.CamCfg) (*SciPan, error) {
+	cam, err := camera.NewSource(logService+" "+log.Service(cfg.ID), cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Log(logService, "Serviço criado: ", cfg.ID, " (", portaria, ")")
 	return &SciPan{
-		sdpCh: messages.GetSdpToPan(),
-		cam: camera.New(
-			logService,
-			config.Config.PanCam.Address,
-			messages.GetChanPan(),
-			config.Config.PanCam.FrameRate,
-			config.Config.PanCam.ImgQuality,
-		),
-		buffer: buffer.NewBuffer(defaults.BufferSize)}
+		portaria: portaria,
+		id:       cfg.ID,
+		cam:      cam,
+		buffer:   buffer.NewBuffer(defaults.BufferSize),
+		relay:    camera.NewRelay(),
+	}, nil
 }
 
-// Run função resposanvel pelas principais chamadas das cameras
-func (s *SciPan) Run() {
-	log.Log(logService, "Serviço iniciado")
-
-	go s.cam.SendFrames()
-
-	//Fica recebendo os frames da panoramica, processando-as e salvando no buffer
-	go func() {
-		for {
-			c := <-s.cam.ChFrame
-			img := s.cam.ProcessaFrame(c)
-			s.buffer.Add(img)
-		}
-	}()
-
-	//Fica escutando o canal do SDP até receber uma imagem com placa
-	//Quando chega alguma, busca no buffer da panoramica a imagem correspondente
-	go func() {
-		for {
-			f := <-s.sdpCh
-			var msg *messages.Msg
-			if msg = s.buscaFrame(f); msg.Err == nil {
-				msg.Err = s.ValidaTimeFrame(*msg, f)
-			}
+// Source retorna a origem de frames da câmera
+func (s *SciPan) Source() camera.FrameSource {
+	return s.cam
+}
 
-			if msg.Err != nil {
-				log.Log(logService, fmt.Sprintf("Mensagem enviada ao SLP (ID: %4.d) com erro: %s", msg.ID, msg.Err.Error()))
-			} else {
-				log.Log(logService, fmt.Sprintf("Mensagem enviada ao SLP. ID: %4.d", msg.ID))
-			}
-			messages.SendPanToScd(*msg)
-		}
-	}()
+// Relay retorna a distribuição dos frames da câmera aos visualizadores ao vivo
+func (s *SciPan) Relay() *camera.Relay {
+	return s.relay
 }
 
-//Procura no buffer a imagem com o timestamp mais proximo ao recebido
-func (s *SciPan) buscaFrame(panRcv messages.PanReceive) *messages.Msg {
-	var img *image.ImageStruct
-	var err error
+// Run recebe os frames da panorâmica e os mantém no buffer até o fim da
+// captura, enviando-os também aos visualizadores ao vivo
+func (s *SciPan) Run() {
+	log.Log(logService, "Serviço iniciado: ", s.id)
 
-	if img, err = s.buffer.Frame(panRcv.Time); err != nil {
-		return &messages.Msg{
-			Err: err,
-		}
+	if err := s.cam.Start(); err != nil {
+		log.Log(logService, "Erro ao iniciar a câmera ", s.id, ": ", err)
+		return
 	}
-	return &messages.Msg{
-		ID:    panRcv.ID,
-		Frame: *img,
+	defer s.relay.Close()
+	for img := range s.cam.Frames() {
+		s.buffer.Add(img)
+		s.relay.Publish(img)
 	}
+	log.Log(logService, "Serviço encerrado: ", s.id)
 }
 
-//ValidaTimeFrame se o frame retornado pelo buffer está dentro do tempo maximo e mínimo
-func (s *SciPan) ValidaTimeFrame(msg messages.Msg, f messages.PanReceive) error {
-	bufferTime := f.Time
-	timeZoom := msg.Frame.Time
+// Frame retorna o frame do buffer com o timestamp mais próximo ao frame zoom
+// do tempo t, dentro do intervalo permitido
+func (s *SciPan) Frame(t time.Time) (*image.ImageStruct, error) {
+	img, err := s.buffer.Frame(t)
+	if err != nil {
+		return nil, err
+	}
+	if err := ValidaTimeFrame(img.Time, t); err != nil {
+		return nil, err
+	}
+	return img, nil
+}
 
+// ValidaTimeFrame se o frame retornado pelo buffer está dentro do tempo maximo e mínimo
+func ValidaTimeFrame(bufferTime, timeZoom time.Time) error {
 	timeMax := timeZoom.Add(defaults.TimeMax)
 	timeMin := timeZoom.Add(defaults.TimeMin)
 
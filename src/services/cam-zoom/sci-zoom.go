@@ -1,10 +1,9 @@
 package scizoom
 
 import (
-	"fmt"
-
 	"github.com/gustavolimam/control-access/src/components/camera"
 	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/defaults"
 	"github.com/gustavolimam/control-access/src/components/log"
 	"github.com/gustavolimam/control-access/src/components/messages"
 )
@@ -13,47 +12,63 @@ const (
 	logService log.Service = "CAM-ZOOM"
 )
 
-var (
-	frameID = 0
-)
-
-// SciZoom representa a estrutua do serviço SCI-ZOOM
+// SciZoom representa a estrutua do serviço SCI-ZOOM de uma câmera de placas
 type SciZoom struct {
-	cam *camera.Camera
+	portaria string
+	id       string
+	cam      camera.FrameSource
+	relay    *camera.Relay
+	frameID  int
+}
+
+// New retorna uma estrutura do servço sci-zoom para a câmera de placas da portaria
+func New(portaria string, cfg config.CamCfg) (*SciZoom, error) {
+	cam, err := camera.NewSource(logService+" "+log.Service(cfg.ID), cfg)
+	if err != nil {
+		return nil, err
+	}
+	log.Log(logService, "Serviço criado: ", cfg.ID, " (", portaria, ")")
+	return &SciZoom{portaria: portaria, id: cfg.ID, cam: cam, relay: camera.NewRelay()}, nil
 }
 
-// New retorna uma estrutura do servço sci-zoom
-func New() *SciZoom {
-	log.Log(logService, "Serviço criado")
-	return &SciZoom{cam: camera.New(
-		"CAM-ZOOM",
-		config.Config.ZoomCam.Address,
-		messages.GetChanZoom(),
-		config.Config.ZoomCam.FrameRate,
-		config.Config.ZoomCam.ImgQuality)}
+// Source retorna a origem de frames da câmera
+func (s *SciZoom) Source() camera.FrameSource {
+	return s.cam
+}
+
+// Relay retorna a distribuição dos frames da câmera aos visualizadores ao vivo
+func (s *SciZoom) Relay() *camera.Relay {
+	return s.relay
 }
 
 // Run realiza a função do serviço sci-zoom:
-// 1. Recebe frames da camera zoom
-// 2. Processa o frame atribuindo informações (modo noturno e timestamp)
-// 3. Envia para o sdp
+// 1. Recebe frames da camera zoom, com as informações de modo noturno e timestamp
+// 2. Identifica o frame com a portaria e a câmera
+// 3. Envia para o slp e aos visualizadores ao vivo
 func (s *SciZoom) Run() {
-	log.Log(logService, "Serviço iniciado")
+	log.Log(logService, "Serviço iniciado: ", s.id)
 
-	go s.cam.SendFrames()
+	if err := s.cam.Start(); err != nil {
+		log.Log(logService, "Erro ao iniciar a câmera ", s.id, ": ", err)
+		return
+	}
 
-	for {
+	defer s.relay.Close()
+	for img := range s.cam.Frames() {
 		// Recebimento de frames da camera
-		if frameID > defaults.IDMax {
-			frameID = 1
+		s.relay.Publish(img)
+		if s.frameID >= defaults.IDMax {
+			s.frameID = 0
 		}
+		s.frameID++
 
-		c := <-s.cam.ChFrame
-		img := s.cam.ProcessaFrame(c)
-
-		frameID++
-
-		messages.SendResultSdp(&image.ImageZoomID{ZoomID: frameID, Img: img})
-		log.Log(logService, fmt.Sprintln("Imagem Zoom enviada ao SDP - IDZoom = ", frameID))
+		messages.SendResultSlp(messages.Msg{
+			Portaria: s.portaria,
+			Camera:   s.id,
+			ZoomID:   s.frameID,
+			ID:       s.frameID,
+			Frame:    *img,
+		})
 	}
+	log.Log(logService, "Serviço encerrado: ", s.id)
 }
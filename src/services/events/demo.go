@@ -0,0 +1,48 @@
+package events
+
+import (
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/messages"
+)
+
+const (
+	modoDemo = "demo" // valor de config.Config.Eventos.Modo que habilita o simulador
+
+	intervaloEntradaDemo = 20 * time.Second
+	intervaloSaidaDemo   = 30 * time.Second
+)
+
+// simula envia placas fictícias para o serviço de eventos pelo mesmo canal
+// utilizado pelas câmeras, para demonstração do sistema sem câmeras
+func simula() {
+	// Simula o envio de dados de entrada a cada 20 segundos
+	tickerEntrada := time.NewTicker(intervaloEntradaDemo)
+	go func() {
+		for range tickerEntrada.C {
+			log.Log(logService, "Simulando entrada de veiculo")
+			messages.SendPlateToEvents(messages.PlatePackage{
+				Placa:     "EXI7254",
+				Tempo:     time.Now(),
+				Portaria:  "Principal",
+				Tipo:      defaults.Entrada,
+				Confianca: 1,
+			})
+		}
+	}()
+
+	// Simula o envio de dados de saída a cada 30 segundos
+	tickerSaida := time.NewTicker(intervaloSaidaDemo)
+	for range tickerSaida.C {
+		log.Log(logService, "Simulando saída de veiculo")
+		messages.SendPlateToEvents(messages.PlatePackage{
+			Placa:     "EXI7254",
+			Tempo:     time.Now(),
+			Portaria:  "Iguatemi",
+			Tipo:      defaults.Saida,
+			Confianca: 1,
+		})
+	}
+}
@@ -1,11 +1,19 @@
 package events
 
 import (
-	"time"
-
+	"github.com/gustavolimam/control-access/src/components/access"
+	"github.com/gustavolimam/control-access/src/components/config"
 	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/gate"
 	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/messages"
+	"github.com/gustavolimam/control-access/src/components/outbox"
+	"github.com/gustavolimam/control-access/src/components/override"
+	"github.com/gustavolimam/control-access/src/components/passes"
+	"github.com/gustavolimam/control-access/src/components/plate"
 	"github.com/gustavolimam/control-access/src/components/storage"
+	"github.com/gustavolimam/control-access/src/components/visits"
+	"github.com/gustavolimam/control-access/src/components/watchlist"
 )
 
 const (
@@ -14,66 +22,178 @@ const (
 
 // EventSys estrutura do serviço de eventos
 type EventSys struct {
-	WebCh chan defaults.EventoVeiculo
+	WebCh   chan defaults.EventoVeiculo
+	store   storage.Store
+	outbox  *outbox.Outbox
+	tracker *visits.Tracker
+	engine  *access.Engine
+	gates   *gate.Manager
+	pending *override.Manager
+	passes  *passes.Manager
+	watch   *watchlist.Watchlist
 }
 
-// New instancia o serviço de eventos
-func New() *EventSys {
+// New instancia o serviço de eventos, que persiste os dados através do store,
+// mantém a ocupação do campus no tracker, decide o acesso através do engine,
+// encaminha ao operador através do pending os eventos que dependem de
+// confirmação, abre as cancelas através do gates, registra o uso dos passes
+// de visitantes através do passes e alerta as placas observadas através do wl
+func New(store storage.Store, tracker *visits.Tracker, engine *access.Engine, gates *gate.Manager,
+	pending *override.Manager, pas *passes.Manager, wl *watchlist.Watchlist) *EventSys {
 	log.Log(logService, "Criado Serviço")
 
 	ev := new(EventSys)
 	ev.WebCh = make(chan defaults.EventoVeiculo, 300)
+	ev.store = store
+	ev.tracker = tracker
+	ev.engine = engine
+	ev.gates = gates
+	ev.pending = pending
+	ev.passes = pas
+	ev.watch = wl
+
+	ob, err := outbox.New(config.Config.Path.Outbox, ev.send)
+	if err != nil {
+		log.Log(logService, "Erro ao abrir fila de eventos pendentes: ", err)
+		return nil
+	}
+	ev.outbox = ob
 
 	return ev
 }
 
-// Run função responsável por ficar enviando os dados de evento de entrada e saída da portaria.
+// Run função responsável por receber as placas reconhecidas nas portarias e
+// gerar os eventos de entrada e saída
 func (ev *EventSys) Run() {
 	log.Log(logService, "Iniando a recepção de dados de entrada ou saida da portaria")
 
-	// Simula o envio de dados a cada 2 minutos
-	tickerEntrada := time.NewTicker(20 * time.Second)
-	go func() {
-		for _ = range tickerEntrada.C {
-			log.Log(logService, "Novo evento de entrada de veiculo")
-			// Criação de dados fakes para simular a entrada de um veiculo
-			dadosTest := defaults.EventoVeiculo{
-				Placa:    "EXI7254",
-				Tempo:    time.Now(),
-				Portaria: "Principal",
-			}
+	go ev.outbox.Run()
 
-			if err := storage.SendEntryToDB(dadosTest); err != nil {
-				log.Log(logService, "Erro ao tentar enviar informação de entrada - erro: ", err)
-			}
+	if config.Config.Eventos.Modo == modoDemo {
+		log.Log(logService, "Modo demonstração: eventos de veículos simulados")
+		go simula()
+	}
+
+	placas := messages.GetPlateToEvents()
+	for {
+		pkg := <-placas
+		evento := defaults.EventoVeiculo{
+			Placa:     plate.Normalize(pkg.Placa),
+			Tempo:     pkg.Tempo,
+			Portaria:  pkg.Portaria,
+			Tipo:      pkg.Tipo,
+			Confianca: pkg.Confianca,
+			Revisar:   pkg.Revisar,
 		}
-	}()
-
-	// Simula o envio de dados a cada 3 minutos
-	tickerSaida := time.NewTicker(30 * time.Second)
-	go func() {
-		for _ = range tickerSaida.C {
-			log.Log(logService, "Novo evento de saída de veiculo")
-			// Criação de dados fakes para simular a entrada de um veiculo
-			dadosTest := defaults.EventoVeiculo{
-				Placa:    "EXI7254",
-				Tempo:    time.Now(),
-				Portaria: "Iguatemi",
+		if evento.Tipo == "" {
+			// Portaria de entrada e saída: o sentido é definido pela ocupação do
+			// campus, apenas pela placa exata. A saída definida é pareada com a
+			// entrada tolerando os erros de reconhecimento
+			evento.Tipo = defaults.Entrada
+			if ev.tracker.Inside(evento.Placa, evento.Tempo) {
+				evento.Tipo = defaults.Saida
 			}
+		}
+		log.Log(logService, "Novo evento de ", evento.Tipo, " de veiculo - placa: ", evento.Placa,
+			" portaria: ", evento.Portaria)
+		if err := plate.Validate(evento.Placa); err != nil {
+			// O evento é mantido, a leitura pode corresponder a uma placa estrangeira ou especial
+			log.Log(logService, "Placa ", evento.Placa, " fora dos formatos antigo e Mercosul")
+		}
 
-			if err := storage.SendExitToDB(dadosTest); err != nil {
-				log.Log(logService, "Erro ao tentar enviar informação de saída - erro: ", err)
-			}
+		// Alerta a segurança caso a placa esteja na lista de observação
+		ev.watch.Check(evento)
+
+		// Decide o acesso do veículo. A decisão é gravada junto ao evento para auditoria
+		decisao := ev.engine.Decide(evento)
+		evento.Decisao = &decisao
+		if decisao.Resultado == defaults.AguardaOperador {
+			// A espera pelo operador não pode bloquear os eventos das demais portarias
+			go ev.aguardaOperador(evento, pkg.ZoomFrame, pkg.PanFrame)
+			continue
 		}
-	}()
 
-	// for {
-	// 	newEvent := <-dadosFakes
-	// 	log.Log(logService, "Nova entrada de veículo detecta na portaria principal")
+		ev.conclui(evento)
+	}
+}
+
+// aguardaOperador aguarda a decisão do operador para o evento e conclui o
+// evento com a decisão recebida
+func (ev *EventSys) aguardaOperador(evento defaults.EventoVeiculo, zoom, pan []byte) {
+	decisao := ev.pending.Wait(evento, zoom, pan)
+	evento.Decisao = &decisao
+	ev.conclui(evento)
+}
+
+// conclui abre a cancela caso o acesso tenha sido permitido, registra o
+// evento e o envia ao front
+func (ev *EventSys) conclui(evento defaults.EventoVeiculo) {
+	if evento.Decisao.Resultado == defaults.Permitido {
+		go ev.abreCancela(evento)
+		ev.registraPasse(evento)
+	}
+
+	// Envia as informações do evento para serem salvas no banco de dados
+	ev.registra(evento)
+
+	// Envia as informações do evento para serem mostradas no Front, sem
+	// bloquear a recepção caso o front não esteja consumindo
+	select {
+	case ev.WebCh <- evento:
+	default:
+		log.Log(logService, "Canal do front cheio, evento não enviado ao front - placa: ", evento.Placa)
+	}
+}
+
+// PendingEvents retorna a quantidade de eventos aguardando envio ao banco
+func (ev *EventSys) PendingEvents() int {
+	return ev.outbox.Len()
+}
+
+// abreCancela aciona a cancela da portaria do evento
+func (ev *EventSys) abreCancela(evento defaults.EventoVeiculo) {
+	switch err := ev.gates.Open(evento); err {
+	case nil:
+	case gate.ErrCooldown:
+		log.Log(logService, "Cancela ", evento.Portaria, " já acionada para a placa ", evento.Placa)
+	default:
+		log.Log(logService, "Erro ao abrir cancela ", evento.Portaria, " - placa: ", evento.Placa, " - erro: ", err)
+	}
+}
+
+// registra atualiza a ocupação e persiste o evento na fila em disco antes do
+// envio ao banco
+func (ev *EventSys) registra(evento defaults.EventoVeiculo) {
+	ev.tracker.Registra(evento)
+	if err := ev.outbox.Add(evento); err != nil {
+		log.Log(logService, "Erro ao gravar evento na fila - erro: ", err)
+	}
+}
+
+// registraPasse registra a entrada com passe de visitante e encerra os
+// passes de uso único na saída do veículo
+func (ev *EventSys) registraPasse(evento defaults.EventoVeiculo) {
+	switch {
+	case evento.Tipo == defaults.Saida:
+		ev.passes.Exit(evento.Placa, evento.Tempo)
+	case evento.Decisao.Regra == access.RegraPasse:
+		ev.passes.Entry(evento.Placa, evento.Portaria, evento.Tempo)
+	}
+}
+
+// send envia o evento para o banco de acordo com o seu tipo. É chamado pela
+// fila de eventos, que repete o envio enquanto houver erro
+func (ev *EventSys) send(evento defaults.EventoVeiculo) error {
+	var err error
+	switch evento.Tipo {
+	case defaults.Saida:
+		err = ev.store.RecordExit(evento)
+	default:
+		err = ev.store.RecordEntry(evento)
+	}
 
-	// 	// Envia as informações de evento de entrada para serem mostrada no Front
-	// 	ev.WebCh <- newEvent
-	// 	// Envia as informações de evento de entrada para serem salvas no banco de dados
-	// 	storage.SendEntryToDB(newEvent)
-	// }
+	if err != nil {
+		log.Log(logService, "Erro ao tentar enviar informação de ", evento.Tipo, " - erro: ", err)
+	}
+	return err
 }
@@ -0,0 +1,218 @@
+// O pacote ingest implementa a ingestão das câmeras das portarias. Cada
+// portaria possui um Pipeline: as câmeras de placas enviam os frames ao slp,
+// as panorâmicas mantêm o buffer de frames e as placas reconhecidas são
+// consolidadas com a imagem panorâmica correspondente e enviadas ao serviço
+// de eventos com o nome e o sentido da portaria
+package ingest
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gustavolimam/control-access/src/components/buffer"
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/messages"
+	scipan "github.com/gustavolimam/control-access/src/services/cam-panoramica"
+	scizoom "github.com/gustavolimam/control-access/src/services/cam-zoom"
+)
+
+const (
+	logService log.Service = "INGEST"
+
+	// Sentidos das passagens de uma portaria
+	SentidoEntrada = "entrada"
+	SentidoSaida   = "saida"
+	SentidoAmbos   = "ambos" // definido pela ocupação do campus no serviço de eventos
+
+	// Funções das câmeras de uma portaria
+	FuncaoPlaca      = "placa"      // câmera zoom, enviada ao reconhecimento de placas
+	FuncaoPanoramica = "panoramica" // câmera de visão geral, anexada aos eventos
+)
+
+var (
+	errPortariaSemNome = errors.New("Portaria sem nome na configuração")
+	errSentidoInvalido = errors.New("Sentido da portaria inválido, utilize entrada, saida ou ambos")
+	errFuncaoInvalida  = errors.New("Função da câmera inválida, utilize placa ou panoramica")
+)
+
+// Pipeline representa a ingestão das câmeras de uma portaria
+type Pipeline struct {
+	cfg        config.PortariaConfig
+	tipo       defaults.TipoEvento
+	zooms      []*scizoom.SciZoom
+	pans       []*scipan.SciPan
+	resultados chan messages.SlpPackage
+	placas     *buffer.InfraBuffer // placas enviadas recentemente, para descartar leituras repetidas
+}
+
+// Ingest mantém os pipelines das portarias configuradas
+type Ingest struct {
+	pipelines []*Pipeline
+	portarias map[string]*Pipeline
+	cameras   []*monitorada
+
+	// Alarmes recebe os alarmes das câmeras sem frames e a sua normalização
+	Alarmes chan Alarme
+}
+
+// New cria os pipelines das portarias configuradas. Câmeras sem
+// identificador recebem o identificador portaria-funcao-n
+func New(cfgs []config.PortariaConfig) (*Ingest, error) {
+	in := &Ingest{portarias: map[string]*Pipeline{}, Alarmes: make(chan Alarme, defaults.BufferChannel)}
+	cameras := map[string]bool{}
+
+	for _, cfg := range cfgs {
+		if strings.TrimSpace(cfg.Nome) == "" {
+			return nil, errPortariaSemNome
+		}
+		if _, ok := in.portarias[cfg.Nome]; ok {
+			return nil, fmt.Errorf("Portaria %s duplicada na configuração", cfg.Nome)
+		}
+
+		p := &Pipeline{
+			cfg:        cfg,
+			resultados: make(chan messages.SlpPackage, defaults.BufferChannel),
+			placas:     buffer.NewPlateBuffer(),
+		}
+		switch cfg.Sentido {
+		case SentidoEntrada:
+			p.tipo = defaults.Entrada
+		case SentidoSaida:
+			p.tipo = defaults.Saida
+		case SentidoAmbos:
+		default:
+			return nil, errSentidoInvalido
+		}
+
+		p.cfg.Cameras = make([]config.CamCfg, len(cfg.Cameras))
+		funcoes := map[string]int{}
+		for i, cam := range cfg.Cameras {
+			funcoes[cam.Funcao]++
+			if cam.ID == "" {
+				cam.ID = fmt.Sprintf("%s-%s-%d", strings.Join(strings.Fields(strings.ToLower(cfg.Nome)), "-"),
+					cam.Funcao, funcoes[cam.Funcao])
+			}
+			if cameras[cam.ID] {
+				return nil, fmt.Errorf("Câmera %s duplicada na configuração", cam.ID)
+			}
+			cameras[cam.ID] = true
+			p.cfg.Cameras[i] = cam
+
+			switch cam.Funcao {
+			case FuncaoPlaca:
+				z, err := scizoom.New(cfg.Nome, cam)
+				if err != nil {
+					return nil, fmt.Errorf("Câmera %s: %v", cam.ID, err)
+				}
+				p.zooms = append(p.zooms, z)
+				in.cameras = append(in.cameras, &monitorada{portaria: cfg.Nome, cfg: cam, src: z.Source(), relay: z.Relay()})
+			case FuncaoPanoramica:
+				pan, err := scipan.New(cfg.Nome, cam)
+				if err != nil {
+					return nil, fmt.Errorf("Câmera %s: %v", cam.ID, err)
+				}
+				p.pans = append(p.pans, pan)
+				in.cameras = append(in.cameras, &monitorada{portaria: cfg.Nome, cfg: cam, src: pan.Source(), relay: pan.Relay()})
+			default:
+				return nil, errFuncaoInvalida
+			}
+		}
+		if len(p.zooms) == 0 {
+			log.Log(logService, "Portaria ", cfg.Nome, " sem câmera de placas, nenhum evento será gerado")
+		}
+
+		in.pipelines = append(in.pipelines, p)
+		in.portarias[cfg.Nome] = p
+	}
+	return in, nil
+}
+
+// Run inicia os pipelines das portarias e encaminha o resultado do
+// reconhecimento de placas ao pipeline da portaria do frame
+func (in *Ingest) Run() {
+	log.Log(logService, "Iniciando a ingestão de ", len(in.pipelines), " portarias")
+
+	for _, p := range in.pipelines {
+		p.run()
+	}
+	go in.monitora()
+
+	resultados := messages.GetSlpToScdCh()
+	for pkg := range resultados {
+		p, ok := in.portarias[pkg.Portaria]
+		if !ok {
+			log.Log(logService, "Placa reconhecida em portaria desconhecida: ", pkg.Portaria)
+			continue
+		}
+		select {
+		case p.resultados <- pkg:
+		default:
+			log.Log(logService, "Fila da portaria ", pkg.Portaria, " cheia, placa ", pkg.PlateInfo.Placa, " descartada")
+		}
+	}
+}
+
+// Config retorna a configuração da portaria, com os identificadores das câmeras
+func (p *Pipeline) Config() config.PortariaConfig {
+	return p.cfg
+}
+
+// run inicia as câmeras da portaria e a consolidação das placas reconhecidas
+func (p *Pipeline) run() {
+	log.Log(logService, "Portaria ", p.cfg.Nome, " (", p.cfg.Sentido, "): ", len(p.zooms), " câmeras de placas, ",
+		len(p.pans), " panorâmicas")
+
+	for _, pan := range p.pans {
+		go pan.Run()
+	}
+	for _, z := range p.zooms {
+		go z.Run()
+	}
+	go p.placas.DeletaPlateBuffer()
+	go func() {
+		for pkg := range p.resultados {
+			p.consolida(pkg)
+		}
+	}()
+}
+
+// consolida envia a placa reconhecida ao serviço de eventos com a imagem
+// panorâmica mais próxima, descartando as leituras repetidas do mesmo veículo
+func (p *Pipeline) consolida(pkg messages.SlpPackage) {
+	if pkg.JidoshaError != nil || pkg.ZoomFrame == nil {
+		return
+	}
+	tempo := pkg.ZoomFrame.Time
+	lida := messages.NewBufferPackage(pkg.PlateInfo, pkg.ZoomFrame)
+	repetida, m := p.placas.FindPlateBuffer(&lida)
+	if repetida {
+		return
+	}
+
+	placa := messages.PlatePackage{
+		Placa:     pkg.PlateInfo.Placa,
+		Tempo:     tempo,
+		Portaria:  p.cfg.Nome,
+		Tipo:      p.tipo,
+		Confianca: pkg.PlateInfo.Confianca,
+		Revisar:   m.Revisar,
+		ZoomFrame: pkg.ZoomFrame.Image,
+	}
+	if m.Revisar {
+		// Leitura parecida com outra placa recente, pode ser o mesmo veículo
+		log.Log(logService, "Placa ", placa.Placa, " semelhante à placa ", m.Placa, " lida na portaria ", p.cfg.Nome,
+			fmt.Sprintf(" (confiança %.2f), evento marcado para revisão", m.Confianca))
+	}
+	for _, pan := range p.pans {
+		if img, err := pan.Frame(tempo); err == nil {
+			placa.PanFrame = img.Image
+			break
+		}
+	}
+
+	log.Log(logService, "Placa ", placa.Placa, " reconhecida na portaria ", p.cfg.Nome, " pela câmera ", pkg.Camera)
+	messages.SendPlateToEvents(placa)
+}
@@ -0,0 +1,186 @@
+package ingest
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/camera"
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	intervaloMonitor   = 5 * time.Second // intervalo da verificação da saúde das câmeras
+	alarmeSemFramesPad = time.Minute
+)
+
+var (
+	// ErrCameraInexistente indica uma câmera que não está na configuração das portarias
+	ErrCameraInexistente = errors.New("Câmera inexistente")
+	// ErrSemFrame indica uma câmera que ainda não enviou frames
+	ErrSemFrame = errors.New("Nenhum frame recebido da câmera")
+)
+
+// CameraStatus representa a saúde de uma câmera de portaria
+type CameraStatus struct {
+	ID        string `json:"id"`
+	Portaria  string `json:"portaria"`
+	Funcao    string `json:"funcao"`
+	Tipo      string `json:"tipo"`
+	FrameRate int    `json:"frameRate"` // frames por segundo configurados
+	camera.Health
+	Alarme         bool `json:"alarme"`         // câmera sem frames por mais de Monitoramento.AlarmeSemFrames
+	Visualizadores int  `json:"visualizadores"` // operadores assistindo ao vivo
+}
+
+// Alarme representa a mudança de situação de uma câmera offline ou travada
+type Alarme struct {
+	Camera      string        `json:"camera"`
+	Portaria    string        `json:"portaria"`
+	Estado      camera.Estado `json:"estado"`
+	Ativo       bool          `json:"ativo"` // false indica que a câmera voltou a transmitir
+	UltimoFrame time.Time     `json:"ultimoFrame"`
+	Tempo       time.Time     `json:"tempo"`
+}
+
+// monitorada representa uma câmera acompanhada pelo monitoramento
+type monitorada struct {
+	portaria string
+	cfg      config.CamCfg
+	src      camera.FrameSource
+	relay    *camera.Relay
+
+	mutex  sync.Mutex
+	alarme bool
+}
+
+// status retorna a saúde atual da câmera
+func (m *monitorada) status() CameraStatus {
+	m.mutex.Lock()
+	alarme := m.alarme
+	m.mutex.Unlock()
+
+	tipo := m.cfg.Tipo
+	if tipo == "" {
+		tipo = camera.TipoVendor
+	}
+	return CameraStatus{
+		ID:             m.cfg.ID,
+		Portaria:       m.portaria,
+		Funcao:         m.cfg.Funcao,
+		Tipo:           tipo,
+		FrameRate:      m.cfg.FrameRate,
+		Health:         m.src.Health(),
+		Alarme:         alarme,
+		Visualizadores: m.relay.Viewers(),
+	}
+}
+
+// Cameras retorna a saúde das câmeras das portarias, na ordem da configuração
+func (in *Ingest) Cameras() []CameraStatus {
+	cameras := make([]CameraStatus, 0, len(in.cameras))
+	for _, m := range in.cameras {
+		cameras = append(cameras, m.status())
+	}
+	return cameras
+}
+
+// Camera retorna a saúde da câmera id
+func (in *Ingest) Camera(id string) (CameraStatus, error) {
+	m, err := in.camera(id)
+	if err != nil {
+		return CameraStatus{}, err
+	}
+	return m.status(), nil
+}
+
+// Snapshot retorna o frame mais recente da câmera id
+func (in *Ingest) Snapshot(id string) (*image.ImageStruct, error) {
+	m, err := in.camera(id)
+	if err != nil {
+		return nil, err
+	}
+	img := m.relay.Last()
+	if img == nil {
+		return nil, ErrSemFrame
+	}
+	return img, nil
+}
+
+// Subscribe registra um visualizador ao vivo da câmera id, que recebe os
+// frames da conexão já existente com a câmera. cancela deve ser chamada ao
+// fim da visualização
+func (in *Ingest) Subscribe(id string) (frames <-chan *image.ImageStruct, cancela func(), err error) {
+	m, err := in.camera(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	frames, cancela = m.relay.Subscribe()
+	return frames, cancela, nil
+}
+
+// camera retorna a câmera monitorada id
+func (in *Ingest) camera(id string) (*monitorada, error) {
+	for _, m := range in.cameras {
+		if m.cfg.ID == id {
+			return m, nil
+		}
+	}
+	return nil, ErrCameraInexistente
+}
+
+// monitora verifica periodicamente a saúde das câmeras, gerando um alarme
+// quando uma câmera fica sem frames por mais de Monitoramento.AlarmeSemFrames
+// e outro quando volta a transmitir
+func (in *Ingest) monitora() {
+	for range time.Tick(intervaloMonitor) {
+		limite := time.Duration(config.Config.Monitoramento.AlarmeSemFrames) * time.Second
+		if limite <= 0 {
+			limite = alarmeSemFramesPad
+		}
+		for _, m := range in.cameras {
+			in.verifica(m, limite)
+		}
+	}
+}
+
+// verifica gera o alarme da câmera quando a sua situação muda. Câmeras
+// paradas, encerradas ou com o replay concluído, não geram alarme
+func (in *Ingest) verifica(m *monitorada, limite time.Duration) {
+	h := m.src.Health()
+	ultimo := h.UltimoFrame
+	if ultimo.IsZero() {
+		ultimo = h.Inicio
+	}
+	semFrames := h.Estado != camera.Transmitindo && h.Estado != camera.Parada && time.Since(ultimo) > limite
+
+	m.mutex.Lock()
+	mudou := semFrames != m.alarme
+	m.alarme = semFrames
+	m.mutex.Unlock()
+	if !mudou {
+		return
+	}
+
+	a := Alarme{
+		Camera:      m.cfg.ID,
+		Portaria:    m.portaria,
+		Estado:      h.Estado,
+		Ativo:       semFrames,
+		UltimoFrame: h.UltimoFrame,
+		Tempo:       time.Now(),
+	}
+	if a.Ativo {
+		log.Log(logService, "Alarme: câmera ", a.Camera, " da portaria ", a.Portaria, " ", a.Estado,
+			" sem frames desde ", ultimo.Format("02-01-2006 15:04:05"))
+	} else {
+		log.Log(logService, "Câmera ", a.Camera, " da portaria ", a.Portaria, " voltou a transmitir")
+	}
+	select {
+	case in.Alarmes <- a:
+	default:
+		log.Log(logService, "Canal de alarmes cheio, alarme da câmera ", a.Camera, " não enviado aos operadores")
+	}
+}
@@ -0,0 +1,210 @@
+// O pacote slp implementa o serviço de leitura de placas: os frames recebidos
+// do sdp aguardam em uma fila limitada e são reconhecidos por um conjunto de
+// workers, de forma que a lentidão do reconhecimento não atrase a recepção
+// dos frames das câmeras. Com a fila cheia os frames são descartados
+// conforme a política configurada
+package slp
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/messages"
+	"github.com/gustavolimam/control-access/src/components/recognition"
+)
+
+const (
+	logService log.Service = "SLP"
+
+	// Políticas de descarte com a fila cheia
+	DescartaAntigo = "antigo"   // descarta o frame mais antigo da fila
+	DescartaNovo   = "novo"     // descarta o frame recebido
+	Bloqueia       = "bloqueia" // aguarda uma vaga na fila, atrasando o sdp
+
+	filaPadrao         = 32
+	timeoutPadrao      = 2 * time.Second
+	intervaloRelatorio = time.Minute // intervalo do registro dos descartes no log
+)
+
+var (
+	errDescarteInvalido = errors.New("Política de descarte inválida, utilize antigo, novo ou bloqueia")
+)
+
+// Stats representa os contadores do serviço desde o início
+type Stats struct {
+	Recebidos   uint64 `json:"recebidos"`   // frames recebidos do sdp
+	Processados uint64 `json:"processados"` // frames reconhecidos, com ou sem placa
+	Descartados uint64 `json:"descartados"` // frames descartados com a fila cheia
+	Expirados   uint64 `json:"expirados"`   // frames cujo reconhecimento excedeu o timeout
+	Erros       uint64 `json:"erros"`       // frames com erro no reconhecimento
+	Fila        int    `json:"fila"`        // frames aguardando reconhecimento
+}
+
+// Slp representa a estrutura do serviço slp
+type Slp struct {
+	rec      recognition.Recognizer
+	timeout  time.Duration
+	workers  int
+	descarte string
+	fila     chan messages.Msg
+
+	recebidos   uint64
+	processados uint64
+	descartados uint64
+	expirados   uint64
+	erros       uint64
+}
+
+// New cria o serviço slp, que reconhece as placas através de rec com a
+// quantidade de workers, o timeout e a fila configurados
+func New(rec recognition.Recognizer, cfg config.CfgJidosha) (*Slp, error) {
+	s := &Slp{
+		rec:      rec,
+		timeout:  time.Duration(cfg.Timeout) * time.Millisecond,
+		workers:  cfg.NumThreads,
+		descarte: cfg.Descarte,
+	}
+	if s.timeout <= 0 {
+		s.timeout = timeoutPadrao
+	}
+	if s.workers <= 0 {
+		s.workers = 1
+	}
+	switch s.descarte {
+	case "":
+		s.descarte = DescartaAntigo
+	case DescartaAntigo, DescartaNovo, Bloqueia:
+	default:
+		return nil, errDescarteInvalido
+	}
+	fila := cfg.Fila
+	if fila <= 0 {
+		fila = filaPadrao
+	}
+	s.fila = make(chan messages.Msg, fila)
+
+	log.Log(logService, "Serviço criado: ", s.workers, " workers, fila de ", fila, " frames, descarte ", s.descarte)
+	return s, nil
+}
+
+// Run realiza a função do serviço slp:
+// 1. Recebe os frames do sdp e os coloca na fila
+// 2. Reconhece as placas dos frames da fila nos workers
+// 3. Envia o resultado ao scd
+func (s *Slp) Run() {
+	log.Log(logService, "Serviço iniciado")
+
+	for i := 0; i < s.workers; i++ {
+		go s.worker()
+	}
+	go s.relatorio()
+
+	frames := messages.GetSdpToSlp()
+	for {
+		s.enfileira(<-frames)
+	}
+}
+
+// enfileira coloca o frame na fila, aplicando a política de descarte caso
+// esteja cheia
+func (s *Slp) enfileira(msg messages.Msg) {
+	atomic.AddUint64(&s.recebidos, 1)
+
+	if s.descarte == Bloqueia {
+		s.fila <- msg
+		return
+	}
+	for {
+		select {
+		case s.fila <- msg:
+			return
+		default:
+		}
+
+		if s.descarte == DescartaNovo {
+			atomic.AddUint64(&s.descartados, 1)
+			return
+		}
+		// Libera a vaga do frame mais antigo. Um worker pode ter consumido a
+		// fila nesse intervalo, nesse caso nenhum frame é descartado
+		select {
+		case <-s.fila:
+			atomic.AddUint64(&s.descartados, 1)
+		default:
+		}
+	}
+}
+
+// worker reconhece os frames da fila e envia o resultado ao scd
+func (s *Slp) worker() {
+	for msg := range s.fila {
+		messages.SendSlpToScd(s.reconhece(msg))
+	}
+}
+
+// reconhece retorna o pacote do scd com a placa de maior confiança do frame
+func (s *Slp) reconhece(msg messages.Msg) messages.SlpPackage {
+	frame := msg.Frame
+	pkg := messages.SlpPackage{
+		Portaria:  msg.Portaria,
+		Camera:    msg.Camera,
+		ZoomID:    msg.ZoomID,
+		ID:        msg.ID,
+		ZoomFrame: &frame,
+	}
+	if msg.Err != nil {
+		pkg.JidoshaError = msg.Err
+		return pkg
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.timeout)
+	defer cancel()
+
+	candidatos, err := s.rec.Recognize(ctx, frame.Image)
+	if err == nil {
+		pkg.PlateInfo, pkg.JidoshaError = recognition.Best(candidatos)
+		atomic.AddUint64(&s.processados, 1)
+		return pkg
+	}
+
+	pkg.JidoshaError = err
+	if err == recognition.ErrTimeout || ctx.Err() == context.DeadlineExceeded {
+		pkg.JidoshaError = recognition.ErrTimeout
+		atomic.AddUint64(&s.expirados, 1)
+		return pkg
+	}
+	atomic.AddUint64(&s.erros, 1)
+	log.Log(logService, "Erro no reconhecimento do frame ", msg.ID, ": ", err)
+	return pkg
+}
+
+// Stats retorna os contadores do serviço
+func (s *Slp) Stats() Stats {
+	return Stats{
+		Recebidos:   atomic.LoadUint64(&s.recebidos),
+		Processados: atomic.LoadUint64(&s.processados),
+		Descartados: atomic.LoadUint64(&s.descartados),
+		Expirados:   atomic.LoadUint64(&s.expirados),
+		Erros:       atomic.LoadUint64(&s.erros),
+		Fila:        len(s.fila),
+	}
+}
+
+// relatorio registra no log os frames descartados e expirados a cada
+// intervaloRelatorio, quando houver
+func (s *Slp) relatorio() {
+	var anterior Stats
+	for range time.Tick(intervaloRelatorio) {
+		atual := s.Stats()
+		if atual.Descartados != anterior.Descartados || atual.Expirados != anterior.Expirados {
+			log.Log(logService, "Frames no último minuto: ", atual.Processados-anterior.Processados, " processados, ",
+				atual.Descartados-anterior.Descartados, " descartados, ", atual.Expirados-anterior.Expirados,
+				" expirados, fila com ", atual.Fila)
+		}
+		anterior = atual
+	}
+}
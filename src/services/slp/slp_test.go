@@ -0,0 +1,218 @@
+package slp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/image"
+	"github.com/gustavolimam/control-access/src/components/messages"
+	"github.com/gustavolimam/control-access/src/components/recognition"
+)
+
+const timeoutTeste = 5 * time.Second
+
+// bloqueado é um motor de reconhecimento que só responde após liberado ou
+// com o fim do contexto
+type bloqueado struct {
+	iniciado chan struct{}
+	libera   chan struct{}
+}
+
+func novoBloqueado() *bloqueado {
+	return &bloqueado{iniciado: make(chan struct{}, 100), libera: make(chan struct{})}
+}
+
+func (b *bloqueado) Recognize(ctx context.Context, jpeg []byte) ([]recognition.Candidate, error) {
+	b.iniciado <- struct{}{}
+	select {
+	case <-b.libera:
+		return nil, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// frame cria a mensagem do sdp com o identificador informado
+func frame(id int) messages.Msg {
+	return messages.Msg{Portaria: "P1", Camera: "C1", ID: id, Frame: image.ImageStruct{Image: []byte{0xFF, 0xD8, byte(id), 0xFF, 0xD9}}}
+}
+
+// novoSlp cria o serviço sem iniciar os workers
+func novoSlp(t *testing.T, rec recognition.Recognizer, cfg config.CfgJidosha) *Slp {
+	t.Helper()
+	s, err := New(rec, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// fila retorna os identificadores dos frames na fila, esvaziando-a
+func fila(s *Slp) []int {
+	var ids []int
+	for {
+		select {
+		case msg := <-s.fila:
+			ids = append(ids, msg.ID)
+		default:
+			return ids
+		}
+	}
+}
+
+// descartaResultados remove os resultados enviados ao scd pelos workers
+func descartaResultados() {
+	for {
+		select {
+		case <-messages.GetSlpToScdCh():
+		default:
+			return
+		}
+	}
+}
+
+func TestNewDescarteInvalido(t *testing.T) {
+	if _, err := New(recognition.NewFake(), config.CfgJidosha{Descarte: "todos"}); err != errDescarteInvalido {
+		t.Errorf("New com descarte inválido = %v, esperado %v", err, errDescarteInvalido)
+	}
+}
+
+func TestEnfileiraFilaCheia(t *testing.T) {
+	casos := []struct {
+		nome        string
+		descarte    string
+		fila        []int
+		descartados uint64
+	}{
+		{"política padrão descarta o mais antigo", "", []int{4, 5}, 3},
+		{"descarta o mais antigo", DescartaAntigo, []int{4, 5}, 3},
+		{"descarta o recebido", DescartaNovo, []int{1, 2}, 3},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			s := novoSlp(t, recognition.NewFake(), config.CfgJidosha{Descarte: c.descarte, Fila: 2})
+			for id := 1; id <= 5; id++ {
+				s.enfileira(frame(id))
+			}
+
+			st := s.Stats()
+			if st.Recebidos != 5 || st.Descartados != c.descartados || st.Fila != len(c.fila) {
+				t.Errorf("contadores = %+v, esperado %d descartados", st, c.descartados)
+			}
+			ids := fila(s)
+			if len(ids) != len(c.fila) {
+				t.Fatalf("fila = %v, esperado %v", ids, c.fila)
+			}
+			for i := range ids {
+				if ids[i] != c.fila[i] {
+					t.Errorf("fila = %v, esperado %v", ids, c.fila)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestEnfileiraBloqueia(t *testing.T) {
+	rec := novoBloqueado()
+	s := novoSlp(t, rec, config.CfgJidosha{Descarte: Bloqueia, Fila: 1, Timeout: int(timeoutTeste / time.Millisecond)})
+	defer descartaResultados()
+	go s.worker()
+
+	// O worker fica bloqueado no primeiro frame e o segundo ocupa a fila
+	s.enfileira(frame(1))
+	select {
+	case <-rec.iniciado:
+	case <-time.After(timeoutTeste):
+		t.Fatal("reconhecimento não iniciado")
+	}
+	s.enfileira(frame(2))
+
+	enfileirado := make(chan struct{})
+	go func() {
+		s.enfileira(frame(3))
+		close(enfileirado)
+	}()
+	select {
+	case <-enfileirado:
+		t.Fatal("frame enfileirado com a fila cheia")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(rec.libera)
+	select {
+	case <-enfileirado:
+	case <-time.After(timeoutTeste):
+		t.Fatal("frame não enfileirado após liberar a fila")
+	}
+	if st := s.Stats(); st.Recebidos != 3 || st.Descartados != 0 {
+		t.Errorf("contadores = %+v, nenhum descarte esperado", st)
+	}
+}
+
+func TestReconhece(t *testing.T) {
+	lento := recognition.NewFake(recognition.Candidate{Placa: "ABC1234", Confianca: 0.9})
+	lento.SetDelay(time.Second)
+	comErro := recognition.NewFake()
+	comErro.SetError(errors.New("motor indisponível"))
+
+	casos := []struct {
+		nome  string
+		rec   recognition.Recognizer
+		placa string
+		erro  error
+		quer  Stats
+	}{
+		{
+			nome:  "placa reconhecida",
+			rec:   recognition.NewFake(recognition.Candidate{Placa: "ABC1234", Confianca: 0.9}),
+			placa: "ABC1234",
+			quer:  Stats{Processados: 1},
+		},
+		{
+			nome: "frame sem placa",
+			rec:  recognition.NewFake(),
+			erro: recognition.ErrSemPlaca,
+			quer: Stats{Processados: 1},
+		},
+		{
+			nome: "motor lento expira",
+			rec:  lento,
+			erro: recognition.ErrTimeout,
+			quer: Stats{Expirados: 1},
+		},
+		{
+			nome: "motor bloqueado expira",
+			rec:  novoBloqueado(),
+			erro: recognition.ErrTimeout,
+			quer: Stats{Expirados: 1},
+		},
+		{
+			nome: "erro do motor",
+			rec:  comErro,
+			quer: Stats{Erros: 1},
+		},
+	}
+	for _, c := range casos {
+		t.Run(c.nome, func(t *testing.T) {
+			s := novoSlp(t, c.rec, config.CfgJidosha{Timeout: 50})
+			pkg := s.reconhece(frame(1))
+
+			if pkg.PlateInfo.Placa != c.placa {
+				t.Errorf("placa = %q, esperado %q", pkg.PlateInfo.Placa, c.placa)
+			}
+			if c.erro != nil && pkg.JidoshaError != c.erro {
+				t.Errorf("erro = %v, esperado %v", pkg.JidoshaError, c.erro)
+			}
+			if c.erro == nil && c.placa == "" && pkg.JidoshaError == nil {
+				t.Error("erro do motor não informado")
+			}
+			if st := s.Stats(); st != c.quer {
+				t.Errorf("contadores = %+v, esperado %+v", st, c.quer)
+			}
+		})
+	}
+}
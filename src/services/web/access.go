@@ -0,0 +1,40 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/access"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+// accessAPIEndPoints registra as rotas de consulta e alteração das regras de acesso
+func (ws *WebSys) accessAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/access/rules", handleWith(ws.getAccessRules, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/access/rules", handleWith(ws.putAccessRules, ws.permite(auth.Configurar), ws.autenticado)).Methods("PUT")
+}
+
+// getAccessRules retorna as regras de acesso vigentes
+func (ws *WebSys) getAccessRules(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.engine.Rules())
+}
+
+// putAccessRules substitui as regras de acesso
+func (ws *WebSys) putAccessRules(w http.ResponseWriter, r *http.Request) {
+	var regras access.Rules
+	if err := decodifica(w, r, &regras); err != nil {
+		return
+	}
+	if err := regras.Validate(); err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	registraAntes(r, ws.engine.Rules())
+	if err := ws.engine.SetRules(regras); err != nil {
+		log.Log(logService, "Erro ao salvar regras de acesso: ", err)
+		serveInternalError(w, "não foi possível salvar as regras de acesso: %v", err)
+		return
+	}
+	serveResult(w, ws.engine.Rules())
+}
@@ -0,0 +1,154 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+// conta representa o corpo das requisições de cadastro de usuários
+type conta struct {
+	auth.User
+	Senha string `json:"senha,omitempty"`
+}
+
+// accountsAPIEndPoints registra as rotas de gestão de usuários e papéis
+func (ws *WebSys) accountsAPIEndPoints(api *mux.Router) {
+	gerenciar := ws.permite(auth.GerenciarUsuarios)
+
+	api.HandleFunc("/accounts", handleWith(ws.getAccounts, gerenciar, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/accounts", handleWith(ws.postAccount, gerenciar, ws.autenticado)).Methods("POST")
+	api.HandleFunc("/accounts/{usuario}", handleWith(ws.getAccount, gerenciar, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/accounts/{usuario}", handleWith(ws.putAccount, gerenciar, ws.autenticado)).Methods("PUT")
+	api.HandleFunc("/accounts/{usuario}", handleWith(ws.deleteAccount, gerenciar, ws.autenticado)).Methods("DELETE")
+
+	api.HandleFunc("/roles", handleWith(ws.getRoles, gerenciar, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/roles/{papel}", handleWith(ws.putRole, gerenciar, ws.autenticado)).Methods("PUT")
+}
+
+// serveAccountError envia ao cliente o erro retornado pela gestão de usuários
+func serveAccountError(w http.ResponseWriter, err error) {
+	switch err {
+	case storage.ErrNotFound:
+		serveNotFound(w, "%v", err)
+	case auth.ErrUltimoAdmin:
+		serveCustomError(w, http.StatusConflict, "conflict", "%v", err)
+	case auth.ErrSenhaCurta, auth.ErrPapelInvalido, auth.ErrPermissaoInvalida, auth.ErrPermissaoAdmin:
+		serveBadRequest(w, "%v", err)
+	default:
+		log.Log(logService, "Erro na gestão de usuários: ", err)
+		serveInternalError(w, "erro na gestão de usuários: %v", err)
+	}
+}
+
+// getAccounts retorna os usuários cadastrados
+func (ws *WebSys) getAccounts(w http.ResponseWriter, r *http.Request) {
+	usuarios, err := ws.auth.Users()
+	if err != nil {
+		serveAccountError(w, err)
+		return
+	}
+	serveResult(w, usuarios)
+}
+
+// getAccount retorna o usuário informado
+func (ws *WebSys) getAccount(w http.ResponseWriter, r *http.Request) {
+	u, err := ws.auth.User(mux.Vars(r)["usuario"])
+	if err != nil {
+		serveAccountError(w, err)
+		return
+	}
+	serveResult(w, u.Publico())
+}
+
+// postAccount cadastra um novo usuário
+func (ws *WebSys) postAccount(w http.ResponseWriter, r *http.Request) {
+	var c conta
+	if err := decodifica(w, r, &c); err != nil {
+		return
+	}
+	if _, err := ws.auth.User(c.Usuario); err == nil {
+		serveCustomError(w, http.StatusConflict, "conflict", "usuário %s já cadastrado", c.Usuario)
+		return
+	}
+	if c.Senha == "" {
+		serveAccountError(w, auth.ErrSenhaCurta)
+		return
+	}
+
+	ws.salvaConta(w, r, c)
+}
+
+// putAccount altera o usuário informado. Senha vazia mantém a senha atual
+func (ws *WebSys) putAccount(w http.ResponseWriter, r *http.Request) {
+	var c conta
+	if err := decodifica(w, r, &c); err != nil {
+		return
+	}
+	c.Usuario = mux.Vars(r)["usuario"]
+	antes, err := ws.auth.User(c.Usuario)
+	if err != nil {
+		serveAccountError(w, err)
+		return
+	}
+	registraAntes(r, antes.Publico())
+
+	ws.salvaConta(w, r, c)
+}
+
+// salvaConta grava o usuário e envia o resultado ao cliente
+func (ws *WebSys) salvaConta(w http.ResponseWriter, r *http.Request, c conta) {
+	if err := ws.auth.SaveUser(c.User, c.Senha); err != nil {
+		serveAccountError(w, err)
+		return
+	}
+
+	sessao, _ := sessaoConectada(r)
+	log.Log(logService, "Usuário ", c.Usuario, " (", c.Papel, ") salvo por ", sessao.Usuario.Usuario)
+	u, err := ws.auth.User(c.Usuario)
+	if err != nil {
+		serveAccountError(w, err)
+		return
+	}
+	serveResult(w, u.Publico())
+}
+
+// deleteAccount remove o usuário informado
+func (ws *WebSys) deleteAccount(w http.ResponseWriter, r *http.Request) {
+	usuario := mux.Vars(r)["usuario"]
+	if antes, err := ws.auth.User(usuario); err == nil {
+		registraAntes(r, antes.Publico())
+	}
+	if err := ws.auth.DeleteUser(usuario); err != nil {
+		serveAccountError(w, err)
+		return
+	}
+
+	sessao, _ := sessaoConectada(r)
+	log.Log(logService, "Usuário ", usuario, " removido por ", sessao.Usuario.Usuario)
+	serveDone(w)
+}
+
+// getRoles retorna as permissões de cada papel
+func (ws *WebSys) getRoles(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.auth.Roles())
+}
+
+// putRole altera as permissões do papel informado
+func (ws *WebSys) putRole(w http.ResponseWriter, r *http.Request) {
+	var role auth.Role
+	if err := decodifica(w, r, &role); err != nil {
+		return
+	}
+	role.Papel = auth.Papel(mux.Vars(r)["papel"])
+	registraAntes(r, ws.auth.Roles()[role.Papel])
+
+	if err := ws.auth.SetRole(role); err != nil {
+		serveAccountError(w, err)
+		return
+	}
+	serveResult(w, role)
+}
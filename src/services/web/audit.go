@@ -0,0 +1,205 @@
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/audit"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/services/web/context"
+)
+
+const (
+	atorAnonimo       = "anonimo"
+	tamanhoMaxAuditor = 1024 * 1024 // tamanho máximo do corpo registrado na auditoria
+	segredoOculto     = "***"
+)
+
+// camposSecretos são os campos do corpo das requisições que não são
+// gravados na auditoria
+var camposSecretos = []string{"senha", "senhaAtual", "novaSenha", "hash", "token"}
+
+// dadosAuditoria são os dados informados pelos handlers para a auditoria da requisição
+type dadosAuditoria struct {
+	ator  string
+	antes interface{}
+}
+
+// statusWriter registra o código de status enviado ao cliente
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// auditHandler é um middleware que registra na auditoria as requisições que
+// alteram dados (POST, PUT e DELETE), com o usuário, a origem, o estado
+// anterior informado pelo handler, o corpo enviado e o status da resposta.
+// Deve ser executado após o contextHandler
+func auditHandler(h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost && r.Method != http.MethodPut && r.Method != http.MethodDelete {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		corpo, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, tamanhoMaxAuditor))
+		if err != nil {
+			serveBadRequest(w, "não foi possível ler a requisição: %v", err)
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(corpo))
+
+		dados := &dadosAuditoria{}
+		context.Set(r, context.AuditKey, dados)
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+
+		ator := dados.ator
+		if sessao, ok := sessaoConectada(r); ok {
+			ator = sessao.Usuario.Usuario
+		}
+		if ator == "" {
+			ator = atorAnonimo
+		}
+
+		audit.Record(audit.Entry{
+			Ator:      ator,
+			Acao:      audit.AcaoHTTP,
+			Recurso:   r.Method + " " + r.URL.Path,
+			Origem:    origemRequisicao(r),
+			Resultado: strconv.Itoa(sw.status),
+			Antes:     audit.Valor(dados.antes),
+			Depois:    ocultaSecretos(corpo),
+		})
+	})
+}
+
+// registraAntes informa à auditoria o estado do recurso antes da alteração
+func registraAntes(r *http.Request, antes interface{}) {
+	if dados, ok := context.Get(r, context.AuditKey).(*dadosAuditoria); ok {
+		dados.antes = antes
+	}
+}
+
+// registraAtor informa à auditoria o usuário de requisições sem sessão, como o login
+func registraAtor(r *http.Request, ator string) {
+	if dados, ok := context.Get(r, context.AuditKey).(*dadosAuditoria); ok {
+		dados.ator = ator
+	}
+}
+
+// origemRequisicao retorna o endereço do cliente, incluindo o endereço
+// informado pelo proxy e a origem do navegador quando existirem
+func origemRequisicao(r *http.Request) string {
+	origem := r.RemoteAddr
+	if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+		origem += " (X-Forwarded-For: " + fwd + ")"
+	}
+	if o := r.Header.Get("Origin"); o != "" {
+		origem += " (Origin: " + o + ")"
+	}
+	return origem
+}
+
+// ocultaSecretos retorna o corpo JSON da requisição sem as senhas e tokens.
+// Corpos que não são objetos JSON são registrados como texto
+func ocultaSecretos(corpo []byte) json.RawMessage {
+	if len(bytes.TrimSpace(corpo)) == 0 {
+		return nil
+	}
+
+	var obj map[string]interface{}
+	if err := json.Unmarshal(corpo, &obj); err != nil {
+		return audit.Valor(string(corpo))
+	}
+	for _, campo := range camposSecretos {
+		if _, ok := obj[campo]; ok {
+			obj[campo] = segredoOculto
+		}
+	}
+	return audit.Valor(obj)
+}
+
+// auditAPIEndPoints registra as rotas de consulta ao registro de auditoria
+func (ws *WebSys) auditAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/audit", handleWith(ws.getAudit, ws.permite(auth.VerAuditoria), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/audit/verify", handleWith(ws.getAuditVerify, ws.permite(auth.VerAuditoria), ws.autenticado)).Methods("GET")
+}
+
+// getAudit retorna as entradas do registro de auditoria. Parâmetros: ator,
+// acao, recurso, inicio, fim, apos (sequência) e limite
+func (ws *WebSys) getAudit(w http.ResponseWriter, r *http.Request) {
+	l, err := audit.Default()
+	if err != nil {
+		serveInternalError(w, "%v", err)
+		return
+	}
+
+	q := r.URL.Query()
+	f := audit.Filtro{Ator: q.Get("ator"), Acao: q.Get("acao"), Recurso: q.Get("recurso"), Limite: 100}
+	if f.Inicio, err = parseTempo(q.Get("inicio"), false); err != nil {
+		serveBadRequest(w, "inicio inválido: %v", err)
+		return
+	}
+	if f.Fim, err = parseTempo(q.Get("fim"), true); err != nil {
+		serveBadRequest(w, "fim inválido: %v", err)
+		return
+	}
+	if apos := q.Get("apos"); apos != "" {
+		if f.Apos, err = strconv.ParseUint(apos, 10, 64); err != nil {
+			serveBadRequest(w, "apos inválido: %s", apos)
+			return
+		}
+	}
+	if limite := q.Get("limite"); limite != "" {
+		if f.Limite, err = strconv.Atoi(limite); err != nil || f.Limite <= 0 {
+			serveBadRequest(w, "limite inválido: %s", limite)
+			return
+		}
+	}
+
+	entradas, err := l.Query(f)
+	if err != nil {
+		log.Log(logService, "Erro ao consultar auditoria: ", err)
+		serveInternalError(w, "erro ao consultar auditoria: %v", err)
+		return
+	}
+	serveResult(w, entradas)
+}
+
+// getAuditVerify verifica a cadeia de hashes do registro de auditoria
+func (ws *WebSys) getAuditVerify(w http.ResponseWriter, r *http.Request) {
+	l, err := audit.Default()
+	if err != nil {
+		serveInternalError(w, "%v", err)
+		return
+	}
+
+	inicio := time.Now()
+	v, err := l.Verify()
+	if err != nil {
+		log.Log(logService, "Erro ao verificar auditoria: ", err)
+		serveInternalError(w, "erro ao verificar auditoria: %v", err)
+		return
+	}
+	log.Log(logService, "Auditoria verificada em ", time.Since(inicio), ": ", v.Entradas, " entradas, válida: ", v.Valido)
+	serveResult(w, v)
+}
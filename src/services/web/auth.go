@@ -0,0 +1,192 @@
+package web
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/services/web/context"
+)
+
+const (
+	cookieSessao = "sessao"
+	prefixoToken = "Bearer "
+)
+
+// credenciais representa o corpo da requisição de login
+type credenciais struct {
+	Usuario string `json:"usuario"`
+	Senha   string `json:"senha"`
+}
+
+// alteracaoSenha representa o corpo da requisição de troca de senha
+type alteracaoSenha struct {
+	SenhaAtual string `json:"senhaAtual"`
+	NovaSenha  string `json:"novaSenha"`
+}
+
+// authAPIEndPoints registra as rotas de login, logout e dados da sessão
+func (ws *WebSys) authAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/login", handleWith(ws.postLogin)).Methods("POST")
+	api.HandleFunc("/logout", handleWith(ws.postLogout, ws.autenticado)).Methods("POST")
+	api.HandleFunc("/me", handleWith(ws.getMe, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/me/password", handleWith(ws.putMyPassword, ws.autenticado)).Methods("PUT")
+}
+
+// autenticado é um middleware que exige uma sessão válida, enviada no header
+// Authorization ou no cookie de sessão, e associa a sessão ao contexto da
+// requisição em context.UserKey
+func (ws *WebSys) autenticado(h http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := tokenRequisicao(r)
+		if token == "" {
+			serveError(w, http.StatusUnauthorized, "autenticação necessária")
+			return
+		}
+
+		sessao, err := ws.auth.Validate(token)
+		if err == auth.ErrSessaoInvalida {
+			serveError(w, http.StatusUnauthorized, "%v", err)
+			return
+		} else if err != nil {
+			log.Log(logService, "Erro ao validar sessão: ", err)
+			serveInternalError(w, "erro ao validar sessão: %v", err)
+			return
+		}
+
+		context.Set(r, context.UserKey, sessao)
+		h.ServeHTTP(w, r)
+	})
+}
+
+// permite é um middleware que exige que o usuário da sessão possua a
+// permissão. Deve ser executado após o middleware autenticado
+func (ws *WebSys) permite(p auth.Permissao) func(http.HandlerFunc) http.HandlerFunc {
+	return func(h http.HandlerFunc) http.HandlerFunc {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			sessao, ok := sessaoConectada(r)
+			if !ok || !ws.auth.Allowed(sessao.Usuario, p) {
+				log.Log(logService, "Acesso negado ao usuário ", sessao.Usuario.Usuario, " em ", r.Method, " ", r.URL.Path,
+					" (permissão ", p, ")")
+				serveNoPermissionError(w)
+				return
+			}
+			h.ServeHTTP(w, r)
+		})
+	}
+}
+
+// tokenRequisicao retorna o token de sessão do header Authorization ou, para
+// navegadores (EventSource e WebSocket não enviam headers), do cookie de sessão
+func tokenRequisicao(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, prefixoToken) {
+		return strings.TrimSpace(strings.TrimPrefix(h, prefixoToken))
+	}
+	if c, err := r.Cookie(cookieSessao); err == nil {
+		return c.Value
+	}
+	return ""
+}
+
+// sessaoConectada retorna a sessão associada à requisição pelo middleware autenticado
+func sessaoConectada(r *http.Request) (auth.Session, bool) {
+	sessao, ok := context.Get(r, context.UserKey).(auth.Session)
+	return sessao, ok
+}
+
+// postLogin autentica o usuário, retornando o token de sessão e gravando-o
+// também em um cookie HttpOnly
+func (ws *WebSys) postLogin(w http.ResponseWriter, r *http.Request) {
+	var c credenciais
+	if err := decodifica(w, r, &c); err != nil {
+		return
+	}
+	registraAtor(r, c.Usuario)
+
+	sessao, err := ws.auth.Login(c.Usuario, c.Senha, enderecoCliente(r))
+	if err == auth.ErrCredenciais {
+		serveError(w, http.StatusUnauthorized, "%v", err)
+		return
+	} else if err == auth.ErrBloqueado {
+		serveError(w, http.StatusTooManyRequests, "%v", err)
+		return
+	} else if err != nil {
+		log.Log(logService, "Erro no login: ", err)
+		serveInternalError(w, "erro no login: %v", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     cookieSessao,
+		Value:    sessao.Token,
+		Path:     "/",
+		Expires:  sessao.Expira,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+	serveResult(w, sessao)
+}
+
+// enderecoCliente retorna o IP do cliente da requisição, sem a porta. O
+// X-Forwarded-For não é considerado, pois pode ser informado pelo próprio cliente
+func enderecoCliente(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// postLogout revoga a sessão atual e apaga o cookie de sessão
+func (ws *WebSys) postLogout(w http.ResponseWriter, r *http.Request) {
+	sessao, _ := sessaoConectada(r)
+	if err := ws.auth.Logout(sessao); err != nil {
+		log.Log(logService, "Erro no logout: ", err)
+		serveInternalError(w, "erro no logout: %v", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{Name: cookieSessao, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	serveDone(w)
+}
+
+// getMe retorna o usuário da sessão atual
+func (ws *WebSys) getMe(w http.ResponseWriter, r *http.Request) {
+	sessao, _ := sessaoConectada(r)
+	serveResult(w, sessao.Usuario)
+}
+
+// putMyPassword altera a senha do usuário da sessão atual. As sessões do
+// usuário, inclusive a atual, são encerradas e um novo login é necessário
+func (ws *WebSys) putMyPassword(w http.ResponseWriter, r *http.Request) {
+	var a alteracaoSenha
+	if err := decodifica(w, r, &a); err != nil {
+		return
+	}
+
+	sessao, _ := sessaoConectada(r)
+	u, err := ws.auth.User(sessao.Usuario.Usuario)
+	if err != nil {
+		serveInternalError(w, "erro ao consultar usuário: %v", err)
+		return
+	}
+	if !auth.VerificaSenha(a.SenhaAtual, u.Hash) {
+		serveError(w, http.StatusForbidden, "senha atual incorreta")
+		return
+	}
+
+	if err := ws.auth.SaveUser(u, a.NovaSenha); err == auth.ErrSenhaCurta {
+		serveBadRequest(w, "%v", err)
+		return
+	} else if err != nil {
+		serveInternalError(w, "erro ao alterar senha: %v", err)
+		return
+	}
+	log.Log(logService, "Senha alterada pelo usuário ", u.Usuario)
+	http.SetCookie(w, &http.Cookie{Name: cookieSessao, Value: "", Path: "/", MaxAge: -1, HttpOnly: true})
+	serveDone(w)
+}
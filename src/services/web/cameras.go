@@ -0,0 +1,146 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/config"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/services/ingest"
+)
+
+const (
+	fpsAoVivoPadrao = 5
+	boundaryAoVivo  = "quadro"
+)
+
+// camerasAPIEndPoints registra as rotas da saúde e da visualização ao vivo
+// das câmeras. As imagens não são compactadas, por isso utilizam handleWith2
+func (ws *WebSys) camerasAPIEndPoints(api *mux.Router) {
+	ver := ws.permite(auth.VerEventos)
+
+	api.HandleFunc("/cameras", handleWith(ws.getCameras, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/cameras/{id}", handleWith(ws.getCamera, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/cameras/{id}/snapshot", handleWith2(ws.getCameraSnapshot, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/cameras/{id}/mjpeg", handleWith2(ws.getCameraMJPEG, ver, ws.autenticado)).Methods("GET")
+}
+
+// consomeAlarmes publica no stream os alarmes das câmeras sem frames
+func (s *stream) consomeAlarmes(alarmes <-chan ingest.Alarme) {
+	for a := range alarmes {
+		s.publica(MensagemCamera, a)
+	}
+}
+
+// getCameras retorna a saúde das câmeras das portarias
+func (ws *WebSys) getCameras(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.cameras.Cameras())
+}
+
+// getCamera retorna a saúde da câmera
+func (ws *WebSys) getCamera(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	c, err := ws.cameras.Camera(id)
+	if err != nil {
+		serveNotFound(w, "%v: %s", err, id)
+		return
+	}
+	serveResult(w, c)
+}
+
+// getCameraSnapshot envia o frame mais recente da câmera
+func (ws *WebSys) getCameraSnapshot(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	img, err := ws.cameras.Snapshot(id)
+	if err != nil {
+		serveNotFound(w, "%v: %s", err, id)
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Last-Modified", img.Time.UTC().Format(http.TimeFormat))
+	w.Write(img.Image)
+}
+
+// getCameraMJPEG envia os frames da câmera ao vivo em multipart MJPEG,
+// exibido diretamente pelo navegador em um <img>. Os frames vêm da conexão
+// já existente com a câmera e são limitados a Web.FPSAoVivo por operador,
+// ou ao parâmetro fps quando menor
+func (ws *WebSys) getCameraMJPEG(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		serveInternalError(w, "streaming não suportado")
+		return
+	}
+
+	fps := config.Config.Web.FPSAoVivo
+	if fps <= 0 {
+		fps = fpsAoVivoPadrao
+	}
+	if param := r.URL.Query().Get("fps"); param != "" {
+		solicitado, err := strconv.Atoi(param)
+		if err != nil || solicitado <= 0 {
+			serveBadRequest(w, "fps inválido: %s", param)
+			return
+		}
+		if solicitado < fps {
+			fps = solicitado
+		}
+	}
+	// Tolera a variação no intervalo entre os frames da câmera, que faria o
+	// limite descartar frames além do necessário
+	intervalo := time.Second / time.Duration(fps) * 9 / 10
+
+	id := mux.Vars(r)["id"]
+	frames, cancela, err := ws.cameras.Subscribe(id)
+	if err != nil {
+		serveNotFound(w, "%v: %s", err, id)
+		return
+	}
+	defer cancela()
+
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundaryAoVivo)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	log.Log(logService, "Operador ", r.RemoteAddr, " assistindo à câmera ", id, " (", fps, " fps)")
+	defer log.Log(logService, "Operador ", r.RemoteAddr, " deixou de assistir à câmera ", id)
+
+	var ultimo time.Time
+	for {
+		select {
+		case img, ok := <-frames:
+			if !ok {
+				return
+			}
+			if time.Since(ultimo) < intervalo {
+				continue
+			}
+			ultimo = time.Now()
+			if err := enviaQuadro(w, img.Image); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// enviaQuadro escreve o JPEG como uma parte do multipart MJPEG
+func enviaQuadro(w http.ResponseWriter, img []byte) error {
+	if _, err := fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n",
+		boundaryAoVivo, len(img)); err != nil {
+		return err
+	}
+	if _, err := w.Write(img); err != nil {
+		return err
+	}
+	_, err := fmt.Fprint(w, "\r\n")
+	return err
+}
@@ -17,6 +17,7 @@ var (
 
 	DbNameKey key = 0 // chave para acessar o nome do banco de dados
 	UserKey   key = 1 // chave para acessar o usuário conectado
+	AuditKey  key = 2 // chave para acessar os dados de auditoria da requisição
 )
 
 // New cria um contexto associado à uma requisição. Se um contexto
@@ -56,3 +57,27 @@ func Clear(r *http.Request) {
 	defer ctxsMu.Unlock()
 	delete(ctxs, r)
 }
+
+// Set associa o valor à chave no contexto da requisição
+func Set(r *http.Request, k key, v interface{}) {
+	ctxsMu.Lock()
+	defer ctxsMu.Unlock()
+	c := ctxs[r]
+	if c == nil {
+		c = NewEmpty()
+	}
+	ctxs[r] = context.WithValue(c, k, v)
+}
+
+// Get retorna o valor associado à chave no contexto da requisição, ou nil
+// caso não exista
+func Get(r *http.Request, k key) interface{} {
+	ctxsMu.Lock()
+	c := ctxs[r]
+	ctxsMu.Unlock()
+
+	if c == nil {
+		return nil
+	}
+	return c.Value(k)
+}
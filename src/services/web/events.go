@@ -0,0 +1,247 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+const (
+	formatoData      = "2006-01-02"
+	formatoCSV       = "csv"
+	formatoNDJSON    = "ndjson"
+	formatoTempoCSV  = "02/01/2006 15:04:05"
+	prefixoExportada = "historico-"
+)
+
+// eventsAPIEndPoints registra as rotas de consulta ao histórico de eventos
+func (ws *WebSys) eventsAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/events", handleWith(ws.getEvents, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+	// O arquivo é enviado com Content-Length, por isso não pode ser compactado
+	api.HandleFunc("/events/export", handleWith2(ws.getEventsExport, ws.permite(auth.Exportar), ws.autenticado)).Methods("GET")
+}
+
+// filtroEventos monta o filtro da consulta a partir dos parâmetros da URL:
+// placa, prefixo, portaria, inicio, fim, tipo, status, cursor e limite
+func filtroEventos(r *http.Request) (storage.Filtro, error) {
+	q := r.URL.Query()
+	f := storage.Filtro{
+		Placa:        q.Get("placa"),
+		PrefixoPlaca: q.Get("prefixo"),
+		Portaria:     q.Get("portaria"),
+		Tipo:         defaults.TipoEvento(q.Get("tipo")),
+		Status:       q.Get("status"),
+		Cursor:       q.Get("cursor"),
+		Revisar:      q.Get("revisar") == "true",
+	}
+
+	switch f.Tipo {
+	case "", defaults.Entrada, defaults.Saida:
+	default:
+		return f, fmt.Errorf("tipo inválido, utilize %s ou %s", defaults.Entrada, defaults.Saida)
+	}
+	switch f.Status {
+	case "", storage.StatusAberta, storage.StatusFechada:
+	default:
+		return f, fmt.Errorf("status inválido, utilize %s ou %s", storage.StatusAberta, storage.StatusFechada)
+	}
+
+	var err error
+	if f.Inicio, err = parseTempo(q.Get("inicio"), false); err != nil {
+		return f, fmt.Errorf("inicio inválido: %v", err)
+	}
+	if f.Fim, err = parseTempo(q.Get("fim"), true); err != nil {
+		return f, fmt.Errorf("fim inválido: %v", err)
+	}
+	if limite := q.Get("limite"); limite != "" {
+		if f.Limite, err = strconv.Atoi(limite); err != nil || f.Limite <= 0 {
+			return f, fmt.Errorf("limite inválido: %s", limite)
+		}
+	}
+	return f, nil
+}
+
+// parseTempo interpreta datas no formato RFC3339 ou AAAA-MM-DD. Datas sem
+// horário utilizam o fim do dia quando fimDoDia for verdadeiro
+func parseTempo(valor string, fimDoDia bool) (time.Time, error) {
+	if valor == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, valor); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation(formatoData, valor, time.Local)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if fimDoDia {
+		t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+	return t, nil
+}
+
+// serveQueryError envia ao cliente o erro retornado pela consulta ao histórico
+func serveQueryError(w http.ResponseWriter, err error) {
+	switch err {
+	case storage.ErrCursorInvalido, storage.ErrPeriodoInvalido:
+		serveBadRequest(w, "%v", err)
+	default:
+		log.Log(logService, "Erro ao consultar histórico de eventos: ", err)
+		serveInternalError(w, "erro ao consultar histórico: %v", err)
+	}
+}
+
+// getEvents retorna uma página do histórico de eventos. O campo proximo da
+// resposta deve ser enviado no parâmetro cursor para obter a página seguinte
+func (ws *WebSys) getEvents(w http.ResponseWriter, r *http.Request) {
+	f, err := filtroEventos(r)
+	if err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+
+	pagina, err := storage.Query(ws.store, f)
+	if err != nil {
+		serveQueryError(w, err)
+		return
+	}
+	serveResult(w, pagina)
+}
+
+// getEventsExport envia todo o histórico filtrado em um arquivo CSV ou JSON
+// delimitado por linhas, de acordo com o parâmetro formato
+func (ws *WebSys) getEventsExport(w http.ResponseWriter, r *http.Request) {
+	f, err := filtroEventos(r)
+	if err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	formato := r.URL.Query().Get("formato")
+	if formato == "" {
+		formato = formatoCSV
+	}
+	if formato != formatoCSV && formato != formatoNDJSON {
+		serveBadRequest(w, "formato inválido, utilize %s ou %s", formatoCSV, formatoNDJSON)
+		return
+	}
+
+	file, err := ioutil.TempFile("", prefixoExportada+"*."+formato)
+	if err != nil {
+		serveInternalError(w, "não foi possível criar o arquivo: %v", err)
+		return
+	}
+	defer os.Remove(file.Name())
+
+	err = ws.exporta(file, formato, f)
+	if cerr := file.Close(); err == nil {
+		err = cerr
+	}
+	if err == storage.ErrPeriodoInvalido {
+		serveQueryError(w, err)
+		return
+	} else if err != nil {
+		log.Log(logService, "Erro ao exportar histórico de eventos: ", err)
+		serveInternalError(w, "não foi possível exportar o histórico: %v", err)
+		return
+	}
+
+	serveSendFile(w, r, file.Name())
+}
+
+// exporta escreve no arquivo todo o histórico filtrado, consultando-o página
+// a página para que o histórico não seja mantido inteiro em memória
+func (ws *WebSys) exporta(out io.Writer, formato string, f storage.Filtro) error {
+	if formato == formatoCSV {
+		if err := escreveCabecalhoCSV(out); err != nil {
+			return err
+		}
+	}
+
+	// O período é fixado para que todas as páginas consultem o mesmo período
+	if f.Fim.IsZero() {
+		f.Fim = time.Now()
+	}
+	f.Cursor = ""
+	f.Limite = storage.LimiteMaximo
+	for {
+		pagina, err := storage.Query(ws.store, f)
+		if err != nil {
+			return err
+		}
+		if formato == formatoCSV {
+			err = escreveCSV(out, pagina.Registros)
+		} else {
+			err = escreveNDJSON(out, pagina.Registros)
+		}
+		if err != nil || pagina.Proximo == "" {
+			return err
+		}
+		f.Cursor = pagina.Proximo
+	}
+}
+
+// escreveCabecalhoCSV escreve a linha de cabeçalho do CSV
+func escreveCabecalhoCSV(out io.Writer) error {
+	cw := csv.NewWriter(out)
+	if err := cw.Write([]string{"id", "placa", "entrada", "portaria", "saida", "portariaSaida", "status",
+		"decisaoEntrada", "motivoEntrada", "decisaoSaida", "motivoSaida"}); err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escreveCSV escreve os registros em CSV, uma visita por linha
+func escreveCSV(out io.Writer, registros []storage.RegistroVeicular) error {
+	cw := csv.NewWriter(out)
+	for _, r := range registros {
+		linha := []string{r.ID, r.Placa, formataTempo(r.Tempo), r.Portaria, formataTempo(r.TempoSaida),
+			r.PortariaSaida, r.Status}
+		linha = append(linha, colunasDecisao(r.DecisaoEntrada)...)
+		linha = append(linha, colunasDecisao(r.DecisaoSaida)...)
+		if err := cw.Write(linha); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// escreveNDJSON escreve os registros em JSON, um objeto por linha
+func escreveNDJSON(out io.Writer, registros []storage.RegistroVeicular) error {
+	enc := json.NewEncoder(out)
+	for _, r := range registros {
+		if err := enc.Encode(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// formataTempo formata o tempo para o CSV, retornando vazio para tempos zerados
+func formataTempo(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Local().Format(formatoTempoCSV)
+}
+
+// colunasDecisao retorna o resultado e o motivo da decisão para o CSV
+func colunasDecisao(d *defaults.DecisaoAcesso) []string {
+	if d == nil {
+		return []string{"", ""}
+	}
+	return []string{string(d.Resultado), d.Motivo}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/gate"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+// aberturaManual representa o corpo da requisição de abertura manual da cancela
+type aberturaManual struct {
+	Motivo string `json:"motivo"`
+}
+
+// estadoCancela representa o estado da cancela enviado ao cliente
+type estadoCancela struct {
+	Portaria string      `json:"portaria"`
+	Estado   gate.Estado `json:"estado,omitempty"`
+	Erro     string      `json:"erro,omitempty"`
+}
+
+// gatesAPIEndPoints registra as rotas de controle manual das cancelas
+func (ws *WebSys) gatesAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/gates/{portaria}", handleWith(ws.getGate, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/gates/{portaria}/open", handleWith(ws.postGateOpen, ws.permite(auth.AbrirCancela), ws.autenticado)).Methods("POST")
+}
+
+// getGate retorna o estado da cancela da portaria
+func (ws *WebSys) getGate(w http.ResponseWriter, r *http.Request) {
+	portaria := mux.Vars(r)["portaria"]
+	c, err := ws.gates.Gate(portaria)
+	if err != nil {
+		serveNotFound(w, "%v: %s", err, portaria)
+		return
+	}
+
+	resp := estadoCancela{Portaria: portaria}
+	if resp.Estado, err = c.State(); err != nil {
+		resp.Erro = err.Error()
+	}
+	serveResult(w, resp)
+}
+
+// postGateOpen abre a cancela da portaria por comando do operador. O motivo é obrigatório
+func (ws *WebSys) postGateOpen(w http.ResponseWriter, r *http.Request) {
+	var a aberturaManual
+	if err := decodifica(w, r, &a); err != nil {
+		return
+	}
+	if strings.TrimSpace(a.Motivo) == "" {
+		serveBadRequest(w, "o motivo da abertura manual é obrigatório")
+		return
+	}
+
+	portaria := mux.Vars(r)["portaria"]
+	c, err := ws.gates.Gate(portaria)
+	if err != nil {
+		serveNotFound(w, "%v: %s", err, portaria)
+		return
+	}
+
+	sessao, _ := sessaoConectada(r)
+	if err := c.OpenManual(sessao.Usuario.Usuario + ": " + a.Motivo); err != nil {
+		log.Log(logService, "Erro na abertura manual da cancela ", portaria, ": ", err)
+		serveInternalError(w, "não foi possível abrir a cancela: %v", err)
+		return
+	}
+	serveDone(w)
+}
@@ -3,6 +3,8 @@ package web
 import (
 	"compress/flate"
 	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -12,6 +14,8 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/gustavolimam/control-access/src/components/audit"
+	"github.com/gustavolimam/control-access/src/components/config"
 	"github.com/gustavolimam/control-access/src/components/log"
 	"github.com/gustavolimam/control-access/src/services/web/context"
 )
@@ -40,20 +44,22 @@ type zipWriter struct {
 // portanto na ordem reversa à fornecida
 func handleWith(h http.HandlerFunc, middleware ...func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
 	h = compressHandler(h)
-	h = defaultHeadersHandler(h)
 	for _, m := range middleware {
 		h = m(h)
 	}
+	h = defaultHeadersHandler(h)
+	h = auditHandler(h)
 	h = contextHandler(h)
 	return h
 }
 
 // Similar ao handleWith, porém sem compressHandler para correto funcionamento do stream de vídeo
 func handleWith2(h http.HandlerFunc, middleware ...func(http.HandlerFunc) http.HandlerFunc) http.HandlerFunc {
-	h = defaultHeadersHandler(h)
 	for _, m := range middleware {
 		h = m(h)
 	}
+	h = defaultHeadersHandler(h)
+	h = auditHandler(h)
 	h = contextHandler(h)
 	return h
 }
@@ -123,18 +129,32 @@ func contextHandler(h http.HandlerFunc) http.HandlerFunc {
 // à resposta HTTP
 func defaultHeadersHandler(h http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token")
-		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		if origin := r.Header.Get("Origin"); origin != "" {
+		// Apenas as origens configuradas podem acessar a API com credenciais
+		// a partir de outro domínio
+		if origin := r.Header.Get("Origin"); origem(origin) {
+			w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
+			w.Header().Set("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, Authorization, X-CSRF-Token")
+			w.Header().Set("Access-Control-Allow-Credentials", "true")
 			w.Header().Set("Access-Control-Allow-Origin", origin)
-		} else {
-			w.Header().Set("Access-Control-Allow-Origin", "*")
+			w.Header().Add("Vary", "Origin")
 		}
 		h.ServeHTTP(w, r)
 	})
 }
 
+// origem verifica se a origem está entre as origens autorizadas na configuração
+func origem(origin string) bool {
+	if origin == "" {
+		return false
+	}
+	for _, o := range config.Config.Web.Origens {
+		if strings.EqualFold(strings.TrimRight(o, "/"), origin) {
+			return true
+		}
+	}
+	return false
+}
+
 // serveDone envia um objeto DONE para o cliente
 func serveDone(w http.ResponseWriter) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
@@ -250,8 +270,8 @@ func serveResult(w http.ResponseWriter, v interface{}) {
 	}
 }
 
-// serveSendFile envia arquivo para o cliente
-func serveSendFile(w http.ResponseWriter, path string) {
+// serveSendFile envia arquivo para o cliente e registra o envio na auditoria
+func serveSendFile(w http.ResponseWriter, r *http.Request, path string) {
 	openfile, err := os.Open(path)
 	if err != nil {
 		log.Log(logService, "Não foi possível abrir o arquivo "+path+": ", err.Error())
@@ -290,9 +310,32 @@ func serveSendFile(w http.ResponseWriter, path string) {
 		log.Log(logService, "serveSendFile - Erro ao ler arquivo via openfile.Seek: ", err.Error())
 	}
 	//'Copy' the file to the client
-	if _, err := io.Copy(w, openfile); err != nil {
+	hash := sha256.New()
+	enviados, err := io.Copy(io.MultiWriter(w, hash), openfile)
+	if err != nil {
 		log.Log(logService, "serveSendFile - Erro ao copiar arquivo via io.Copy: ", err.Error())
 	}
+
+	ator := atorAnonimo
+	if sessao, ok := sessaoConectada(r); ok {
+		ator = sessao.Usuario.Usuario
+	}
+	resultado := "ok"
+	if err != nil {
+		resultado = err.Error()
+	}
+	audit.Record(audit.Entry{
+		Ator:      ator,
+		Acao:      audit.AcaoArquivo,
+		Recurso:   r.URL.String(),
+		Origem:    origemRequisicao(r),
+		Resultado: resultado,
+		Depois: audit.Valor(map[string]interface{}{
+			"arquivo": filepath.Base(path),
+			"bytes":   enviados,
+			"sha256":  hex.EncodeToString(hash.Sum(nil)),
+		}),
+	})
 }
 
 // decodifica interpreta os dados recebidos do frontend e retorna a estrutura correspondente
@@ -0,0 +1,130 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/passes"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+// passesAPIEndPoints registra as rotas dos passes de visitantes e fornecedores
+func (ws *WebSys) passesAPIEndPoints(api *mux.Router) {
+	ver := ws.permite(auth.VerCadastro)
+	editar := ws.permite(auth.EditarCadastro)
+
+	api.HandleFunc("/passes", handleWith(ws.getPasses, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/passes", handleWith(ws.postPass, editar, ws.autenticado)).Methods("POST")
+	api.HandleFunc("/passes/{id}", handleWith(ws.getPass, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/passes/{id}", handleWith(ws.putPass, editar, ws.autenticado)).Methods("PUT")
+	api.HandleFunc("/passes/{id}", handleWith(ws.deletePass, editar, ws.autenticado)).Methods("DELETE")
+}
+
+// servePassError envia ao cliente o erro retornado pelos passes
+func servePassError(w http.ResponseWriter, err error) {
+	switch err {
+	case storage.ErrNotFound:
+		serveNotFound(w, "%v", err)
+	case passes.ErrEncerrado:
+		serveCustomError(w, http.StatusConflict, "conflict", "%v", err)
+	default:
+		log.Log(logService, "Erro nos passes: ", err)
+		serveInternalError(w, "erro nos passes: %v", err)
+	}
+}
+
+// getPasses retorna os passes cadastrados, opcionalmente filtrados pela placa
+// (?placa=) e pela situação (?status=)
+func (ws *WebSys) getPasses(w http.ResponseWriter, r *http.Request) {
+	todos, err := ws.passes.Passes(r.URL.Query().Get("placa"))
+	if err != nil {
+		servePassError(w, err)
+		return
+	}
+
+	status := passes.Status(r.URL.Query().Get("status"))
+	if status == "" {
+		serveResult(w, todos)
+		return
+	}
+	filtrados := []passes.Pass{}
+	for _, p := range todos {
+		if p.Status == status {
+			filtrados = append(filtrados, p)
+		}
+	}
+	serveResult(w, filtrados)
+}
+
+// getPass retorna o passe informado
+func (ws *WebSys) getPass(w http.ResponseWriter, r *http.Request) {
+	p, err := ws.passes.Pass(mux.Vars(r)["id"])
+	if err != nil {
+		servePassError(w, err)
+		return
+	}
+	serveResult(w, p)
+}
+
+// postPass cadastra um novo passe em nome do usuário da sessão
+func (ws *WebSys) postPass(w http.ResponseWriter, r *http.Request) {
+	var p passes.Pass
+	if err := decodifica(w, r, &p); err != nil {
+		return
+	}
+	sessao, _ := sessaoConectada(r)
+	p.ID = ""
+	p.CriadoPor = sessao.Usuario.Usuario
+	ws.salvaPasse(w, &p)
+}
+
+// putPass altera o período, as portarias e os dados do passe ativo
+func (ws *WebSys) putPass(w http.ResponseWriter, r *http.Request) {
+	var p passes.Pass
+	if err := decodifica(w, r, &p); err != nil {
+		return
+	}
+
+	p.ID = mux.Vars(r)["id"]
+	antes, err := ws.passes.Pass(p.ID)
+	if err != nil {
+		servePassError(w, err)
+		return
+	}
+	registraAntes(r, antes)
+	ws.salvaPasse(w, &p)
+}
+
+// salvaPasse valida e grava o passe, retornando o passe atualizado
+func (ws *WebSys) salvaPasse(w http.ResponseWriter, p *passes.Pass) {
+	if err := p.Validate(); err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	if err := ws.passes.Save(p); err != nil {
+		servePassError(w, err)
+		return
+	}
+
+	passe, err := ws.passes.Pass(p.ID)
+	if err != nil {
+		servePassError(w, err)
+		return
+	}
+	serveResult(w, passe)
+}
+
+// deletePass cancela o passe informado, que é mantido para consulta
+func (ws *WebSys) deletePass(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if antes, err := ws.passes.Pass(id); err == nil {
+		registraAntes(r, antes)
+	}
+	if err := ws.passes.Cancel(id); err != nil {
+		servePassError(w, err)
+		return
+	}
+	serveDone(w)
+}
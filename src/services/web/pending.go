@@ -0,0 +1,119 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/override"
+)
+
+// resolucao representa o corpo da requisição de aprovação ou negação do acesso
+type resolucao struct {
+	Motivo string `json:"motivo"`
+}
+
+// pendingAPIEndPoints registra as rotas das decisões que aguardam o operador
+func (ws *WebSys) pendingAPIEndPoints(api *mux.Router) {
+	ver := ws.permite(auth.VerEventos)
+	decidir := ws.permite(auth.AbrirCancela)
+
+	api.HandleFunc("/pending", handleWith(ws.getPending, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/pending/{id}", handleWith(ws.getPendingByID, ver, ws.autenticado)).Methods("GET")
+	// As imagens já estão em JPEG, por isso não são compactadas
+	api.HandleFunc("/pending/{id}/zoom", handleWith2(ws.getPendingZoom, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/pending/{id}/pan", handleWith2(ws.getPendingPan, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/pending/{id}/approve", handleWith(ws.postPendingApprove, decidir, ws.autenticado)).Methods("POST")
+	api.HandleFunc("/pending/{id}/deny", handleWith(ws.postPendingDeny, decidir, ws.autenticado)).Methods("POST")
+}
+
+// consomePendencias publica no stream as pendências criadas e resolvidas
+func (s *stream) consomePendencias(notificacoes <-chan override.Notificacao) {
+	for n := range notificacoes {
+		s.publica(n.Tipo, n.Pendente)
+	}
+}
+
+// servePendingError envia ao cliente o erro retornado pelas pendências
+func servePendingError(w http.ResponseWriter, err error) {
+	switch err {
+	case override.ErrNaoEncontrada:
+		serveNotFound(w, "%v", err)
+	case override.ErrMotivoVazio:
+		serveBadRequest(w, "%v", err)
+	default:
+		serveInternalError(w, "%v", err)
+	}
+}
+
+// getPending retorna os eventos que aguardam a decisão do operador
+func (ws *WebSys) getPending(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.pending.Pending())
+}
+
+// getPendingByID retorna o evento pendente informado
+func (ws *WebSys) getPendingByID(w http.ResponseWriter, r *http.Request) {
+	p, err := ws.pending.Get(mux.Vars(r)["id"])
+	if err != nil {
+		servePendingError(w, err)
+		return
+	}
+	serveResult(w, p)
+}
+
+// getPendingZoom envia a imagem da câmera zoom do evento pendente
+func (ws *WebSys) getPendingZoom(w http.ResponseWriter, r *http.Request) {
+	zoom, _, err := ws.pending.Images(mux.Vars(r)["id"])
+	servePendingImage(w, zoom, err)
+}
+
+// getPendingPan envia a imagem da câmera panorâmica do evento pendente
+func (ws *WebSys) getPendingPan(w http.ResponseWriter, r *http.Request) {
+	_, pan, err := ws.pending.Images(mux.Vars(r)["id"])
+	servePendingImage(w, pan, err)
+}
+
+// servePendingImage envia a imagem JPEG do evento pendente
+func servePendingImage(w http.ResponseWriter, img []byte, err error) {
+	if err != nil {
+		servePendingError(w, err)
+		return
+	}
+	if len(img) == 0 {
+		serveNotFound(w, "imagem não disponível para o evento")
+		return
+	}
+	w.Header().Set("Content-Type", "image/jpeg")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Write(img)
+}
+
+// postPendingApprove permite o acesso do evento pendente e abre a cancela
+func (ws *WebSys) postPendingApprove(w http.ResponseWriter, r *http.Request) {
+	ws.resolvePending(w, r, true)
+}
+
+// postPendingDeny nega o acesso do evento pendente
+func (ws *WebSys) postPendingDeny(w http.ResponseWriter, r *http.Request) {
+	ws.resolvePending(w, r, false)
+}
+
+// resolvePending registra a decisão do operador da sessão para o evento pendente
+func (ws *WebSys) resolvePending(w http.ResponseWriter, r *http.Request, permitido bool) {
+	var res resolucao
+	if err := decodifica(w, r, &res); err != nil {
+		return
+	}
+
+	id := mux.Vars(r)["id"]
+	if p, err := ws.pending.Get(id); err == nil {
+		registraAntes(r, p)
+	}
+
+	sessao, _ := sessaoConectada(r)
+	if err := ws.pending.Resolve(id, permitido, sessao.Usuario.Usuario, res.Motivo); err != nil {
+		servePendingError(w, err)
+		return
+	}
+	serveDone(w)
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/registry"
+	"github.com/gustavolimam/control-access/src/components/storage"
+)
+
+// registryAPIEndPoints registra as rotas do cadastro de pessoas e veículos
+func (ws *WebSys) registryAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/users", handleWith(ws.getUsers, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/users", handleWith(ws.postUser, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("POST")
+	api.HandleFunc("/users/{id}", handleWith(ws.getUser, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/users/{id}", handleWith(ws.putUser, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("PUT")
+	api.HandleFunc("/users/{id}", handleWith(ws.deleteUser, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("DELETE")
+
+	api.HandleFunc("/vehicles", handleWith(ws.getVehicles, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/vehicles", handleWith(ws.postVehicle, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("POST")
+	api.HandleFunc("/vehicles/{placa}", handleWith(ws.getVehicle, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/vehicles/{placa}", handleWith(ws.putVehicle, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("PUT")
+	api.HandleFunc("/vehicles/{placa}", handleWith(ws.deleteVehicle, ws.permite(auth.EditarCadastro), ws.autenticado)).Methods("DELETE")
+}
+
+// serveRegistryError envia ao cliente o erro retornado pelo cadastro
+func serveRegistryError(w http.ResponseWriter, err error) {
+	switch err {
+	case storage.ErrNotFound:
+		serveNotFound(w, "%v", err)
+	case registry.ErrPlacaDeOutraPessoa:
+		serveCustomError(w, http.StatusConflict, "conflict", "%v", err)
+	default:
+		log.Log(logService, "Erro no cadastro: ", err)
+		serveInternalError(w, "erro no cadastro: %v", err)
+	}
+}
+
+// getUsers retorna todas as pessoas cadastradas
+func (ws *WebSys) getUsers(w http.ResponseWriter, r *http.Request) {
+	pessoas, err := ws.registry.Persons()
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, pessoas)
+}
+
+// getUser retorna a pessoa cadastrada com o ID informado
+func (ws *WebSys) getUser(w http.ResponseWriter, r *http.Request) {
+	p, err := ws.registry.Person(mux.Vars(r)["id"])
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, p)
+}
+
+// postUser cadastra uma nova pessoa
+func (ws *WebSys) postUser(w http.ResponseWriter, r *http.Request) {
+	var p registry.Person
+	if err := decodifica(w, r, &p); err != nil {
+		return
+	}
+	p.ID = ""
+	ws.salvaPessoa(w, &p)
+}
+
+// putUser atualiza os dados de uma pessoa cadastrada
+func (ws *WebSys) putUser(w http.ResponseWriter, r *http.Request) {
+	var p registry.Person
+	if err := decodifica(w, r, &p); err != nil {
+		return
+	}
+
+	p.ID = mux.Vars(r)["id"]
+	antes, err := ws.registry.Person(p.ID)
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	registraAntes(r, antes)
+	ws.salvaPessoa(w, &p)
+}
+
+// salvaPessoa valida e grava a pessoa, retornando o cadastro atualizado
+func (ws *WebSys) salvaPessoa(w http.ResponseWriter, p *registry.Person) {
+	if err := p.Validate(); err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	if err := ws.registry.SavePerson(p); err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+
+	pessoa, err := ws.registry.Person(p.ID)
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, pessoa)
+}
+
+// deleteUser remove a pessoa e os seus veículos
+func (ws *WebSys) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["id"]
+	if antes, err := ws.registry.Person(id); err == nil {
+		registraAntes(r, antes)
+	}
+	if err := ws.registry.DeletePerson(id); err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveDone(w)
+}
+
+// getVehicles retorna todos os veículos cadastrados
+func (ws *WebSys) getVehicles(w http.ResponseWriter, r *http.Request) {
+	veiculos, err := ws.registry.Vehicles()
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, veiculos)
+}
+
+// getVehicle retorna o veículo cadastrado com a placa informada
+func (ws *WebSys) getVehicle(w http.ResponseWriter, r *http.Request) {
+	v, err := ws.registry.Vehicle(mux.Vars(r)["placa"])
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, v)
+}
+
+// postVehicle cadastra um novo veículo
+func (ws *WebSys) postVehicle(w http.ResponseWriter, r *http.Request) {
+	var v registry.Vehicle
+	if err := decodifica(w, r, &v); err != nil {
+		return
+	}
+	ws.salvaVeiculo(w, &v)
+}
+
+// putVehicle atualiza os dados do veículo com a placa informada
+func (ws *WebSys) putVehicle(w http.ResponseWriter, r *http.Request) {
+	var v registry.Vehicle
+	if err := decodifica(w, r, &v); err != nil {
+		return
+	}
+
+	v.Placa = mux.Vars(r)["placa"]
+	antes, err := ws.registry.Vehicle(v.Placa)
+	if err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	registraAntes(r, antes)
+	ws.salvaVeiculo(w, &v)
+}
+
+// salvaVeiculo valida e grava o veículo, retornando o cadastro atualizado
+func (ws *WebSys) salvaVeiculo(w http.ResponseWriter, v *registry.Vehicle) {
+	if err := v.Validate(); err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	if err := ws.registry.SaveVehicle(v); err != nil {
+		if err == storage.ErrNotFound {
+			serveBadRequest(w, "pessoa %s não cadastrada", v.PessoaID)
+			return
+		}
+		serveRegistryError(w, err)
+		return
+	}
+	serveResult(w, v)
+}
+
+// deleteVehicle remove o veículo com a placa informada
+func (ws *WebSys) deleteVehicle(w http.ResponseWriter, r *http.Request) {
+	placa := mux.Vars(r)["placa"]
+	if antes, err := ws.registry.Vehicle(placa); err == nil {
+		registraAntes(r, antes)
+	}
+	if err := ws.registry.DeleteVehicle(placa); err != nil {
+		serveRegistryError(w, err)
+		return
+	}
+	serveDone(w)
+}
@@ -0,0 +1,224 @@
+package web
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/log"
+)
+
+const (
+	bufferCliente      = 100              // mensagens pendentes por cliente antes de descartar
+	intervaloHeartbeat = 15 * time.Second // intervalo de keep-alive das conexões
+	timeoutEscritaWS   = 5 * time.Second
+
+	// Tipos de mensagens enviadas aos operadores
+	MensagemEvento = "evento"
+	MensagemAlerta = "alerta"
+	MensagemCamera = "camera"
+)
+
+// mensagemStream representa uma mensagem enviada aos operadores conectados
+type mensagemStream struct {
+	Tipo  string      `json:"tipo"`
+	Dados interface{} `json:"dados"`
+}
+
+// clienteStream representa um operador conectado ao stream. Cada cliente
+// possui o seu próprio buffer, de forma que um navegador lento não bloqueia
+// os demais nem o serviço de eventos
+type clienteStream struct {
+	ch        chan []byte
+	descartes int
+	endereco  string
+}
+
+// stream distribui as mensagens para todos os operadores conectados
+type stream struct {
+	mutex    sync.Mutex
+	clientes map[*clienteStream]struct{}
+}
+
+func newStream() *stream {
+	return &stream{clientes: map[*clienteStream]struct{}{}}
+}
+
+// assina registra um novo cliente no stream
+func (s *stream) assina(endereco string) *clienteStream {
+	c := &clienteStream{ch: make(chan []byte, bufferCliente), endereco: endereco}
+
+	s.mutex.Lock()
+	s.clientes[c] = struct{}{}
+	total := len(s.clientes)
+	s.mutex.Unlock()
+
+	log.Log(logService, "Operador conectado ao stream de eventos: ", endereco, " (", total, " conectados)")
+	return c
+}
+
+// cancela remove o cliente do stream
+func (s *stream) cancela(c *clienteStream) {
+	s.mutex.Lock()
+	delete(s.clientes, c)
+	total := len(s.clientes)
+	s.mutex.Unlock()
+
+	log.Log(logService, "Operador desconectado do stream de eventos: ", c.endereco, " (", total, " conectados)")
+}
+
+// publica envia a mensagem para todos os clientes sem bloquear. Clientes com
+// o buffer cheio perdem a mensagem
+func (s *stream) publica(tipo string, dados interface{}) {
+	data, err := json.Marshal(mensagemStream{Tipo: tipo, Dados: dados})
+	if err != nil {
+		log.Log(logService, "Erro ao codificar mensagem do stream: ", err)
+		return
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for c := range s.clientes {
+		select {
+		case c.ch <- data:
+		default:
+			c.descartes++
+			log.Log(logService, "Buffer do operador ", c.endereco, " cheio, mensagem descartada (",
+				c.descartes, " descartes)")
+		}
+	}
+}
+
+// consomeEventos publica no stream os eventos recebidos do serviço de eventos
+func (s *stream) consomeEventos(eventos <-chan defaults.EventoVeiculo) {
+	for ev := range eventos {
+		s.publica(MensagemEvento, ev)
+	}
+}
+
+// streamAPIEndPoints registra as rotas do stream de eventos. O stream não
+// pode ser compactado, por isso utiliza handleWith2
+func (ws *WebSys) streamAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/events/stream", handleWith2(ws.getEventStream, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+}
+
+// getEventStream envia os eventos em tempo real via WebSocket, quando o
+// cliente solicita upgrade, ou via Server-Sent Events
+func (ws *WebSys) getEventStream(w http.ResponseWriter, r *http.Request) {
+	if websocket.IsWebSocketUpgrade(r) {
+		ws.serveWebSocket(w, r)
+		return
+	}
+	ws.serveSSE(w, r)
+}
+
+// serveSSE envia os eventos no formato text/event-stream
+func (ws *WebSys) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		serveInternalError(w, "streaming não suportado")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	c := ws.stream.assina(r.RemoteAddr)
+	defer ws.stream.cancela(c)
+
+	heartbeat := time.NewTicker(intervaloHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case data := <-c.ch:
+			if _, err := fmt.Fprintf(w, "data: %s\n\n", data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+var upgrader = websocket.Upgrader{
+	CheckOrigin: origemWebSocket,
+}
+
+// origemWebSocket valida a origem do upgrade para WebSocket. O CORS não se
+// aplica ao upgrade, de forma que sem essa validação qualquer página aberta
+// no navegador do operador poderia assinar o stream. São aceitas conexões
+// sem Origin (clientes fora do navegador), do mesmo host da API ou das
+// origens autorizadas na configuração
+func origemWebSocket(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	u, err := url.Parse(origin)
+	if err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	if origem(origin) {
+		return true
+	}
+	log.Log(logService, "Upgrade para WebSocket recusado para a origem ", origin, " (", r.RemoteAddr, ")")
+	return false
+}
+
+// serveWebSocket envia os eventos como mensagens de texto JSON via WebSocket
+func (ws *WebSys) serveWebSocket(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Log(logService, "Erro no upgrade para WebSocket: ", err)
+		return
+	}
+	defer conn.Close()
+
+	c := ws.stream.assina(r.RemoteAddr)
+	defer ws.stream.cancela(c)
+
+	// Lê as mensagens do cliente apenas para detectar o fechamento da conexão
+	fechado := make(chan struct{})
+	go func() {
+		defer close(fechado)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	heartbeat := time.NewTicker(intervaloHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case data := <-c.ch:
+			conn.SetWriteDeadline(time.Now().Add(timeoutEscritaWS))
+			if err := conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeoutEscritaWS)); err != nil {
+				return
+			}
+		case <-fechado:
+			return
+		}
+	}
+}
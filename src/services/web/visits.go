@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+)
+
+// visitsAPIEndPoints registra as rotas de consulta de ocupação e visitas
+func (ws *WebSys) visitsAPIEndPoints(api *mux.Router) {
+	api.HandleFunc("/occupancy", handleWith(ws.getOccupancy, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/visits/open", handleWith(ws.getOpenVisits, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/visits/overstayed", handleWith(ws.getOverstayedVisits, ws.permite(auth.VerEventos), ws.autenticado)).Methods("GET")
+}
+
+// getOccupancy retorna a quantidade de veículos dentro do campus, por portaria e no total
+func (ws *WebSys) getOccupancy(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.tracker.Occupancy())
+}
+
+// getOpenVisits retorna os veículos que estão dentro do campus
+func (ws *WebSys) getOpenVisits(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.tracker.Open())
+}
+
+// getOverstayedVisits retorna os veículos que excederam o tempo de permanência
+func (ws *WebSys) getOverstayedVisits(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.tracker.Overstayed())
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/auth"
+	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/storage"
+	"github.com/gustavolimam/control-access/src/components/watchlist"
+)
+
+// watchlistAPIEndPoints registra as rotas da lista de observação e dos alertas
+func (ws *WebSys) watchlistAPIEndPoints(api *mux.Router) {
+	ver := ws.permite(auth.VerEventos)
+	configurar := ws.permite(auth.Configurar)
+
+	api.HandleFunc("/watchlist", handleWith(ws.getWatchlist, ws.permite(auth.VerCadastro), ws.autenticado)).Methods("GET")
+	api.HandleFunc("/watchlist/{placa}", handleWith(ws.putWatchlist, configurar, ws.autenticado)).Methods("PUT")
+	api.HandleFunc("/watchlist/{placa}", handleWith(ws.deleteWatchlist, configurar, ws.autenticado)).Methods("DELETE")
+
+	api.HandleFunc("/alerts", handleWith(ws.getAlerts, ver, ws.autenticado)).Methods("GET")
+	api.HandleFunc("/alerts/{id}/ack", handleWith(ws.postAlertAck, ver, ws.autenticado)).Methods("POST")
+}
+
+// consomeAlertas publica no stream os alertas da lista de observação
+func (s *stream) consomeAlertas(alertas <-chan watchlist.Alert) {
+	for a := range alertas {
+		s.publica(MensagemAlerta, a)
+	}
+}
+
+// serveWatchlistError envia ao cliente o erro retornado pela lista de observação
+func serveWatchlistError(w http.ResponseWriter, err error) {
+	switch err {
+	case storage.ErrNotFound:
+		serveNotFound(w, "%v", err)
+	default:
+		log.Log(logService, "Erro na lista de observação: ", err)
+		serveInternalError(w, "erro na lista de observação: %v", err)
+	}
+}
+
+// getWatchlist retorna as placas observadas
+func (ws *WebSys) getWatchlist(w http.ResponseWriter, r *http.Request) {
+	serveResult(w, ws.watch.Entries())
+}
+
+// putWatchlist inclui ou altera a placa observada
+func (ws *WebSys) putWatchlist(w http.ResponseWriter, r *http.Request) {
+	var e watchlist.Entry
+	if err := decodifica(w, r, &e); err != nil {
+		return
+	}
+	e.Placa = mux.Vars(r)["placa"]
+	if err := e.Validate(); err != nil {
+		serveBadRequest(w, "%v", err)
+		return
+	}
+	if antes, err := ws.watch.Entry(e.Placa); err == nil {
+		registraAntes(r, antes)
+	}
+
+	sessao, _ := sessaoConectada(r)
+	e.CriadoPor = sessao.Usuario.Usuario
+	if err := ws.watch.Save(&e); err != nil {
+		serveWatchlistError(w, err)
+		return
+	}
+	serveResult(w, e)
+}
+
+// deleteWatchlist remove a placa da lista de observação
+func (ws *WebSys) deleteWatchlist(w http.ResponseWriter, r *http.Request) {
+	placa := mux.Vars(r)["placa"]
+	if antes, err := ws.watch.Entry(placa); err == nil {
+		registraAntes(r, antes)
+	}
+	if err := ws.watch.Delete(placa); err != nil {
+		serveWatchlistError(w, err)
+		return
+	}
+	serveDone(w)
+}
+
+// getAlerts retorna os alertas gravados. ?pendentes=true retorna apenas os
+// alertas não reconhecidos e ?limite= restringe a quantidade
+func (ws *WebSys) getAlerts(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	pendentes := query.Get("pendentes") == "true"
+	limite := 0
+	if l := query.Get("limite"); l != "" {
+		var err error
+		if limite, err = strconv.Atoi(l); err != nil || limite < 0 {
+			serveBadRequest(w, "limite inválido: %s", l)
+			return
+		}
+	}
+
+	alertas, err := ws.watch.Alerts(pendentes, limite)
+	if err != nil {
+		serveWatchlistError(w, err)
+		return
+	}
+	serveResult(w, alertas)
+}
+
+// postAlertAck registra que o operador da sessão tomou ciência do alerta
+func (ws *WebSys) postAlertAck(w http.ResponseWriter, r *http.Request) {
+	sessao, _ := sessaoConectada(r)
+	a, err := ws.watch.Acknowledge(mux.Vars(r)["id"], sessao.Usuario.Usuario)
+	if err != nil {
+		serveWatchlistError(w, err)
+		return
+	}
+	serveResult(w, a)
+}
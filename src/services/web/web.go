@@ -5,8 +5,18 @@ import (
 	"path"
 
 	"github.com/gorilla/mux"
+	"github.com/gustavolimam/control-access/src/components/access"
+	"github.com/gustavolimam/control-access/src/components/auth"
 	"github.com/gustavolimam/control-access/src/components/defaults"
+	"github.com/gustavolimam/control-access/src/components/gate"
 	"github.com/gustavolimam/control-access/src/components/log"
+	"github.com/gustavolimam/control-access/src/components/override"
+	"github.com/gustavolimam/control-access/src/components/passes"
+	"github.com/gustavolimam/control-access/src/components/registry"
+	"github.com/gustavolimam/control-access/src/components/storage"
+	"github.com/gustavolimam/control-access/src/components/visits"
+	"github.com/gustavolimam/control-access/src/components/watchlist"
+	"github.com/gustavolimam/control-access/src/services/ingest"
 )
 
 const (
@@ -15,15 +25,42 @@ const (
 
 // WebSys estrutura responsável por criar as variavéis utilizadas pelo objeto
 type WebSys struct {
-	port string
+	port     string
+	auth     *auth.Service
+	store    storage.Store
+	tracker  *visits.Tracker
+	registry *registry.Registry
+	passes   *passes.Manager
+	watch    *watchlist.Watchlist
+	engine   *access.Engine
+	gates    *gate.Manager
+	pending  *override.Manager
+	cameras  *ingest.Ingest
+	eventos  <-chan defaults.EventoVeiculo
+	stream   *stream
 }
 
 // New é a função que inicializa o objeto utilizado na função de start do server
-func New() *WebSys {
+// eventos é o canal de eventos de veículos enviados aos operadores em tempo real
+func New(authService *auth.Service, store storage.Store, tracker *visits.Tracker, reg *registry.Registry,
+	pas *passes.Manager, wl *watchlist.Watchlist, engine *access.Engine, gates *gate.Manager,
+	pending *override.Manager, cameras *ingest.Ingest, eventos <-chan defaults.EventoVeiculo) *WebSys {
 	log.Log(logService, "Criado serviço")
 
 	web := new(WebSys)
 	web.port = ":666"
+	web.auth = authService
+	web.store = store
+	web.tracker = tracker
+	web.registry = reg
+	web.passes = pas
+	web.watch = wl
+	web.engine = engine
+	web.gates = gates
+	web.pending = pending
+	web.cameras = cameras
+	web.eventos = eventos
+	web.stream = newStream()
 
 	return web
 }
@@ -37,9 +74,25 @@ func (ws *WebSys) Run() {
 		log.Log(logService, "Falha na criação de novo roteador: Objeto vazio")
 	}
 
-	// api := router.PathPrefix("/api/").Subrouter()
-	// ws.gpsAPIEndPoints(api)
-	// ws.configAPIEndPoints(api)
+	api := router.PathPrefix("/api").Subrouter()
+	ws.authAPIEndPoints(api)
+	ws.accountsAPIEndPoints(api)
+	ws.visitsAPIEndPoints(api)
+	ws.eventsAPIEndPoints(api)
+	ws.registryAPIEndPoints(api)
+	ws.passesAPIEndPoints(api)
+	ws.watchlistAPIEndPoints(api)
+	ws.accessAPIEndPoints(api)
+	ws.gatesAPIEndPoints(api)
+	ws.pendingAPIEndPoints(api)
+	ws.auditAPIEndPoints(api)
+	ws.camerasAPIEndPoints(api)
+	ws.streamAPIEndPoints(api)
+
+	go ws.stream.consomeEventos(ws.eventos)
+	go ws.stream.consomePendencias(ws.pending.Notificacoes)
+	go ws.stream.consomeAlertas(ws.watch.Alertas)
+	go ws.stream.consomeAlarmes(ws.cameras.Alarmes)
 
 	// Carrega os arquivos estáticos do Front
 	fs := http.FileServer(http.Dir(path.Join(defaults.GetPath(), "client", "build")))